@@ -4,47 +4,291 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"maps"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/galpt/cake-stats/pkg/alert"
+	"github.com/galpt/cake-stats/pkg/buildinfo"
+	"github.com/galpt/cake-stats/pkg/config"
+	"github.com/galpt/cake-stats/pkg/history"
 	"github.com/galpt/cake-stats/pkg/log"
 	"github.com/galpt/cake-stats/pkg/server"
-	"github.com/rs/zerolog"
 )
 
-// Version is overridden at build-time via -ldflags "-X main.Version=x.y.z".
-var Version = "1.0.0"
+// secretFlags are the flags whose values logEffectiveConfig redacts.  Every
+// flag defined in main that carries a password or token belongs here.
+var secretFlags = map[string]bool{
+	"auth-pass":       true,
+	"influx-token":    true,
+	"alert-smtp-pass": true,
+}
 
 func main() {
 	host := flag.String("host", "0.0.0.0", "bind address for web interface")
 	port := flag.Int("port", 11112, "TCP port for web interface")
 	interval := flag.Duration("interval", 100*time.Millisecond, "poll interval for tc")
 	histCap := flag.Int("history", 300, "samples to retain per interface")
+	minuteCap := flag.Int("history-minutes", history.DefaultMinuteCapacity, "one-minute samples to retain per interface (/api/history?resolution=minute)")
+	histPrecision := flag.Duration("history-precision", time.Millisecond, "sample timestamp resolution: 1s, 1ms or 1us (1s omits t_ms; polls within one second then share an SSE event ID)")
+	noWS := flag.Bool("no-ws", false, "disable the /ws and /api/live WebSocket endpoints")
+	ifaceList := flag.String("iface", "", "comma-separated interfaces to monitor (default: all); each is polled separately")
+	excludeList := flag.String("exclude-iface", "", "comma-separated interfaces to ignore")
+	emaAlpha := flag.Float64("ema-alpha", history.DefaultEMAAlpha, "smoothing factor (0,1] for the history TX graph; 1 disables smoothing")
+	wrapThreshold := flag.Uint64("wrap-threshold", history.DefaultWrapThreshold, "largest counter increase read as a 32-bit counter wrap rather than a reset; 1 disables wrap detection")
+	dbPath := flag.String("db", "", "persist history to this bbolt database file and restore it on start")
+	netns := flag.String("netns", "", "collect from this network namespace (name under /run/netns, or a path such as /proc/<pid>/ns/net)")
+	tlsCert := flag.String("tls-cert", "", "serve HTTPS with this PEM certificate (requires -tls-key)")
+	tlsKey := flag.String("tls-key", "", "PEM private key for -tls-cert")
+	tlsSelfSigned := flag.Bool("tls-self-signed", false, "serve HTTPS with a self-signed certificate generated in memory at start")
+	tlsRedirect := flag.Bool("tls-redirect", false, "with TLS on, also listen on -tls-redirect-port and redirect HTTP to HTTPS")
+	tlsRedirectPort := flag.Int("tls-redirect-port", 80, "plain-HTTP port for -tls-redirect")
+	authUser := flag.String("auth-user", "", "require HTTP Basic auth with this user name (requires -auth-pass)")
+	authPass := flag.String("auth-pass", "", "password for -auth-user")
+	corsOrigin := flag.String("cors-origin", "*", "comma-separated origins allowed to call the API cross-origin (\"*\" for any, empty to disable CORS)")
+	healthzPort := flag.Int("healthz-port", 0, "also serve /healthz alone on this port, without auth (0 = main port only)")
+	statsdAddr := flag.String("statsd-addr", "", "send every poll as gauges to this StatsD agent over UDP (e.g. localhost:8125)")
+	influxURL := flag.String("influx-url", "", "write every poll to this InfluxDB v2 server (e.g. http://localhost:8086; requires -influx-org and -influx-bucket)")
+	influxToken := flag.String("influx-token", "", "API token for -influx-url")
+	influxOrg := flag.String("influx-org", "", "organization for -influx-url")
+	influxBucket := flag.String("influx-bucket", "", "bucket for -influx-url")
+	pushGatewayURL := flag.String("push-gateway-url", "", "push the /metrics exposition to this Prometheus Pushgateway (e.g. http://pushgateway:9091), for when Prometheus cannot reach cake-stats")
+	pushGatewayJob := flag.String("push-gateway-job", server.DefaultPushGatewayJob, "job label for -push-gateway-url")
+	pushGatewayInstance := flag.String("push-gateway-instance", "", "instance label for -push-gateway-url (default: the host name)")
+	pushGatewayInterval := flag.Duration("push-gateway-interval", 0, "push interval for -push-gateway-url (0 = the poll interval)")
+	webhookURL := flag.String("webhook-url", "", "POST the /api/stats JSON to this URL after every successful poll")
+	webhookTimeout := flag.Duration("webhook-timeout", server.DefaultWebhookTimeout, "timeout for each webhook POST")
+	alertDropRate := flag.Float64("alert-drop-rate", 0, "alert when an interface drops more than this many packets per second (0 = off); alerts are logged, sent on /events and POSTed to -webhook-url")
+	alertUtilization := flag.Float64("alert-utilization", 0, "alert when an interface's transmit rate exceeds this percentage of its configured bandwidth (0 = off)")
+	alertAvDelay := flag.Float64("alert-av-delay", 0, "alert when an interface's largest tier average delay exceeds this many milliseconds (0 = off)")
+	alertPkDelay := flag.Float64("alert-pk-delay", 0, "alert when an interface's largest tier peak delay exceeds this many milliseconds (0 = off)")
+	smtpHost := flag.String("alert-smtp-host", "", "also mail alerts through this SMTP server (requires -alert-smtp-from and -alert-smtp-to)")
+	smtpPort := flag.Int("alert-smtp-port", 25, "port of -alert-smtp-host")
+	smtpFrom := flag.String("alert-smtp-from", "", "sender address of alert mails")
+	smtpTo := flag.String("alert-smtp-to", "", "comma-separated recipients of alert mails")
+	smtpUser := flag.String("alert-smtp-user", "", "SMTP user name for PLAIN auth (requires TLS, or a server on localhost)")
+	smtpPass := flag.String("alert-smtp-pass", "", "password for -alert-smtp-user")
+	alertLogSize := flag.Int("alert-log-size", alert.DefaultLogSize, "number of recent alerts kept for /api/alerts")
+	logLevel := flag.String("log-level", "info", "minimum log level: debug, info, warn or error (debug logs every parsed qdisc on each poll)")
+	logFormat := flag.String("log-format", "json", "log output: json or console")
+	sseHeartbeat := flag.Duration("sse-heartbeat", server.DefaultSSEHeartbeat, "interval of the keep-alive comment sent on idle SSE streams")
+	once := flag.Bool("once", false, "poll once, print the /api/stats JSON to stdout and exit without serving HTTP")
+	configPath := flag.String("config", "", "read settings from this YAML file; command-line flags take precedence")
 	showVer := flag.Bool("version", false, "print version and exit")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "cake-stats %s\n\n", Version)
+		fmt.Fprintf(os.Stderr, "cake-stats %s\n\n", buildinfo.Version)
 		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\nOptions:\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 	flag.Parse()
 
 	if *showVer {
-		fmt.Printf("cake-stats %s\n", Version)
+		fmt.Println(buildinfo.String())
 		os.Exit(0)
 	}
+	// Flags given on the command line, before -config fills in the rest.
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
 
+	log.Logger = log.Logger.With().Str("version", buildinfo.Version).Logger()
+	file := &config.File{}
+	if *configPath != "" {
+		var err error
+		if file, err = loadConfig(*configPath, given); err != nil {
+			log.Logger.Fatal().Err(err).Msg("invalid -config")
+		}
+	}
+	historyCaps := file.HistoryCapacities()
+	if err := log.SetLevel(*logLevel); err != nil {
+		log.Logger.Fatal().Err(err).Msg("invalid -log-level")
+	}
+	if err := log.SetFormat(*logFormat); err != nil {
+		log.Logger.Fatal().Err(err).Msg("invalid -log-format")
+	}
 	addr := fmt.Sprintf("%s:%d", *host, *port)
-	log.Logger = log.Logger.Level(zerolog.InfoLevel).With().Str("version", Version).Logger()
+	if *once {
+		if given["port"] {
+			log.Logger.Fatal().Msg("-once and -port are mutually exclusive")
+		}
+		runOnce(server.Config{
+			Interfaces:        splitList(*ifaceList),
+			ExcludeInterfaces: splitList(*excludeList),
+			Netns:             *netns,
+		})
+		return
+	}
+	logEffectiveConfig(historyCaps)
+
+	var deliveries []alert.AlertDelivery
+	if *smtpHost != "" {
+		if *smtpFrom == "" || *smtpTo == "" {
+			log.Logger.Fatal().Msg("-alert-smtp-host requires -alert-smtp-from and -alert-smtp-to")
+		}
+		deliveries = append(deliveries, &alert.SMTPDelivery{
+			Host: *smtpHost,
+			Port: *smtpPort,
+			From: *smtpFrom,
+			To:   splitList(*smtpTo),
+			User: *smtpUser,
+			Pass: *smtpPass,
+		})
+	}
+
+	var healthzAddr string
+	if *healthzPort != 0 {
+		healthzAddr = fmt.Sprintf("%s:%d", *host, *healthzPort)
+	}
+	var redirectAddr string
+	if *tlsRedirect {
+		redirectAddr = fmt.Sprintf("%s:%d", *host, *tlsRedirectPort)
+	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	srv := server.New(addr, *interval, *histCap)
+	srv := server.New(addr, server.Config{
+		Interval:            *interval,
+		HistoryCap:          *histCap,
+		HistoryCapacities:   historyCaps,
+		MinuteHistoryCap:    *minuteCap,
+		HistoryPrecision:    *histPrecision,
+		NoWebSocket:         *noWS,
+		Interfaces:          splitList(*ifaceList),
+		ExcludeInterfaces:   splitList(*excludeList),
+		EMAAlpha:            *emaAlpha,
+		WrapThreshold:       *wrapThreshold,
+		DBPath:              *dbPath,
+		Netns:               *netns,
+		TLSCert:             *tlsCert,
+		TLSKey:              *tlsKey,
+		TLSSelfSigned:       *tlsSelfSigned,
+		TLSRedirect:         redirectAddr,
+		AuthUser:            *authUser,
+		AuthPass:            *authPass,
+		CORSOrigins:         splitList(*corsOrigin),
+		HealthzAddr:         healthzAddr,
+		WebhookURL:          *webhookURL,
+		WebhookTimeout:      *webhookTimeout,
+		SSEHeartbeat:        *sseHeartbeat,
+		AlertRules:          alertRules(file, *alertDropRate, *alertUtilization, *alertAvDelay, *alertPkDelay),
+		AlertDeliveries:     deliveries,
+		AlertLogSize:        *alertLogSize,
+		StatsdAddr:          *statsdAddr,
+		InfluxURL:           *influxURL,
+		InfluxToken:         *influxToken,
+		InfluxOrg:           *influxOrg,
+		InfluxBucket:        *influxBucket,
+		PushGatewayURL:      *pushGatewayURL,
+		PushGatewayJob:      *pushGatewayJob,
+		PushGatewayInstance: *pushGatewayInstance,
+		PushGatewayInterval: *pushGatewayInterval,
+	})
+	if *configPath != "" {
+		srv.WatchReload(ctx, func() (server.Config, error) {
+			file, err := loadConfig(*configPath, given)
+			if err != nil {
+				return server.Config{}, err
+			}
+			return server.Config{
+				Interval:          *interval,
+				HistoryCap:        *histCap,
+				HistoryCapacities: file.HistoryCapacities(),
+				WebhookURL:        *webhookURL,
+			}, nil
+		})
+	}
 	if err := srv.Run(ctx, addr); err != nil {
 		log.Logger.Fatal().Err(err).Msg("fatal")
 	}
 	log.Logger.Info().Msg("shutdown complete")
 }
+
+// alertRules builds the rules selected by the -alert-* flags, followed by the
+// per-interface thresholds of file, which override them.
+func alertRules(file *config.File, dropRate, utilization, avDelay, pkDelay float64) []alert.AlertRule {
+	var rules []alert.AlertRule
+	add := func(iface, metric string, threshold float64) {
+		if threshold > 0 {
+			rules = append(rules, alert.AlertRule{Interface: iface, Metric: metric, Op: ">", Threshold: threshold})
+		}
+	}
+	add("", "drops_per_s", dropRate)
+	add("", "utilization_pct", utilization)
+	add("", "max_av_delay_ms", avDelay)
+	add("", "max_pk_delay_ms", pkDelay)
+	for _, name := range slices.Sorted(maps.Keys(file.Interfaces)) {
+		iface := file.Interfaces[name]
+		add(name, "drops_per_s", iface.DropRateThreshold)
+		add(name, "utilization_pct", iface.UtilizationThreshold)
+		add(name, "max_av_delay_ms", iface.AvDelayMsThreshold)
+		add(name, "max_pk_delay_ms", iface.PkDelayMsThreshold)
+	}
+	return rules
+}
+
+// loadConfig reads the -config file into the flags not in given, first
+// resetting those flags to their defaults so that keys removed from the file
+// since the last load stop applying.  It returns the file for its
+// per-interface settings.
+func loadConfig(path string, given map[string]bool) (*config.File, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		if !given[f.Name] {
+			_ = f.Value.Set(f.DefValue)
+		}
+	})
+	if err := cfg.Apply(flag.CommandLine, given); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// runOnce implements -once: one poll, its JSON on stdout, exit status 1 if tc
+// failed or found no CAKE qdisc.
+func runOnce(cfg server.Config) {
+	b, err := server.New("", cfg).PollOnce()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cake-stats:", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(append(b, '\n'))
+}
+
+// logEffectiveConfig logs every flag value after -config has been applied,
+// with the values of secretFlags redacted.
+func logEffectiveConfig(historyCaps map[string]int) {
+	ev := log.Logger.Info()
+	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name == "version" {
+			return // clashes with the logger's version field
+		}
+		v := f.Value.String()
+		if secretFlags[f.Name] && v != "" {
+			v = "<redacted>"
+		}
+		ev = ev.Str(f.Name, v)
+	})
+	if len(historyCaps) > 0 {
+		ev = ev.Interface("history_per_iface", historyCaps)
+	}
+	ev.Msg("effective configuration")
+}
+
+// splitList splits a comma-separated flag value, dropping blanks.
+func splitList(v string) []string {
+	var out []string
+	for _, f := range strings.Split(v, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
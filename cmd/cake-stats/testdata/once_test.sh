@@ -0,0 +1,54 @@
+#!/bin/sh
+# Integration test for -once: runs the real binary against a fake tc and
+# checks that it prints valid /api/stats JSON and exits 0, and that it exits
+# 1 when tc reports no CAKE qdisc.
+#
+# -netns forces the tc path (netlink would read the host's real qdiscs); a
+# fake nsenter on PATH just drops its --net argument and runs tc.
+#
+# Usage: sh cmd/cake-stats/testdata/once_test.sh   (from the repository root)
+set -eu
+
+here=$(cd "$(dirname "$0")" && pwd)
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+go build -o "$tmp/cake-stats" ./cmd/cake-stats
+
+mkdir "$tmp/bin"
+cat > "$tmp/bin/nsenter" <<'EOF'
+#!/bin/sh
+shift
+exec "$@"
+EOF
+cat > "$tmp/bin/tc" <<EOF
+#!/bin/sh
+cat "\${FAKE_TC_OUTPUT:-$here/tc-output.txt}"
+EOF
+chmod +x "$tmp/bin/nsenter" "$tmp/bin/tc"
+export PATH="$tmp/bin:$PATH"
+
+fail() { echo "FAIL: $*" >&2; exit 1; }
+
+"$tmp/cake-stats" -once -netns /fake/ns > "$tmp/out.json" 2> "$tmp/err.log" ||
+	fail "-once exited $? ($(cat "$tmp/err.log"))"
+python3 - "$tmp/out.json" <<'EOF' || fail "output is not /api/stats JSON: $(cat "$tmp/out.json")"
+import json, sys
+doc = json.load(open(sys.argv[1]))
+assert doc["updated_at"], "missing updated_at"
+names = [i["interface"] for i in doc["interfaces"]]
+assert names, "no interfaces"
+assert all(i["tiers"] for i in doc["interfaces"]), "missing tiers"
+EOF
+
+: > "$tmp/empty.txt"
+if FAKE_TC_OUTPUT="$tmp/empty.txt" "$tmp/cake-stats" -once -netns /fake/ns > "$tmp/out.json" 2> "$tmp/err.log"; then
+	fail "-once with no CAKE qdisc exited 0"
+fi
+grep -q "no CAKE interfaces" "$tmp/err.log" || fail "missing error message: $(cat "$tmp/err.log")"
+
+if "$tmp/cake-stats" -once -port 8080 > /dev/null 2>&1; then
+	fail "-once -port exited 0"
+fi
+
+echo "PASS"
@@ -223,7 +223,7 @@ After=network.target
 Wants=network.target
 
 [Service]
-Type=simple
+Type=notify
 ExecStart=${BINARY_PATH} -port ${PORT} -interval ${INTERVAL}
 Restart=on-failure
 RestartSec=5
@@ -0,0 +1,358 @@
+// Package alert evaluates threshold rules against every poll's statistics
+// and hands the alerts they raise to a delivery function, which sends them on
+// through AlertDelivery backends such as SMTPDelivery.
+package alert
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+	"github.com/galpt/cake-stats/pkg/util"
+)
+
+// DefaultCooldown is the AlertRule.Cooldown used when it is zero.
+const DefaultCooldown = 60 * time.Second
+
+// DefaultLogSize is the number of alerts kept for Log unless changed with
+// SetLogSize.
+const DefaultLogSize = 100
+
+// ErrUnknownMetric is returned for an AlertRule.Metric not in Metrics.
+var ErrUnknownMetric = errors.New("unknown alert metric")
+
+// ErrInvalidOp is returned for an AlertRule.Op other than >, >=, < or <=.
+var ErrInvalidOp = errors.New("invalid alert operator")
+
+// Metrics maps the metric names rules can use, the CakeStats JSON field
+// names, to their value.  Rates are computed by HistoryStore.Record, so rules
+// see the stats after the poll has been recorded.
+var Metrics = map[string]func(*types.CakeStats) float64{
+	"tx_bytes_per_s":  func(cs *types.CakeStats) float64 { return cs.TxBytesPerS },
+	"drops_per_s":     func(cs *types.CakeStats) float64 { return cs.DropsPerS },
+	"marks_per_s":     func(cs *types.CakeStats) float64 { return cs.MarksPerS },
+	"max_av_delay_ms": func(cs *types.CakeStats) float64 { return cs.MaxAvDelayMs },
+	"max_pk_delay_ms": func(cs *types.CakeStats) float64 { return cs.MaxPkDelayMs },
+	"utilization_pct": func(cs *types.CakeStats) float64 { return cs.UtilizationPct },
+	"memory_pct":      func(cs *types.CakeStats) float64 { return cs.MemoryPct },
+}
+
+// tierDelays maps the delay metrics to the per-tier field they are the
+// maximum of, so an alert can name the tier responsible.
+var tierDelays = map[string]func(types.CakeTier) string{
+	"max_av_delay_ms": func(t types.CakeTier) string { return t.AvDelay },
+	"max_pk_delay_ms": func(t types.CakeTier) string { return t.PkDelay },
+}
+
+// AlertRule raises an alert for every interface whose Metric compares to
+// Threshold with Op, and a recovery once it no longer does.  A rule naming an
+// Interface overrides the rules for the same Metric that match all
+// interfaces, so a per-interface threshold replaces the global one.
+type AlertRule struct {
+	// Interface limits the rule to one interface; empty matches all.
+	Interface string
+	Metric    string
+	Op        string
+	Threshold float64
+	// Cooldown is the minimum time between two alerts for the same rule
+	// and interface, whether the condition persists or flaps; 0 means
+	// DefaultCooldown.
+	Cooldown time.Duration
+}
+
+func (r AlertRule) cooldown() time.Duration {
+	if r.Cooldown <= 0 {
+		return DefaultCooldown
+	}
+	return r.Cooldown
+}
+
+func (r AlertRule) validate() error {
+	if Metrics[r.Metric] == nil {
+		return fmt.Errorf("%w %q", ErrUnknownMetric, r.Metric)
+	}
+	switch r.Op {
+	case ">", ">=", "<", "<=":
+		return nil
+	}
+	return fmt.Errorf("%w %q", ErrInvalidOp, r.Op)
+}
+
+func (r AlertRule) matches(v float64) bool {
+	switch r.Op {
+	case ">":
+		return v > r.Threshold
+	case ">=":
+		return v >= r.Threshold
+	case "<":
+		return v < r.Threshold
+	default:
+		return v <= r.Threshold
+	}
+}
+
+// ruleState is the alert state of one rule on one interface.
+type ruleState struct {
+	// inAlert is set while the condition holds.
+	inAlert bool
+	// lastFired is when the last alert was sent; firstFired is the first
+	// alert of the current episode, zero while none went out.
+	lastFired  time.Time
+	firstFired time.Time
+	// logSeq is the sequence number of the episode's Log entry.
+	logSeq uint64
+	// last is the alert built from the latest poll, the base of the
+	// recovery sent if the interface disappears.
+	last types.CakeAlert
+}
+
+type stateKey struct {
+	rule  int
+	iface string
+}
+
+// AlertManager evaluates its rules against the polls handed to Observe on
+// its own goroutine (Run), so slow deliveries never hold up the poller.
+type AlertManager struct {
+	rules []AlertRule
+	// overridden holds, per metric, the interfaces with their own rule.
+	overridden map[string]map[string]bool
+	deliver    func(context.Context, types.CakeAlert)
+	queue      chan []types.CakeStats
+	// now is the clock stamped into alerts; see SetClock.
+	now func() time.Time
+
+	mu     sync.Mutex
+	states map[stateKey]*ruleState
+	// log holds the latest alerts, oldest first; log[0] has sequence
+	// number logStart.
+	log      []types.AlertLogEntry
+	logStart uint64
+	logSize  int
+}
+
+// NewAlertManager validates rules and returns a manager that passes every
+// alert they raise to deliver.
+func NewAlertManager(rules []AlertRule, deliver func(context.Context, types.CakeAlert)) (*AlertManager, error) {
+	overridden := make(map[string]map[string]bool)
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+		if r.Interface != "" {
+			if overridden[r.Metric] == nil {
+				overridden[r.Metric] = make(map[string]bool)
+			}
+			overridden[r.Metric][r.Interface] = true
+		}
+	}
+	return &AlertManager{
+		rules:      rules,
+		overridden: overridden,
+		deliver:    deliver,
+		queue:      make(chan []types.CakeStats, 1),
+		now:        time.Now,
+		states:     make(map[stateKey]*ruleState),
+		logSize:    DefaultLogSize,
+	}, nil
+}
+
+// SetClock replaces time.Now as the clock stamped into alerts.
+func (m *AlertManager) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	m.now = now
+	m.mu.Unlock()
+}
+
+// SetLogSize changes how many alerts Log keeps, dropping the oldest if there
+// are more.  Sizes below 1 are treated as 1.
+func (m *AlertManager) SetLogSize(n int) {
+	n = max(n, 1)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logSize = n
+	if extra := len(m.log) - n; extra > 0 {
+		m.log = append(m.log[:0], m.log[extra:]...)
+		m.logStart += uint64(extra)
+	}
+}
+
+// Log returns the latest alerts, newest first.  Each entry is the first alert
+// of an episode; repeats while it persists are not logged again.
+func (m *AlertManager) Log() types.AlertsResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	out := make(types.AlertsResponse, len(m.log))
+	for i, e := range m.log {
+		end := now
+		if e.ResolvedAt != nil {
+			end = *e.ResolvedAt
+		}
+		e.DurationMs = end.Sub(e.TriggeredAt).Milliseconds()
+		out[len(m.log)-1-i] = e
+	}
+	return out
+}
+
+// appendLog adds a to the log, evicting the oldest entry when it is full, and
+// returns its sequence number.
+func (m *AlertManager) appendLog(a types.CakeAlert) uint64 {
+	if len(m.log) >= m.logSize {
+		m.log = append(m.log[:0], m.log[1:]...)
+		m.logStart++
+	}
+	m.log = append(m.log, types.AlertLogEntry{CakeAlert: a})
+	return m.logStart + uint64(len(m.log)) - 1
+}
+
+// resolveLog marks the entry with sequence number seq resolved at t, unless
+// it has already been evicted.
+func (m *AlertManager) resolveLog(seq uint64, t time.Time) {
+	if seq < m.logStart {
+		return
+	}
+	if i := seq - m.logStart; i < uint64(len(m.log)) {
+		m.log[i].ResolvedAt = &t
+	}
+}
+
+// Observe hands one poll to Run without blocking.  If Run is still busy with
+// an earlier poll that has not been picked up, the newer poll replaces it.
+func (m *AlertManager) Observe(stats []types.CakeStats) {
+	for {
+		select {
+		case m.queue <- stats:
+			return
+		default:
+		}
+		select {
+		case <-m.queue:
+		default:
+		}
+	}
+}
+
+// Run evaluates observed polls until ctx is done.
+func (m *AlertManager) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case stats := <-m.queue:
+			for _, a := range m.Evaluate(stats) {
+				m.deliver(ctx, a)
+			}
+		}
+	}
+}
+
+// Evaluate checks every rule against every interface in stats and returns
+// the alerts and recoveries to deliver, in rule order.
+//
+// A condition that starts to hold fires an alert unless the rule's cooldown
+// since the last alert has not passed; while it keeps holding it fires again
+// at most once per cooldown.  When it stops holding, a recovery (ResolvedAt
+// set) is sent if an alert went out for that episode.  An interface missing
+// from stats counts as recovered, and its state is dropped.
+func (m *AlertManager) Evaluate(stats []types.CakeStats) []types.CakeAlert {
+	var out []types.CakeAlert
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for ri, r := range m.rules {
+		value := Metrics[r.Metric]
+		for i := range stats {
+			cs := &stats[i]
+			if r.Interface != "" && r.Interface != cs.Interface {
+				continue
+			}
+			if r.Interface == "" && m.overridden[r.Metric][cs.Interface] {
+				continue
+			}
+			key := stateKey{ri, cs.Interface}
+			st := m.states[key]
+			if st == nil {
+				st = &ruleState{}
+				m.states[key] = st
+			}
+			v := value(cs)
+			a := types.CakeAlert{Interface: cs.Interface, Metric: r.Metric, Value: v, Threshold: r.Threshold}
+			if r.Metric == "utilization_pct" {
+				a.Bandwidth = cs.Bandwidth
+			}
+			if field := tierDelays[r.Metric]; field != nil {
+				a.Tier = slowestTier(cs.Tiers, field)
+			}
+			st.last = a
+			switch {
+			case r.matches(v):
+				if !st.inAlert {
+					st.inAlert, st.firstFired = true, time.Time{}
+				}
+				if st.lastFired.IsZero() || now.Sub(st.lastFired) >= r.cooldown() {
+					st.lastFired = now
+					a.TriggeredAt = now
+					if st.firstFired.IsZero() {
+						st.firstFired = now
+						st.logSeq = m.appendLog(a)
+					}
+					out = append(out, a)
+				}
+			case st.inAlert:
+				st.inAlert = false
+				if !st.firstFired.IsZero() {
+					a.TriggeredAt = st.firstFired
+					a.ResolvedAt = &now
+					m.resolveLog(st.logSeq, now)
+					out = append(out, a)
+				}
+			}
+		}
+	}
+
+	present := make(map[string]bool, len(stats))
+	for i := range stats {
+		present[stats[i].Interface] = true
+	}
+	var gone []stateKey
+	for key := range m.states {
+		if !present[key.iface] {
+			gone = append(gone, key)
+		}
+	}
+	slices.SortFunc(gone, func(a, b stateKey) int {
+		return cmp.Or(cmp.Compare(a.rule, b.rule), strings.Compare(a.iface, b.iface))
+	})
+	for _, key := range gone {
+		st := m.states[key]
+		delete(m.states, key)
+		if st.inAlert && !st.firstFired.IsZero() {
+			a := st.last
+			a.TriggeredAt = st.firstFired
+			a.ResolvedAt = &now
+			m.resolveLog(st.logSeq, now)
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// slowestTier returns the name of the tier whose delay field is largest, or ""
+// if no tier reports a delay.
+func slowestTier(tiers []types.CakeTier, field func(types.CakeTier) string) string {
+	var name string
+	var worst float64
+	for _, t := range tiers {
+		if v := util.ParseDelayMs(field(t)); v > worst {
+			name, worst = t.Name, v
+		}
+	}
+	return name
+}
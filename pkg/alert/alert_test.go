@@ -0,0 +1,231 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestEvaluate_DropRate(t *testing.T) {
+	m, err := NewAlertManager([]AlertRule{{Metric: "drops_per_s", Op: ">", Threshold: 5}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dr := range []float64{0, 3, 5} {
+		if got := m.Evaluate([]types.CakeStats{{Interface: "eth0", DropsPerS: dr}}); len(got) != 0 {
+			t.Errorf("drops_per_s %v: want no alert, got %+v", dr, got)
+		}
+	}
+	got := m.Evaluate([]types.CakeStats{{Interface: "eth0", DropsPerS: 1}, {Interface: "ifb4eth0", DropsPerS: 12.5}})
+	if len(got) != 1 {
+		t.Fatalf("want 1 alert, got %+v", got)
+	}
+	a := got[0]
+	if a.Interface != "ifb4eth0" || a.Metric != "drops_per_s" || a.Value != 12.5 || a.Threshold != 5 || a.TriggeredAt.IsZero() {
+		t.Errorf("alert: got %+v", a)
+	}
+}
+
+func TestEvaluate_Utilization(t *testing.T) {
+	m, err := NewAlertManager([]AlertRule{{Metric: "utilization_pct", Op: ">", Threshold: 95}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Evaluate([]types.CakeStats{{Interface: "eth0", Bandwidth: "100Mbit", UtilizationPct: 80}}); len(got) != 0 {
+		t.Errorf("80%%: want no alert, got %+v", got)
+	}
+	got := m.Evaluate([]types.CakeStats{{Interface: "eth0", Bandwidth: "100Mbit", UtilizationPct: 96}})
+	if len(got) != 1 {
+		t.Fatalf("96%%: want 1 alert, got %+v", got)
+	}
+	if a := got[0]; a.Interface != "eth0" || a.Value != 96 || a.Bandwidth != "100Mbit" {
+		t.Errorf("alert: got %+v", a)
+	}
+}
+
+func TestEvaluate_DelayPerInterface(t *testing.T) {
+	m, err := NewAlertManager([]AlertRule{
+		{Metric: "max_av_delay_ms", Op: ">", Threshold: 20},
+		{Metric: "max_pk_delay_ms", Op: ">", Threshold: 50},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.Evaluate([]types.CakeStats{
+		{Interface: "eth0", MaxAvDelayMs: 2, MaxPkDelayMs: 5, Tiers: []types.CakeTier{{Name: "Bulk", AvDelay: "2ms", PkDelay: "5ms"}}},
+		{Interface: "ifb4eth1", MaxAvDelayMs: 35, MaxPkDelayMs: 80, Tiers: []types.CakeTier{
+			{Name: "Bulk", AvDelay: "35ms", PkDelay: "12ms"},
+			{Name: "Best Effort", AvDelay: "4ms", PkDelay: "80ms"},
+			{Name: "Video", AvDelay: "1ms", PkDelay: "3ms"},
+		}},
+	})
+	if len(got) != 2 {
+		t.Fatalf("want 2 alerts, got %+v", got)
+	}
+	for i, want := range []types.CakeAlert{
+		{Interface: "ifb4eth1", Metric: "max_av_delay_ms", Value: 35, Tier: "Bulk"},
+		{Interface: "ifb4eth1", Metric: "max_pk_delay_ms", Value: 80, Tier: "Best Effort"},
+	} {
+		if a := got[i]; a.Interface != want.Interface || a.Metric != want.Metric || a.Value != want.Value || a.Tier != want.Tier {
+			t.Errorf("alert %d: got %+v, want %+v", i, a, want)
+		}
+	}
+}
+
+func TestEvaluate_InterfaceOverride(t *testing.T) {
+	m, err := NewAlertManager([]AlertRule{
+		{Metric: "max_av_delay_ms", Op: ">", Threshold: 20},
+		{Interface: "wan", Metric: "max_av_delay_ms", Op: ">", Threshold: 10},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.Evaluate([]types.CakeStats{{Interface: "wan", MaxAvDelayMs: 15}, {Interface: "ifb4wan", MaxAvDelayMs: 15}})
+	if len(got) != 1 || got[0].Interface != "wan" || got[0].Threshold != 10 {
+		t.Fatalf("15ms: want one wan alert at threshold 10, got %+v", got)
+	}
+	// Above both thresholds the global rule still skips wan, whose own alert
+	// is already firing.
+	got = m.Evaluate([]types.CakeStats{{Interface: "wan", MaxAvDelayMs: 25}, {Interface: "ifb4wan", MaxAvDelayMs: 25}})
+	if len(got) != 1 || got[0].Interface != "ifb4wan" || got[0].Threshold != 20 {
+		t.Errorf("25ms: want one ifb4wan alert at threshold 20, got %+v", got)
+	}
+}
+
+func TestEvaluate_InterfaceRule(t *testing.T) {
+	m, err := NewAlertManager([]AlertRule{{Interface: "eth0", Metric: "drops_per_s", Op: ">=", Threshold: 1}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.Evaluate([]types.CakeStats{{Interface: "eth0", DropsPerS: 1}, {Interface: "eth1", DropsPerS: 9}})
+	if len(got) != 1 || got[0].Interface != "eth0" {
+		t.Errorf("want one eth0 alert, got %+v", got)
+	}
+}
+
+func TestNewAlertManager_Validation(t *testing.T) {
+	if _, err := NewAlertManager([]AlertRule{{Metric: "nope", Op: ">"}}, nil); !errors.Is(err, ErrUnknownMetric) {
+		t.Errorf("unknown metric: got %v", err)
+	}
+	if _, err := NewAlertManager([]AlertRule{{Metric: "drops_per_s", Op: "=="}}, nil); !errors.Is(err, ErrInvalidOp) {
+		t.Errorf("invalid op: got %v", err)
+	}
+}
+
+func TestRun_Delivers(t *testing.T) {
+	delivered := make(chan types.CakeAlert, 1)
+	m, err := NewAlertManager([]AlertRule{{Metric: "drops_per_s", Op: ">", Threshold: 0}},
+		func(_ context.Context, a types.CakeAlert) { delivered <- a })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	m.Observe([]types.CakeStats{{Interface: "eth0", DropsPerS: 2}})
+	select {
+	case a := <-delivered:
+		if a.Interface != "eth0" || a.Value != 2 {
+			t.Errorf("got %+v", a)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("alert not delivered")
+	}
+}
+
+// step feeds m one poll per 100ms for d, with the drop rate given by rate at
+// each instant, and returns the alerts and recoveries raised.
+func step(m *AlertManager, clock *time.Time, d time.Duration, rate func(time.Duration) float64) (alerts, recoveries []types.CakeAlert) {
+	start := *clock
+	m.now = func() time.Time { return *clock }
+	for ; clock.Sub(start) < d; *clock = clock.Add(100 * time.Millisecond) {
+		for _, a := range m.Evaluate([]types.CakeStats{{Interface: "eth0", DropsPerS: rate(clock.Sub(start))}}) {
+			if a.ResolvedAt == nil {
+				alerts = append(alerts, a)
+			} else {
+				recoveries = append(recoveries, a)
+			}
+		}
+	}
+	return alerts, recoveries
+}
+
+func TestEvaluate_Cooldown(t *testing.T) {
+	rule := AlertRule{Metric: "drops_per_s", Op: ">", Threshold: 5, Cooldown: 5 * time.Second}
+	clock := time.Unix(1700000000, 0)
+
+	// Dropping for 3s out of 10s: one alert, one recovery.
+	m, _ := NewAlertManager([]AlertRule{rule}, nil)
+	alerts, recoveries := step(m, &clock, 10*time.Second, func(at time.Duration) float64 {
+		if at >= time.Second && at < 4*time.Second {
+			return 20
+		}
+		return 0
+	})
+	if len(alerts) != 1 || len(recoveries) != 1 {
+		t.Fatalf("want 1 alert and 1 recovery, got %d and %d", len(alerts), len(recoveries))
+	}
+	if r := recoveries[0]; !r.TriggeredAt.Equal(alerts[0].TriggeredAt) || r.ResolvedAt.Sub(r.TriggeredAt) != 3*time.Second {
+		t.Errorf("recovery: triggered %v resolved %v", r.TriggeredAt, r.ResolvedAt)
+	}
+
+	// Flapping every 500ms for 10s: the cooldown allows one alert per 5s.
+	m, _ = NewAlertManager([]AlertRule{rule}, nil)
+	alerts, recoveries = step(m, &clock, 10*time.Second, func(at time.Duration) float64 {
+		if at/(500*time.Millisecond)%2 == 0 {
+			return 20
+		}
+		return 0
+	})
+	if len(alerts) != 2 || len(recoveries) != 2 {
+		t.Errorf("flapping: want 2 alerts and 2 recoveries, got %d and %d", len(alerts), len(recoveries))
+	}
+
+	// Dropping throughout: re-fired once per cooldown, never recovered.
+	m, _ = NewAlertManager([]AlertRule{rule}, nil)
+	alerts, recoveries = step(m, &clock, 10*time.Second, func(time.Duration) float64 { return 20 })
+	if len(alerts) != 2 || len(recoveries) != 0 {
+		t.Errorf("sustained: want 2 alerts and no recovery, got %d and %d", len(alerts), len(recoveries))
+	}
+}
+
+func TestEvaluate_InterfaceVanishes(t *testing.T) {
+	m, _ := NewAlertManager([]AlertRule{{Metric: "drops_per_s", Op: ">", Threshold: 5}}, nil)
+	if got := m.Evaluate([]types.CakeStats{{Interface: "eth0", DropsPerS: 9}, {Interface: "eth1"}}); len(got) != 1 {
+		t.Fatalf("want 1 alert, got %+v", got)
+	}
+	got := m.Evaluate([]types.CakeStats{{Interface: "eth1"}})
+	if len(got) != 1 || got[0].Interface != "eth0" || got[0].ResolvedAt == nil || got[0].Value != 9 {
+		t.Fatalf("want eth0's recovery, got %+v", got)
+	}
+	if log := m.Log(); len(log) != 1 || log[0].ResolvedAt == nil {
+		t.Errorf("log entry not resolved: %+v", log)
+	}
+	if _, ok := m.states[stateKey{0, "eth0"}]; ok {
+		t.Error("state of the vanished interface kept")
+	}
+	if got := m.Evaluate([]types.CakeStats{{Interface: "eth1"}}); len(got) != 0 {
+		t.Errorf("recovery sent twice: %+v", got)
+	}
+}
+
+func TestLog_Evicts(t *testing.T) {
+	m, _ := NewAlertManager([]AlertRule{{Metric: "drops_per_s", Op: ">", Threshold: 5}}, nil)
+	m.SetLogSize(2)
+	stats := []types.CakeStats{{Interface: "eth0", DropsPerS: 9}, {Interface: "eth1", DropsPerS: 9}, {Interface: "eth2", DropsPerS: 9}}
+	m.Evaluate(stats)
+	stats[0].DropsPerS, stats[2].DropsPerS = 0, 0
+	m.Evaluate(stats)
+
+	got := m.Log()
+	if len(got) != 2 || got[0].Interface != "eth2" || got[1].Interface != "eth1" {
+		t.Fatalf("want eth2, eth1; got %+v", got)
+	}
+	if got[0].ResolvedAt == nil || got[1].ResolvedAt != nil {
+		t.Errorf("want only eth2 resolved, got %+v", got)
+	}
+}
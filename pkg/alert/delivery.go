@@ -0,0 +1,126 @@
+package alert
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// DefaultSMTPTimeout bounds each SMTPDelivery.Deliver when the context has no
+// earlier deadline.
+const DefaultSMTPTimeout = 10 * time.Second
+
+// AlertDelivery sends alerts, and recoveries, to one destination.
+type AlertDelivery interface {
+	Deliver(ctx context.Context, a types.CakeAlert) error
+}
+
+// SMTPDelivery mails each alert as a plain-text message.  STARTTLS is used
+// when the server offers it; User and Pass, when set, authenticate with PLAIN,
+// which net/smtp only allows over TLS or to localhost.
+type SMTPDelivery struct {
+	Host string
+	// Port defaults to 25.
+	Port int
+	From string
+	To   []string
+	User string
+	Pass string
+}
+
+// Deliver sends a to every recipient in one SMTP transaction.
+func (d *SMTPDelivery) Deliver(ctx context.Context, a types.CakeAlert) error {
+	port := d.Port
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(d.Host, strconv.Itoa(port))
+	ctx, cancel := context.WithTimeout(ctx, DefaultSMTPTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	c, err := smtp.NewClient(conn, d.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer c.Close()
+	if err := d.send(c, a); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return nil
+}
+
+func (d *SMTPDelivery) send(c *smtp.Client, a types.CakeAlert) error {
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: d.Host}); err != nil {
+			return err
+		}
+	}
+	if d.User != "" {
+		if err := c.Auth(smtp.PlainAuth("", d.User, d.Pass, d.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(d.From); err != nil {
+		return err
+	}
+	for _, to := range d.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(d.message(a)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message formats a as a plain-text mail with headers.  Lines end in "\n";
+// the DATA writer turns them into CRLF.
+func (d *SMTPDelivery) message(a types.CakeAlert) []byte {
+	state := "alert"
+	if a.ResolvedAt != nil {
+		state = "resolved"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\n", d.From)
+	fmt.Fprintf(&b, "To: %s\n", strings.Join(d.To, ", "))
+	fmt.Fprintf(&b, "Subject: cake-stats %s: %s on %s\n", state, a.Metric, a.Interface)
+	fmt.Fprintf(&b, "Date: %s\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\nContent-Type: text/plain; charset=utf-8\n\n")
+	fmt.Fprintf(&b, "Interface: %s\n", a.Interface)
+	fmt.Fprintf(&b, "Metric:    %s\n", a.Metric)
+	fmt.Fprintf(&b, "Value:     %s\n", strconv.FormatFloat(a.Value, 'f', -1, 64))
+	fmt.Fprintf(&b, "Threshold: %s\n", strconv.FormatFloat(a.Threshold, 'f', -1, 64))
+	if a.Tier != "" {
+		fmt.Fprintf(&b, "Tier:      %s\n", a.Tier)
+	}
+	if a.Bandwidth != "" {
+		fmt.Fprintf(&b, "Bandwidth: %s\n", a.Bandwidth)
+	}
+	fmt.Fprintf(&b, "Triggered: %s\n", a.TriggeredAt.Format(time.RFC3339))
+	if a.ResolvedAt != nil {
+		fmt.Fprintf(&b, "Resolved:  %s\n", a.ResolvedAt.Format(time.RFC3339))
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,93 @@
+package alert
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// mockSMTP accepts one SMTP session on a loopback port and sends the
+// recipients and message it received on the returned channel.
+func mockSMTP(t *testing.T) (port int, mail <-chan [2]string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	ch := make(chan [2]string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 mock ESMTP")
+		var rcpt []string
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO", "MAIL":
+				_ = tp.PrintfLine("250 ok")
+			case "RCPT":
+				rcpt = append(rcpt, arg)
+				_ = tp.PrintfLine("250 ok")
+			case "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				body, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				ch <- [2]string{strings.Join(rcpt, ","), string(body)}
+				_ = tp.PrintfLine("250 queued")
+			case "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				return
+			default:
+				_ = tp.PrintfLine("502 unknown command")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, ch
+}
+
+func TestSMTPDelivery(t *testing.T) {
+	port, mail := mockSMTP(t)
+	d := &SMTPDelivery{Host: "127.0.0.1", Port: port, From: "router@example.com", To: []string{"ops@example.com", "noc@example.com"}}
+	a := types.CakeAlert{Interface: "ifb4eth1", Metric: "max_av_delay_ms", Value: 42.5, Threshold: 20, Tier: "Bulk", TriggeredAt: time.Now()}
+	if err := d.Deliver(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	got := <-mail
+	if got[0] != "TO:<ops@example.com>,TO:<noc@example.com>" {
+		t.Errorf("recipients: got %q", got[0])
+	}
+	for _, want := range []string{"Subject: cake-stats alert: max_av_delay_ms on ifb4eth1", "Interface: ifb4eth1", "Value:     42.5", "Tier:      Bulk"} {
+		if !strings.Contains(got[1], want) {
+			t.Errorf("message lacks %q:\n%s", want, got[1])
+		}
+	}
+}
+
+func TestSMTPDelivery_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	d := &SMTPDelivery{Host: "127.0.0.1", Port: port, From: "a@example.com", To: []string{"b@example.com"}}
+	if err := d.Deliver(context.Background(), types.CakeAlert{}); err == nil || !strings.HasPrefix(err.Error(), "smtp: ") {
+		t.Errorf("want an smtp error, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+// Package buildinfo holds the version metadata of the running binary.  The
+// variables are stamped at build time, e.g.
+//
+//	go build -ldflags "-X github.com/galpt/cake-stats/pkg/buildinfo.Version=v1.2.3 \
+//	  -X github.com/galpt/cake-stats/pkg/buildinfo.Commit=abc1234 \
+//	  -X github.com/galpt/cake-stats/pkg/buildinfo.BuiltAt=2024-03-01T12:00:00Z" ./cmd/cake-stats
+//
+// Commit and BuiltAt left unset fall back to the VCS information the Go
+// toolchain embeds when building from a git checkout.
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	// Version is the release tag; "dev" for unstamped builds.
+	Version = "dev"
+	// Commit is the abbreviated git revision.
+	Commit = ""
+	// BuiltAt is the build time in RFC 3339.
+	BuiltAt = ""
+)
+
+func init() {
+	if Commit != "" && BuiltAt != "" {
+		return
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, s := range bi.Settings {
+		switch {
+		case s.Key == "vcs.revision" && Commit == "":
+			Commit = s.Value[:min(len(s.Value), 7)]
+		case s.Key == "vcs.time" && BuiltAt == "":
+			BuiltAt = s.Value
+		}
+	}
+}
+
+// GoVersion is the Go release the binary was built with.
+func GoVersion() string { return runtime.Version() }
+
+// String renders every field on its own line, for -version.
+func String() string {
+	return fmt.Sprintf("cake-stats %s\n  commit:   %s\n  built at: %s\n  go:       %s",
+		Version, orUnknown(Commit), orUnknown(BuiltAt), GoVersion())
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,150 @@
+// Package config reads the YAML configuration file given with -config.  Each
+// key mirrors a command-line flag; flags given on the command line take
+// precedence over the file.
+//
+//	host: 0.0.0.0
+//	port: 11112
+//	interval: 500ms
+//	history: 600
+//	log_level: info
+//	iface_filter: [wan, lan]
+//	exclude_iface: [ifb4wan]
+//	tls_cert: /etc/cake-stats/cert.pem
+//	tls_key: /etc/cake-stats/key.pem
+//	auth_user: admin
+//	auth_pass: secret
+//	webhook_url: https://example.com/hook
+//	interfaces:
+//	  wan:
+//	    history_capacity: 3000
+//	    drop_rate_threshold: 20
+//	    av_delay_ms_threshold: 10
+//	    pk_delay_ms_threshold: 30
+//	    utilization_threshold: 90
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// File is the parsed configuration file.  Zero values mean "not set".
+type File struct {
+	Host         string        `yaml:"host"`
+	Port         int           `yaml:"port"`
+	Interval     time.Duration `yaml:"interval"`
+	History      int           `yaml:"history"`
+	LogLevel     string        `yaml:"log_level"`
+	IfaceFilter  []string      `yaml:"iface_filter"`
+	ExcludeIface []string      `yaml:"exclude_iface"`
+	TLSCert      string        `yaml:"tls_cert"`
+	TLSKey       string        `yaml:"tls_key"`
+	AuthUser     string        `yaml:"auth_user"`
+	AuthPass     string        `yaml:"auth_pass"`
+	WebhookURL   string        `yaml:"webhook_url"`
+	// Interfaces holds per-interface settings keyed by interface name.
+	Interfaces map[string]Interface `yaml:"interfaces"`
+}
+
+// Interface is the per-interface section of File.
+type Interface struct {
+	// History overrides the top-level history capacity for this interface.
+	History int `yaml:"history_capacity"`
+	// The thresholds override -alert-drop-rate, -alert-av-delay,
+	// -alert-pk-delay and -alert-utilization for this interface, and alert on
+	// it even when the flag is off.
+	DropRateThreshold    float64 `yaml:"drop_rate_threshold"`
+	AvDelayMsThreshold   float64 `yaml:"av_delay_ms_threshold"`
+	PkDelayMsThreshold   float64 `yaml:"pk_delay_ms_threshold"`
+	UtilizationThreshold float64 `yaml:"utilization_threshold"`
+}
+
+// Load reads and parses the file at path.  Unknown keys are an error, so a
+// misspelt key is not silently ignored.
+func Load(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+	for name, iface := range f.Interfaces {
+		if iface.History < 0 {
+			return nil, fmt.Errorf("config: %s: interfaces.%s.history_capacity must not be negative", path, name)
+		}
+		if min(iface.DropRateThreshold, iface.AvDelayMsThreshold, iface.PkDelayMsThreshold, iface.UtilizationThreshold) < 0 {
+			return nil, fmt.Errorf("config: %s: interfaces.%s: alert thresholds must not be negative", path, name)
+		}
+	}
+	return &f, nil
+}
+
+// Apply sets every flag of fs that f gives a value for, except the flags in
+// given, which were set on the command line and keep their values.  Keys
+// whose flag is not defined in fs are skipped.
+func (f *File) Apply(fs *flag.FlagSet, given map[string]bool) error {
+	for _, kv := range []struct{ flag, value string }{
+		{"host", f.Host},
+		{"port", itoa(f.Port)},
+		{"interval", durationString(f.Interval)},
+		{"history", itoa(f.History)},
+		{"log-level", f.LogLevel},
+		{"iface", strings.Join(f.IfaceFilter, ",")},
+		{"exclude-iface", strings.Join(f.ExcludeIface, ",")},
+		{"tls-cert", f.TLSCert},
+		{"tls-key", f.TLSKey},
+		{"auth-user", f.AuthUser},
+		{"auth-pass", f.AuthPass},
+		{"webhook-url", f.WebhookURL},
+	} {
+		if kv.value == "" || given[kv.flag] || fs.Lookup(kv.flag) == nil {
+			continue
+		}
+		if err := fs.Set(kv.flag, kv.value); err != nil {
+			return fmt.Errorf("config: %s: %w", kv.flag, err)
+		}
+	}
+	return nil
+}
+
+// HistoryCapacities returns the per-interface history overrides.
+func (f *File) HistoryCapacities() map[string]int {
+	var out map[string]int
+	for name, iface := range f.Interfaces {
+		if iface.History == 0 {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]int)
+		}
+		out[name] = iface.History
+	}
+	return out
+}
+
+func itoa(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func durationString(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	f, err := Load("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &File{
+		Host:         "192.168.1.1",
+		Port:         8080,
+		Interval:     500 * time.Millisecond,
+		History:      600,
+		LogLevel:     "warn",
+		IfaceFilter:  []string{"wan", "lan"},
+		ExcludeIface: []string{"ifb4wan"},
+		TLSCert:      "/etc/cake-stats/cert.pem",
+		TLSKey:       "/etc/cake-stats/key.pem",
+		AuthUser:     "admin",
+		AuthPass:     "secret",
+		WebhookURL:   "https://example.com/hook",
+		Interfaces: map[string]Interface{
+			"wan": {History: 3000, AvDelayMsThreshold: 10, UtilizationThreshold: 90},
+			"lan": {History: 100},
+		},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("got  %+v\nwant %+v", f, want)
+	}
+	if got := f.HistoryCapacities(); !reflect.DeepEqual(got, map[string]int{"wan": 3000, "lan": 100}) {
+		t.Errorf("HistoryCapacities: got %v", got)
+	}
+}
+
+func TestLoad_UnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(path, []byte("prot: 80\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("want error for unknown key")
+	}
+}
+
+func TestApply_FlagsTakePrecedence(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	host := fs.String("host", "0.0.0.0", "")
+	port := fs.Int("port", 11112, "")
+	interval := fs.Duration("interval", 100*time.Millisecond, "")
+	iface := fs.String("iface", "", "")
+	if err := fs.Parse([]string{"-port", "9000"}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Load("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Apply(fs, map[string]bool{"port": true}); err != nil {
+		t.Fatal(err)
+	}
+	if *host != "192.168.1.1" || *interval != 500*time.Millisecond || *iface != "wan,lan" {
+		t.Errorf("file values not applied: host %q interval %v iface %q", *host, *interval, *iface)
+	}
+	if *port != 9000 {
+		t.Errorf("command-line -port overridden: got %d", *port)
+	}
+}
@@ -1,9 +1,22 @@
 package history
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	bolt "go.etcd.io/bbolt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/galpt/cake-stats/pkg/log"
 	"github.com/galpt/cake-stats/pkg/types"
 	"github.com/galpt/cake-stats/pkg/util"
 )
@@ -12,18 +25,57 @@ import (
 type ifaceState struct {
 	prevTxBytes uint64
 	prevDropped uint64
+	prevWayMiss uint64
+	prevWayInds uint64
+	prevWayCols uint64
+	prevRequeue uint64
+	prevMarks   uint64
+	prevAckDrop uint64
 	prevTime    time.Time
-	samples     []types.HistorySample
-	head        int
-	count       int
+	// clockBehind is set when the last poll read the clock earlier than
+	// prevTime; see recordIface.
+	clockBehind bool
+	// prevEMATx is the smoothed Tx of the last sample; emaSeeded is false
+	// until the first sample, which seeds the average with its raw rate.
+	prevEMATx float64
+	emaSeeded bool
+	// restored is set for interfaces loaded by OpenDB: the ring holds
+	// samples but there are no counters to diff against yet.
+	restored bool
+	ring     ringBuffer[types.HistorySample]
+	// tiers holds one history per tin, in the order tc reports them.  It is
+	// rebuilt when the tin names change, e.g. after a diffserv mode switch.
+	tiers []*tierState
+	// minute holds one sample per completed minute, built by minuteAgg;
+	// nil until the first minute completes.
+	minute    ringBuffer[types.HistorySample]
+	minuteAgg minuteAgg
+	// peaks tracks the highest raw values since peaks.Since; see
+	// PeakSnapshot.
+	peaks types.PeakStats
+}
+
+// tierState tracks per-tier counters and the tier's ring buffer.
+type tierState struct {
+	name      string
+	prevBytes uint64
+	prevDrops uint64
+	ring      ringBuffer[types.TierHistorySample]
 }
 
-func newIfaceState(capacity int, cs *types.CakeStats) *ifaceState {
+func newIfaceState(ring ringBuffer[types.HistorySample], cs *types.CakeStats, now time.Time) *ifaceState {
 	return &ifaceState{
 		prevTxBytes: txBytes(cs),
 		prevDropped: cs.Dropped,
-		prevTime:    time.Now(),
-		samples:     make([]types.HistorySample, capacity),
+		prevWayMiss: tierSum(cs.Tiers, func(t types.CakeTier) uint64 { return t.WayMiss }),
+		prevWayInds: tierSum(cs.Tiers, func(t types.CakeTier) uint64 { return t.WayInds }),
+		prevWayCols: tierSum(cs.Tiers, func(t types.CakeTier) uint64 { return t.WayCols }),
+		prevRequeue: cs.Requeues,
+		prevMarks:   tierSum(cs.Tiers, func(t types.CakeTier) uint64 { return t.Marks }),
+		prevAckDrop: tierSum(cs.Tiers, func(t types.CakeTier) uint64 { return t.AckDrop }),
+		prevTime:    now,
+		ring:        ring,
+		peaks:       types.PeakStats{Since: now},
 	}
 }
 
@@ -38,112 +90,905 @@ func txBytes(cs *types.CakeStats) uint64 {
 	return sum
 }
 
-func (st *ifaceState) push(s types.HistorySample, capacity int) {
-	st.samples[st.head] = s
-	st.head = (st.head + 1) % capacity
-	if st.count < capacity {
-		st.count++
-	}
-}
-
-func (st *ifaceState) ordered(capacity int) []types.HistorySample {
-	if st.count == 0 {
-		return nil
-	}
-	out := make([]types.HistorySample, st.count)
-	if st.count < capacity {
-		copy(out, st.samples[:st.count])
-	} else {
-		n := copy(out, st.samples[st.head:])
-		copy(out[n:], st.samples[:st.head])
-	}
-	return out
-}
+// defaultDeadIfaceTTL is the number of consecutive polls an interface may be
+// absent from before its state is pruned.
+const defaultDeadIfaceTTL = 3
 
 // HistoryStore is a thread-safe collection of per-interface ring buffers.
 type HistoryStore struct {
 	mu       sync.RWMutex
 	ifaces   map[string]*ifaceState
 	capacity int
+	// missedPolls counts consecutive Record calls an interface was absent
+	// from; it is pruned once the count reaches deadIfaceTTL.
+	missedPolls  map[string]int
+	deadIfaceTTL int
+	// now is the clock used by Record; see SetClock.
+	now func() time.Time
+	// lastRecord is the clock reading of the latest Record call.
+	lastRecord time.Time
+	// precision selects which timestamp fields samples carry; see
+	// SetPrecision.
+	precision time.Duration
+	// tracer, when non-nil, wraps Record in OpenTelemetry spans.
+	tracer trace.Tracer
+	// pruneOnEmpty makes an empty stats slice count as a missed poll for
+	// every interface; see SetPruneOnEmpty.  Atomic so Record can check it
+	// before taking the lock.
+	pruneOnEmpty atomic.Bool
+	// lazyRing selects lazyRing over eagerRing for new interfaces; see
+	// SetLazyRing.
+	lazyRing bool
+	// tierCapacity is the per-tier ring size; 0 means capacity.  See
+	// SetTierCapacity.
+	tierCapacity int
+	// emaAlpha is the smoothing factor applied to HistorySample.Tx; see
+	// SetEMAAlpha.
+	emaAlpha float64
+	// db, when set by OpenDB, receives every appended sample.  Record
+	// queues them in pending; flushDB writes them every dbFlushInterval
+	// and on CloseDB.  dbMu serializes those writes with Reset's bucket
+	// deletion and is taken before mu.
+	db         *bolt.DB
+	pending    []persisted
+	dbMu       sync.Mutex
+	dbFlushing chan struct{}
+	dbFlushed  chan struct{}
+	// dbFlushInterval overrides DBFlushInterval in tests.
+	dbFlushInterval time.Duration
+	// capacities holds per-interface overrides of capacity; see SetCapacity.
+	capacities map[string]int
+	// minuteCapacity is the size of the per-minute rings; see
+	// SetMinuteCapacity.
+	minuteCapacity int
+	// wrapThreshold bounds the counter increase taken for a 32-bit wrap;
+	// see SetWrapThreshold.
+	wrapThreshold uint64
+	// appearHook and disappearHook are called after Record for interfaces
+	// it added or pruned; see SetAppearHook and SetDisappearHook.
+	appearHook    func(iface string)
+	disappearHook func(iface string)
 }
 
+// DefaultEMAAlpha is the Tx smoothing factor of a new HistoryStore.
+const DefaultEMAAlpha = 0.2
+
+// DefaultWrapThreshold is the wrap threshold of a new HistoryStore: half the
+// range of a 32-bit kernel counter.
+const DefaultWrapThreshold = 1 << 31
+
 func NewHistoryStore(capacity int) *HistoryStore {
 	if capacity < 2 {
 		capacity = 2
 	}
-	return &HistoryStore{
-		ifaces:   make(map[string]*ifaceState),
-		capacity: capacity,
+	hs := &HistoryStore{
+		ifaces:         make(map[string]*ifaceState),
+		capacity:       capacity,
+		missedPolls:    make(map[string]int),
+		deadIfaceTTL:   defaultDeadIfaceTTL,
+		now:            time.Now,
+		precision:      time.Second,
+		emaAlpha:       DefaultEMAAlpha,
+		minuteCapacity: DefaultMinuteCapacity,
+		wrapThreshold:  DefaultWrapThreshold,
 	}
+	hs.pruneOnEmpty.Store(true)
+	return hs
 }
 
-func (hs *HistoryStore) Record(stats []types.CakeStats, interval time.Duration) {
-	now := time.Now()
+// SetLazyRing chooses the ring buffer used for interfaces discovered from now
+// on.  By default each interface allocates its full capacity up front; with
+// lazy set the buffer grows as samples arrive, which saves memory when the
+// capacity is very large (say 100,000 samples) and interfaces come and go.
+// Existing interfaces keep their current buffer.
+func (hs *HistoryStore) SetLazyRing(lazy bool) {
+	hs.mu.Lock()
+	hs.lazyRing = lazy
+	hs.mu.Unlock()
+}
+
+// SetEMAAlpha sets the weight of the newest rate in the exponential moving
+// average stored as HistorySample.Tx: ema = alpha*instant + (1-alpha)*prev.
+// At 100 ms polls the raw rate jitters too much to graph; smaller values
+// smooth harder.  1 disables smoothing; values outside (0, 1] restore
+// DefaultEMAAlpha.  CakeStats.TxBytesPerS always carries the raw rate.
+func (hs *HistoryStore) SetEMAAlpha(alpha float64) {
+	if !(alpha > 0 && alpha <= 1) {
+		alpha = DefaultEMAAlpha
+	}
+	hs.mu.Lock()
+	hs.emaAlpha = alpha
+	hs.mu.Unlock()
+}
+
+// SetAppearHook sets a function called with the name of every interface
+// Record starts tracking: on its first poll, and again after it was pruned
+// or Reset.  Record calls it after releasing the store's lock.
+func (hs *HistoryStore) SetAppearHook(hook func(iface string)) {
+	hs.mu.Lock()
+	hs.appearHook = hook
+	hs.mu.Unlock()
+}
+
+// SetDisappearHook sets a function called with the name of every interface
+// Record prunes for having been absent for deadIfaceTTL polls.  Record calls
+// it after releasing the store's lock.
+func (hs *HistoryStore) SetDisappearHook(hook func(iface string)) {
+	hs.mu.Lock()
+	hs.disappearHook = hook
+	hs.mu.Unlock()
+}
+
+// SetWrapThreshold sets how a counter that went backwards is read.  On
+// 32-bit routers tc reports 32-bit kernel counters, which wrap at 2^32 (4 GiB
+// for bytes).  If the counter was below 2^32 and would have reached its new
+// value by wrapping with an increase below threshold, the increase is taken
+// as the delta; otherwise the counter is treated as reset and the rate is 0.
+// 1 disables wrap detection; 0 restores DefaultWrapThreshold.
+func (hs *HistoryStore) SetWrapThreshold(threshold uint64) {
+	if threshold == 0 {
+		threshold = DefaultWrapThreshold
+	}
+	hs.mu.Lock()
+	hs.wrapThreshold = threshold
+	hs.mu.Unlock()
+}
+
+// SetTierCapacity sets how many samples each per-tier history keeps.  Values
+// below 2 restore the default, which is the store capacity.  Like
+// SetLazyRing it only affects tiers created from now on.
+func (hs *HistoryStore) SetTierCapacity(capacity int) {
+	if capacity < 2 {
+		capacity = 0
+	}
+	hs.mu.Lock()
+	hs.tierCapacity = capacity
+	hs.mu.Unlock()
+}
+
+// SetCapacity overrides the number of samples kept for iface, e.g. a long
+// window for the WAN link and a short one for a flapping lab interface.  An
+// existing ring is resized on the interface's next Record: growing keeps
+// every sample, shrinking keeps the newest, both in order.  A capacity below
+// 2 removes the override.
+func (hs *HistoryStore) SetCapacity(iface string, capacity int) {
 	hs.mu.Lock()
 	defer hs.mu.Unlock()
+	if capacity < 2 {
+		delete(hs.capacities, iface)
+		return
+	}
+	if hs.capacities == nil {
+		hs.capacities = make(map[string]int)
+	}
+	hs.capacities[iface] = capacity
+}
+
+// SetDefaultCapacity changes the ring size of interfaces without a
+// SetCapacity override.  Like SetCapacity, existing rings are resized on
+// their interface's next Record.
+func (hs *HistoryStore) SetDefaultCapacity(capacity int) {
+	if capacity < 2 {
+		capacity = 2
+	}
+	hs.mu.Lock()
+	hs.capacity = capacity
+	hs.mu.Unlock()
+}
+
+// capacityFor returns the ring capacity for iface.  Callers must hold hs.mu.
+func (hs *HistoryStore) capacityFor(iface string) int {
+	if c, ok := hs.capacities[iface]; ok {
+		return c
+	}
+	return hs.capacity
+}
+
+// newRing returns an empty ring buffer sized for iface.  Callers must hold
+// hs.mu.
+func (hs *HistoryStore) newRing(iface string) ringBuffer[types.HistorySample] {
+	return newRingOf[types.HistorySample](hs.lazyRing, hs.capacityFor(iface))
+}
 
+// newTierStates returns a fresh, empty history for each of tiers, baselined
+// on their current counters.  Callers must hold hs.mu.
+func (hs *HistoryStore) newTierStates(tiers []types.CakeTier) []*tierState {
+	capacity := hs.tierCapacity
+	if capacity == 0 {
+		capacity = hs.capacity
+	}
+	out := make([]*tierState, len(tiers))
+	for i, t := range tiers {
+		out[i] = &tierState{
+			name:      t.Name,
+			prevBytes: t.Bytes,
+			prevDrops: t.Drops,
+			ring:      newRingOf[types.TierHistorySample](hs.lazyRing, capacity),
+		}
+	}
+	return out
+}
+
+func newRingOf[T any](lazy bool, capacity int) ringBuffer[T] {
+	if lazy {
+		return newLazyRing[T](capacity)
+	}
+	return newEagerRing[T](capacity)
+}
+
+// SetPruneOnEmpty controls what Record does with an empty stats slice.  When
+// true (the default) an empty poll is a valid "no CAKE qdiscs" result and
+// counts towards pruning every interface.  When false Record returns
+// immediately without taking the lock, which suits setups where several
+// pollers share one store and an empty slice only means "nothing from me".
+func (hs *HistoryStore) SetPruneOnEmpty(prune bool) {
+	hs.pruneOnEmpty.Store(prune)
+}
+
+// SetPrecision chooses the timestamp resolution of new samples.  At the
+// default time.Second only T is set; time.Millisecond additionally fills TMs
+// and time.Microsecond fills TUs, so samples taken within the same second at
+// sub-second poll intervals stay distinguishable.  Any other value is
+// treated as time.Second.
+func (hs *HistoryStore) SetPrecision(p time.Duration) {
+	if p != time.Millisecond && p != time.Microsecond {
+		p = time.Second
+	}
+	hs.mu.Lock()
+	hs.precision = p
+	hs.mu.Unlock()
+}
+
+// LastSampleTime is LastRecorded truncated to the store precision: the
+// instant SampleTime reports for the samples of the latest Record call.
+func (hs *HistoryStore) LastSampleTime() time.Time {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	if hs.precision == time.Millisecond || hs.precision == time.Microsecond {
+		return hs.lastRecord.Truncate(hs.precision)
+	}
+	return hs.lastRecord.Truncate(time.Second)
+}
+
+// SetTracer enables OpenTelemetry spans around Record: one "history.Record"
+// span per call and a "history.Record.iface" child per interface.  Passing
+// nil disables tracing.  Record checks the field without locking, so call
+// SetTracer before the store is shared with the poller.
+func (hs *HistoryStore) SetTracer(t trace.Tracer) {
+	hs.tracer = t
+}
+
+// SetClock replaces time.Now as the source of sample timestamps.
+func (hs *HistoryStore) SetClock(now func() time.Time) {
+	hs.mu.Lock()
+	hs.now = now
+	hs.mu.Unlock()
+}
+
+// LastRecorded returns the timestamp given to the samples of the latest
+// Record call, or the zero time before the first one.
+func (hs *HistoryStore) LastRecorded() time.Time {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	return hs.lastRecord
+}
+
+// stamp fills the timestamp fields of s according to the store precision.
+// Callers must hold hs.mu.
+func (hs *HistoryStore) stamp(s *types.HistorySample, now time.Time) {
+	switch hs.precision {
+	case time.Millisecond:
+		s.TMs = now.UnixMilli()
+		s.T = s.TMs / 1000
+	case time.Microsecond:
+		s.TUs = now.UnixMicro()
+		s.T = s.TUs / 1_000_000
+	default:
+		s.T = now.Unix()
+	}
+}
+
+// SetDeadIfaceTTL sets how many consecutive polls an interface may be missing
+// before its history is discarded.  Values below 1 are clamped to 1, which
+// restores the old prune-on-first-miss behaviour.
+func (hs *HistoryStore) SetDeadIfaceTTL(polls int) {
+	if polls < 1 {
+		polls = 1
+	}
+	hs.mu.Lock()
+	hs.deadIfaceTTL = polls
+	hs.mu.Unlock()
+}
+
+func (hs *HistoryStore) Record(stats []types.CakeStats, interval time.Duration) {
+	if len(stats) == 0 && !hs.pruneOnEmpty.Load() {
+		return
+	}
+	var (
+		ctx   context.Context
+		span  trace.Span
+		start time.Time
+	)
+	if hs.tracer != nil {
+		start = time.Now()
+		ctx, span = hs.tracer.Start(context.Background(), "history.Record",
+			trace.WithAttributes(attribute.Int("iface_count", len(stats))))
+		defer func() {
+			span.SetAttributes(attribute.Float64("elapsed_ms", float64(time.Since(start))/float64(time.Millisecond)))
+			span.End()
+		}()
+	}
+
+	now := hs.now()
+	hs.mu.Lock()
+	hs.lastRecord = now
+	db := hs.db
+	appearHook, disappearHook := hs.appearHook, hs.disappearHook
+	var appeared []string
 	for i := range stats {
-		cs := &stats[i]
-		key := cs.Interface
-		st, exists := hs.ifaces[key]
-		if !exists {
-			hs.ifaces[key] = newIfaceState(hs.capacity, cs)
-			continue
+		var sub trace.Span
+		if hs.tracer != nil {
+			_, sub = hs.tracer.Start(ctx, "history.Record.iface",
+				trace.WithAttributes(attribute.String("iface", stats[i].Interface)))
+		}
+		if _, known := hs.ifaces[stats[i].Interface]; !known && appearHook != nil {
+			appeared = append(appeared, stats[i].Interface)
+		}
+		sample, ok := hs.recordIface(&stats[i], now, interval)
+		if ok && db != nil {
+			iface := stats[i].Interface
+			hs.pending = append(hs.pending, persisted{iface, sample, hs.capacityFor(iface)})
+		}
+		if sub != nil {
+			sub.End()
 		}
-		elapsed := now.Sub(st.prevTime).Seconds()
-		if elapsed <= 0 {
-			elapsed = interval.Seconds()
-		}
-		currTx := txBytes(cs)
-		var txRate float64
-		if currTx >= st.prevTxBytes {
-			txRate = float64(currTx-st.prevTxBytes) / elapsed
-		}
-		var drRate float64
-		if cs.Dropped >= st.prevDropped {
-			drRate = float64(cs.Dropped-st.prevDropped) / elapsed
-		}
-		avMs := maxDelayMs(cs.Tiers, func(t types.CakeTier) string { return t.AvDelay })
-		pkMs := maxDelayMs(cs.Tiers, func(t types.CakeTier) string { return t.PkDelay })
-		cs.TxBytesPerS = txRate
-		cs.DropsPerS = drRate
-		cs.MaxAvDelayMs = avMs
-		cs.MaxPkDelayMs = pkMs
-		st.push(types.HistorySample{
-			T:  now.Unix(),
-			Tx: txRate,
-			Av: avMs,
-			Pk: pkMs,
-			Dr: drRate,
-		}, hs.capacity)
-		st.prevTxBytes = currTx
-		st.prevDropped = cs.Dropped
-		st.prevTime = now
 	}
+	pruned := hs.prune(stats)
+	hs.mu.Unlock()
 
+	for _, iface := range appeared {
+		appearHook(iface)
+	}
+	if disappearHook != nil {
+		for _, iface := range pruned {
+			disappearHook(iface)
+		}
+	}
+}
+
+// prune counts a missed poll for every interface absent from stats and
+// drops those gone for deadIfaceTTL polls, which it returns in sorted order.
+// Callers must hold hs.mu.
+func (hs *HistoryStore) prune(stats []types.CakeStats) []string {
+	var pruned []string
 	active := make(map[string]struct{}, len(stats))
 	for _, cs := range stats {
 		active[cs.Interface] = struct{}{}
 	}
 	for key := range hs.ifaces {
-		if _, ok := active[key]; !ok {
+		if _, ok := active[key]; ok {
+			delete(hs.missedPolls, key)
+			continue
+		}
+		// A single failed or partial poll must not wipe history; only prune
+		// once the interface has been gone for deadIfaceTTL polls in a row.
+		hs.missedPolls[key]++
+		if hs.missedPolls[key] >= hs.deadIfaceTTL {
 			delete(hs.ifaces, key)
+			delete(hs.missedPolls, key)
+			pruned = append(pruned, key)
+		}
+	}
+	sort.Strings(pruned)
+	return pruned
+}
+
+// recordIface computes rates for one interface against its previous counters,
+// writes them back onto cs and appends a sample, which it returns.  ok is
+// false when the poll only set a baseline.  Callers must hold hs.mu.
+func (hs *HistoryStore) recordIface(cs *types.CakeStats, now time.Time, interval time.Duration) (sample types.HistorySample, ok bool) {
+	cs.MemoryPct = memoryPct(cs.MemoryUsed, cs.MemoryTotal)
+	key := cs.Interface
+	st, exists := hs.ifaces[key]
+	if exists {
+		if c := hs.capacityFor(key); st.ring.capacity() != c {
+			st.ring = resizeRing(st.ring, hs.lazyRing, c)
+		}
+	}
+	if !exists || st.restored {
+		ring := hs.newRing(key)
+		if exists {
+			ring = st.ring
+		}
+		st = newIfaceState(ring, cs, now)
+		st.tiers = hs.newTierStates(cs.Tiers)
+		hs.ifaces[key] = st
+		return sample, false
+	}
+	// A clock stepped backwards (e.g. by ntpd) gives a negative elapsed.
+	// The rates then assume one interval, and prevTime keeps the pre-step
+	// time so a one-off bad reading does not skew the next poll.  Only if
+	// the clock is still behind on the next poll is the new time adopted.
+	elapsed := now.Sub(st.prevTime).Seconds()
+	behind, wasBehind := elapsed < 0, st.clockBehind
+	if behind && !wasBehind {
+		log.Logger.Warn().Str("iface", key).Time("prev", st.prevTime).Time("now", now).
+			Msg("history: clock stepped backwards")
+	}
+	st.clockBehind = behind
+	if elapsed <= 0 {
+		elapsed = interval.Seconds()
+	}
+	currTx := txBytes(cs)
+	txRate := float64(hs.counterDelta(st.prevTxBytes, currTx)) / elapsed
+	drRate := float64(hs.counterDelta(st.prevDropped, cs.Dropped)) / elapsed
+	currWm := tierSum(cs.Tiers, func(t types.CakeTier) uint64 { return t.WayMiss })
+	wmDelta := hs.counterDelta(st.prevWayMiss, currWm)
+	currWi := tierSum(cs.Tiers, func(t types.CakeTier) uint64 { return t.WayInds })
+	wiRate := float64(hs.counterDelta(st.prevWayInds, currWi)) / elapsed
+	currWc := tierSum(cs.Tiers, func(t types.CakeTier) uint64 { return t.WayCols })
+	wcRate := float64(hs.counterDelta(st.prevWayCols, currWc)) / elapsed
+	rqRate := float64(hs.counterDelta(st.prevRequeue, cs.Requeues)) / elapsed
+	currMk := tierSum(cs.Tiers, func(t types.CakeTier) uint64 { return t.Marks })
+	mkRate := float64(hs.counterDelta(st.prevMarks, currMk)) / elapsed
+	currAd := tierSum(cs.Tiers, func(t types.CakeTier) uint64 { return t.AckDrop })
+	adRate := float64(hs.counterDelta(st.prevAckDrop, currAd)) / elapsed
+	avMs := maxDelayMs(cs.Tiers, func(t types.CakeTier) string { return t.AvDelay })
+	pkMs := maxDelayMs(cs.Tiers, func(t types.CakeTier) string { return t.PkDelay })
+	spMs := maxDelayMs(cs.Tiers, func(t types.CakeTier) string { return t.SpDelay })
+	cs.TxBytesPerS = txRate
+	cs.UtilizationPct = utilizationPct(txRate, cs.BandwidthBps)
+	emaTx := txRate
+	if st.emaSeeded {
+		emaTx = hs.emaAlpha*txRate + (1-hs.emaAlpha)*st.prevEMATx
+	}
+	st.prevEMATx, st.emaSeeded = emaTx, true
+	cs.DropsPerS = drRate
+	cs.MarksPerS = mkRate
+	cs.AckDropPerS = adRate
+	cs.WayColsPerS = wcRate
+	cs.MaxAvDelayMs = avMs
+	cs.MaxPkDelayMs = pkMs
+	sample = types.HistorySample{
+		Tx:   emaTx,
+		Av:   avMs,
+		Pk:   pkMs,
+		Dr:   drRate,
+		Wm:   wmDelta,
+		Wi:   wiRate,
+		Sp:   spMs,
+		Rq:   rqRate,
+		Mk:   mkRate,
+		Ad:   adRate,
+		Fl:   float64(maxFlows(cs.Tiers)),
+		Util: cs.UtilizationPct,
+		Mem:  cs.MemoryPct,
+	}
+	hs.stamp(&sample, now)
+	st.ring.push(sample)
+	hs.promote(st, sample)
+	st.peaks.Tx = max(st.peaks.Tx, txRate)
+	st.peaks.Pk = max(st.peaks.Pk, pkMs)
+	st.peaks.Av = max(st.peaks.Av, avMs)
+	st.peaks.Dr = max(st.peaks.Dr, drRate)
+	hs.recordTiers(st, cs.Tiers, sample.T, elapsed)
+	st.prevTxBytes = currTx
+	st.prevDropped = cs.Dropped
+	st.prevWayMiss = currWm
+	st.prevWayInds = currWi
+	st.prevWayCols = currWc
+	st.prevRequeue = cs.Requeues
+	st.prevMarks = currMk
+	st.prevAckDrop = currAd
+	if !behind || wasBehind {
+		st.prevTime = now
+	}
+	return sample, true
+}
+
+// counterDelta returns how far a counter advanced from prev to curr.  A
+// counter that went backwards was reset and advanced 0, unless prev fits in
+// 32 bits and counting on through 2^32 reaches curr in fewer than
+// wrapThreshold steps: then a 32-bit kernel counter wrapped.  Callers must
+// hold hs.mu.
+func (hs *HistoryStore) counterDelta(prev, curr uint64) uint64 {
+	if curr >= prev {
+		return curr - prev
+	}
+	if prev <= math.MaxUint32 {
+		if d := curr + 1<<32 - prev; d < hs.wrapThreshold {
+			return d
+		}
+	}
+	return 0
+}
+
+// recordTiers appends one sample per tier, or rebuilds the tier histories
+// when the tins no longer match the previous poll.  Callers must hold hs.mu.
+func (hs *HistoryStore) recordTiers(st *ifaceState, tiers []types.CakeTier, t int64, elapsed float64) {
+	if !sameTiers(st.tiers, tiers) {
+		st.tiers = hs.newTierStates(tiers)
+		return
+	}
+	for i, tier := range tiers {
+		ts := st.tiers[i]
+		sample := types.TierHistorySample{
+			T:    t,
+			Name: tier.Name,
+			AvMs: util.ParseDelayMs(tier.AvDelay),
+			PkMs: util.ParseDelayMs(tier.PkDelay),
+		}
+		sample.TxBytesPerS = float64(hs.counterDelta(ts.prevBytes, tier.Bytes)) / elapsed
+		sample.DrRate = float64(hs.counterDelta(ts.prevDrops, tier.Drops)) / elapsed
+		ts.ring.push(sample)
+		ts.prevBytes = tier.Bytes
+		ts.prevDrops = tier.Drops
+	}
+}
+
+// sameTiers reports whether states were built for exactly these tiers.
+func sameTiers(states []*tierState, tiers []types.CakeTier) bool {
+	if len(states) != len(tiers) {
+		return false
+	}
+	for i, ts := range states {
+		if ts.name != tiers[i].Name {
+			return false
 		}
 	}
+	return true
 }
 
-func (hs *HistoryStore) Snapshot() types.HistoryResponse {
+// Snapshot copies both rings of every interface, oldest first.  The minute
+// in progress is not in Minute until it completes; interfaces without
+// samples in a ring are omitted from it.
+func (hs *HistoryStore) Snapshot() types.HistorySnapshot {
 	hs.mu.RLock()
 	defer hs.mu.RUnlock()
+	out := types.HistorySnapshot{
+		Samples: hs.samplesLocked(),
+		Minute:  make(types.HistoryResponse, len(hs.ifaces)),
+	}
+	for key, st := range hs.ifaces {
+		if st.minute == nil {
+			continue
+		}
+		if samples := st.minute.ordered(); len(samples) > 0 {
+			out.Minute[key] = samples
+		}
+	}
+	return out
+}
+
+// samplesLocked copies the full-resolution ring of every interface.  Callers
+// must hold hs.mu.
+func (hs *HistoryStore) samplesLocked() types.HistoryResponse {
 	out := make(types.HistoryResponse, len(hs.ifaces))
 	for key, st := range hs.ifaces {
-		if samples := st.ordered(hs.capacity); len(samples) > 0 {
+		if samples := st.ring.ordered(); len(samples) > 0 {
 			out[key] = samples
 		}
 	}
 	return out
 }
 
+// Since returns, per interface, the samples taken after t (unix
+// milliseconds, compared with SampleTime), oldest first.  Interfaces with no
+// such samples are omitted.
+func (hs *HistoryStore) Since(t int64) types.HistoryResponse {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	out := make(types.HistoryResponse)
+	for key, st := range hs.ifaces {
+		samples := st.ring.ordered()
+		i := sort.Search(len(samples), func(i int) bool { return SampleTime(samples[i]).UnixMilli() > t })
+		if i < len(samples) {
+			out[key] = samples[i:]
+		}
+	}
+	return out
+}
+
+// TierSnapshot returns every interface's per-tier history: samples grouped by
+// tier in the order tc reports them, oldest first within each tier.
+// Interfaces without tier samples are omitted.
+func (hs *HistoryStore) TierSnapshot() map[string][]types.TierHistorySample {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	out := make(types.TierHistoryResponse, len(hs.ifaces))
+	for key, st := range hs.ifaces {
+		var samples []types.TierHistorySample
+		for _, ts := range st.tiers {
+			samples = append(samples, ts.ring.ordered()...)
+		}
+		if len(samples) > 0 {
+			out[key] = samples
+		}
+	}
+	return out
+}
+
+// Samples returns iface's history, oldest first, and whether the interface
+// is known.  A known interface may have no samples yet.
+func (hs *HistoryStore) Samples(iface string) ([]types.HistorySample, bool) {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	st, ok := hs.ifaces[iface]
+	if !ok {
+		return nil, false
+	}
+	return st.ring.ordered(), true
+}
+
+// TierSamples is Samples for iface's per-tier history, grouped as in
+// TierSnapshot.
+func (hs *HistoryStore) TierSamples(iface string) ([]types.TierHistorySample, bool) {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	st, ok := hs.ifaces[iface]
+	if !ok {
+		return nil, false
+	}
+	var samples []types.TierHistorySample
+	for _, ts := range st.tiers {
+		samples = append(samples, ts.ring.ordered()...)
+	}
+	return samples, true
+}
+
+// PeakSnapshot returns each interface's peak values.  Peaks are updated by
+// Record, so reading them costs nothing per sample retained.  Interfaces
+// restored by OpenDB appear after their first poll.
+func (hs *HistoryStore) PeakSnapshot() map[string]types.PeakStats {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	out := make(map[string]types.PeakStats, len(hs.ifaces))
+	for key, st := range hs.ifaces {
+		if !st.restored {
+			out[key] = st.peaks
+		}
+	}
+	return out
+}
+
+// ResetPeaks zeroes the peaks of iface and restarts them from now.  Reports
+// whether the interface was known.
+func (hs *HistoryStore) ResetPeaks(iface string) bool {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	st, ok := hs.ifaces[iface]
+	if !ok || st.restored {
+		return false
+	}
+	st.peaks = types.PeakStats{Since: hs.now()}
+	return true
+}
+
+// SnapshotByNamespace is Snapshot restricted to interfaces recorded under the
+// "<ns>/" prefix, as written by per-namespace pollers.  The prefix is stripped
+// from the returned keys so each namespace reads like a standalone host.
+func (hs *HistoryStore) SnapshotByNamespace(ns string) map[string][]types.HistorySample {
+	prefix := ns + "/"
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	out := make(map[string][]types.HistorySample)
+	for key, st := range hs.ifaces {
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if samples := st.ring.ordered(); len(samples) > 0 {
+			out[name] = samples
+		}
+	}
+	return out
+}
+
+// Namespaces returns the sorted, unique namespace prefixes present in the
+// store.  Interfaces recorded without a "<ns>/" prefix are not counted.
+func (hs *HistoryStore) Namespaces() []string {
+	hs.mu.RLock()
+	seen := make(map[string]struct{})
+	for key := range hs.ifaces {
+		if ns, _, ok := strings.Cut(key, "/"); ok {
+			seen[ns] = struct{}{}
+		}
+	}
+	hs.mu.RUnlock()
+	out := make([]string, 0, len(seen))
+	for ns := range seen {
+		out = append(out, ns)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Reset discards all state for iface.  The next Record that includes it
+// starts a fresh baseline, exactly as for a newly discovered interface.
+// Reports whether the interface was known.
+func (hs *HistoryStore) Reset(iface string) bool {
+	hs.dbMu.Lock()
+	defer hs.dbMu.Unlock()
+	hs.mu.Lock()
+	_, ok := hs.ifaces[iface]
+	delete(hs.ifaces, iface)
+	delete(hs.missedPolls, iface)
+	hs.pending = slices.DeleteFunc(hs.pending, func(p persisted) bool { return p.iface == iface })
+	db := hs.db
+	hs.mu.Unlock()
+	if db != nil {
+		forget(db, iface)
+	}
+	return ok
+}
+
+// NearestSample returns the retained sample for iface whose timestamp is
+// closest to t.  Ties go to the earlier sample.  ok is false when the
+// interface is unknown or has no samples yet.
+func (hs *HistoryStore) NearestSample(iface string, t time.Time) (types.HistorySample, bool) {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	st, ok := hs.ifaces[iface]
+	if !ok || st.ring.len() == 0 {
+		return types.HistorySample{}, false
+	}
+	var best types.HistorySample
+	bestDist := time.Duration(math.MaxInt64)
+	for _, s := range st.ring.ordered() {
+		d := SampleTime(s).Sub(t)
+		if d < 0 {
+			d = -d
+		}
+		if d < bestDist {
+			best, bestDist = s, d
+		}
+	}
+	return best, true
+}
+
+// SampleTime returns the instant a sample was taken, using the finest
+// timestamp field it carries (TUs, then TMs, then T).
+func SampleTime(s types.HistorySample) time.Time {
+	switch {
+	case s.TUs != 0:
+		return time.UnixMicro(s.TUs)
+	case s.TMs != 0:
+		return time.UnixMilli(s.TMs)
+	default:
+		return time.Unix(s.T, 0)
+	}
+}
+
+// HistoryCheckpoint is an immutable, point-in-time copy of every interface's
+// ordered samples.  It holds no reference to the store's ring buffers, so
+// long-running consumers (exporters, large responses) can read it without
+// holding the store lock while Record keeps writing.
+type HistoryCheckpoint struct {
+	samples types.HistoryResponse
+	taken   time.Time
+}
+
+// Checkpoint deep-copies the current history under the read lock.
+func (hs *HistoryStore) Checkpoint() HistoryCheckpoint {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	return HistoryCheckpoint{samples: hs.samplesLocked(), taken: time.Now()}
+}
+
+// Samples returns the checkpointed samples for iface, oldest first, or nil if
+// the interface had no samples when the checkpoint was taken.
+func (cp HistoryCheckpoint) Samples(iface string) []types.HistorySample {
+	return cp.samples[iface]
+}
+
+// Interfaces returns the checkpointed interface names in sorted order.
+func (cp HistoryCheckpoint) Interfaces() []string {
+	names := make([]string, 0, len(cp.samples))
+	for k := range cp.samples {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Taken returns the time the checkpoint was created.
+func (cp HistoryCheckpoint) Taken() time.Time { return cp.taken }
+
+// Errors returned by the HistoryStore query methods.
+var (
+	ErrUnknownInterface    = errors.New("history: unknown interface")
+	ErrUnknownField        = errors.New("history: unknown sample field")
+	ErrInsufficientSamples = errors.New("history: not enough samples")
+	ErrInvalidPercentile   = errors.New("history: percentile must be within [0, 100]")
+)
+
+// sampleFields maps the field names accepted by Percentile to accessors.
+var sampleFields = map[string]func(types.HistorySample) float64{
+	"tx": func(s types.HistorySample) float64 { return s.Tx },
+	"av": func(s types.HistorySample) float64 { return s.Av },
+	"pk": func(s types.HistorySample) float64 { return s.Pk },
+	"sp": func(s types.HistorySample) float64 { return s.Sp },
+	"dr": func(s types.HistorySample) float64 { return s.Dr },
+	"rq": func(s types.HistorySample) float64 { return s.Rq },
+	"wi": func(s types.HistorySample) float64 { return s.Wi },
+}
+
+// Percentile returns the p-th percentile (0–100) of a HistorySample field
+// over the interface's retained samples.  field is the sample's JSON name:
+// "av", "pk" or "sp" for delays (milliseconds), or one of the rates "tx",
+// "dr", "rq" and "wi".  Fractional ranks are linearly interpolated between
+// the two neighbouring values, so p=0 is the minimum and p=100 the maximum.
+// At least two samples are required; otherwise NaN and
+// ErrInsufficientSamples are returned.
+func (hs *HistoryStore) Percentile(iface, field string, p float64) (float64, error) {
+	get, ok := sampleFields[field]
+	if !ok {
+		return math.NaN(), fmt.Errorf("%w: %q", ErrUnknownField, field)
+	}
+	if p < 0 || p > 100 || math.IsNaN(p) {
+		return math.NaN(), ErrInvalidPercentile
+	}
+	hs.mu.RLock()
+	st, ok := hs.ifaces[iface]
+	var samples []types.HistorySample
+	if ok {
+		samples = st.ring.ordered()
+	}
+	hs.mu.RUnlock()
+	if !ok {
+		return math.NaN(), fmt.Errorf("%w: %q", ErrUnknownInterface, iface)
+	}
+	if len(samples) < 2 {
+		return math.NaN(), ErrInsufficientSamples
+	}
+	values := make([]float64, len(samples))
+	for i, smp := range samples {
+		values[i] = get(smp)
+	}
+	sort.Float64s(values)
+	return percentileSorted(values, p), nil
+}
+
+// PercentileDelay is Percentile restricted to the av and pk delays, as served
+// by /api/stats/percentile.
+func (hs *HistoryStore) PercentileDelay(iface, field string, pct float64) (float64, error) {
+	if field != "av" && field != "pk" {
+		return math.NaN(), fmt.Errorf("%w: %q", ErrUnknownField, field)
+	}
+	return hs.Percentile(iface, field, pct)
+}
+
+// percentileSorted interpolates the pct-th percentile of an ascending slice.
+func percentileSorted(sorted []float64, pct float64) float64 {
+	rank := pct / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	if lo == hi {
+		return sorted[lo]
+	}
+	frac := rank - float64(lo)
+	return sorted[lo] + (sorted[hi]-sorted[lo])*frac
+}
+
+// utilizationPct returns a byte rate as a percentage of a shaper rate in
+// bits per second, or 0 when the shaper has no fixed rate.
+func utilizationPct(bytesPerS float64, bandwidthBps uint64) float64 {
+	if bandwidthBps == 0 {
+		return 0
+	}
+	return bytesPerS * 8 / float64(bandwidthBps) * 100
+}
+
+// memoryPct is used as a percentage of total, both tc byte strings; 0 when
+// total is unknown.
+func memoryPct(used, total string) float64 {
+	t := util.ParseBytesStr(total)
+	if t == 0 {
+		return 0
+	}
+	return float64(util.ParseBytesStr(used)) * 100 / float64(t)
+}
+
 func maxDelayMs(tiers []types.CakeTier, field func(types.CakeTier) string) float64 {
 	var best float64
 	for _, t := range tiers {
@@ -153,3 +998,22 @@ func maxDelayMs(tiers []types.CakeTier, field func(types.CakeTier) string) float
 	}
 	return best
 }
+
+// maxFlows returns the largest active flow count (sparse + bulk +
+// unresponsive) of any tier.
+func maxFlows(tiers []types.CakeTier) uint64 {
+	var most uint64
+	for _, t := range tiers {
+		most = max(most, t.SpFlows+t.BkFlows+t.UnFlows)
+	}
+	return most
+}
+
+// tierSum adds up a per-tier counter across all tiers of an interface.
+func tierSum(tiers []types.CakeTier, field func(types.CakeTier) uint64) uint64 {
+	var sum uint64
+	for _, t := range tiers {
+		sum += field(t)
+	}
+	return sum
+}
@@ -1,9 +1,16 @@
 package history
 
 import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel/trace/noop"
+
 	"github.com/galpt/cake-stats/pkg/types"
 )
 
@@ -22,8 +29,585 @@ func TestHistorySnapshot(t *testing.T) {
 	// first record establishes state, no sample
 	store.Record(stats, time.Second)
 	store.Record(stats, time.Second)
-	snap := store.Snapshot()
+	snap := store.Snapshot().Samples
 	if _, ok := snap["eth0"]; !ok {
 		t.Fatal("expected snapshot for eth0")
 	}
 }
+
+func TestDeadIfaceTTL(t *testing.T) {
+	store := NewHistoryStore(3)
+	eth0 := []types.CakeStats{{Interface: "eth0"}}
+	other := []types.CakeStats{{Interface: "eth1"}}
+	store.Record(eth0, time.Second)
+	store.Record(eth0, time.Second)
+
+	store.Record(other, time.Second)
+	store.Record(other, time.Second)
+	if _, ok := store.Snapshot().Samples["eth0"]; !ok {
+		t.Fatal("eth0 should survive 2 missed polls")
+	}
+	store.Record(other, time.Second)
+	if _, ok := store.Snapshot().Samples["eth0"]; ok {
+		t.Fatal("eth0 should be pruned after 3 missed polls")
+	}
+}
+
+func TestAppearDisappearHooks(t *testing.T) {
+	store := NewHistoryStore(3)
+	var events []string
+	store.SetAppearHook(func(iface string) { events = append(events, "appear "+iface) })
+	store.SetDisappearHook(func(iface string) { events = append(events, "disappear "+iface) })
+	both := []types.CakeStats{{Interface: "eth0"}, {Interface: "eth1"}}
+	store.Record(both, time.Second)
+	store.Record(both, time.Second)
+	for range 3 {
+		store.Record(both[1:], time.Second)
+	}
+	store.Record(both, time.Second)
+	want := []string{"appear eth0", "appear eth1", "disappear eth0", "appear eth0"}
+	if strings.Join(events, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got %q, want %q", events, want)
+	}
+}
+
+func TestWayMissDelta(t *testing.T) {
+	store := NewHistoryStore(3)
+	store.Record([]types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{{WayMiss: 100}, {WayMiss: 50}}}}, time.Second)
+	store.Record([]types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{{WayMiss: 400}, {WayMiss: 250}}}}, time.Second)
+	samples := store.Snapshot().Samples["eth0"]
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(samples))
+	}
+	if samples[0].Wm != 500 {
+		t.Errorf("Wm: want 500, got %d", samples[0].Wm)
+	}
+}
+
+func TestCheckpointStableUnderWrites(t *testing.T) {
+	store := NewHistoryStore(50)
+	for i := 0; i < 10; i++ {
+		store.Record([]types.CakeStats{{Interface: "eth0", SentBytes: uint64(i * 1000)}}, time.Second)
+	}
+	cp := store.Checkpoint()
+	want := append([]types.HistorySample(nil), cp.Samples("eth0")...)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 10; i < 200; i++ {
+			store.Record([]types.CakeStats{{Interface: "eth0", SentBytes: uint64(i * 1000)}}, time.Second)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_ = store.Checkpoint().Samples("eth0")
+		}
+	}()
+	wg.Wait()
+
+	got := cp.Samples("eth0")
+	if len(got) != len(want) {
+		t.Fatalf("checkpoint length changed: %d -> %d", len(want), len(got))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("checkpoint sample %d changed: %+v -> %+v", i, want[i], got[i])
+		}
+	}
+	if names := cp.Interfaces(); len(names) != 1 || names[0] != "eth0" {
+		t.Errorf("Interfaces()=%v want [eth0]", names)
+	}
+}
+
+func TestWayIndsRate(t *testing.T) {
+	store := NewHistoryStore(3)
+	clock := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return clock }
+	store.Record([]types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{{WayInds: 200}, {WayInds: 300}}}}, time.Second)
+	clock = clock.Add(500 * time.Millisecond)
+	store.Record([]types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{{WayInds: 700}, {WayInds: 800}}}}, time.Second)
+	samples := store.Snapshot().Samples["eth0"]
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(samples))
+	}
+	if got := samples[0].Wi; math.Abs(got-2000) > 1e-6 {
+		t.Errorf("Wi: want 2000, got %v", got)
+	}
+}
+
+// recordDelays feeds one sample per av value into store for eth0.
+func recordDelays(store *HistoryStore, av []string) {
+	store.Record([]types.CakeStats{{Interface: "eth0"}}, time.Second)
+	for _, v := range av {
+		store.Record([]types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{{AvDelay: v, PkDelay: v}}}}, time.Second)
+	}
+}
+
+func TestPercentileDelay(t *testing.T) {
+	store := NewHistoryStore(10)
+	recordDelays(store, []string{"5ms", "1ms", "4ms", "2ms", "3ms"})
+	for _, tc := range []struct {
+		pct  float64
+		want float64
+	}{
+		{0, 1}, {100, 5}, {50, 3}, {25, 2}, {90, 4.6},
+	} {
+		got, err := store.PercentileDelay("eth0", "av", tc.pct)
+		if err != nil {
+			t.Fatalf("p%v: %v", tc.pct, err)
+		}
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("p%v: want %v, got %v", tc.pct, tc.want, got)
+		}
+	}
+	if _, err := store.PercentileDelay("eth0", "tx", 50); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("field tx: want ErrUnknownField, got %v", err)
+	}
+	if _, err := store.PercentileDelay("eth9", "av", 50); !errors.Is(err, ErrUnknownInterface) {
+		t.Errorf("eth9: want ErrUnknownInterface, got %v", err)
+	}
+}
+
+func TestPercentileDelay_SingleSample(t *testing.T) {
+	store := NewHistoryStore(10)
+	recordDelays(store, []string{"5ms"})
+	v, err := store.PercentileDelay("eth0", "pk", 50)
+	if !errors.Is(err, ErrInsufficientSamples) || !math.IsNaN(v) {
+		t.Errorf("want (NaN, ErrInsufficientSamples), got (%v, %v)", v, err)
+	}
+}
+
+func TestSetPrecision(t *testing.T) {
+	at := time.Unix(1700000000, 123456789)
+	for _, tc := range []struct {
+		p        time.Duration
+		wantTMs  int64
+		wantTUs  int64
+		wantJSON string
+	}{
+		{time.Second, 0, 0, ""},
+		{time.Millisecond, 1700000000123, 0, `"t_ms":1700000000123`},
+		{time.Microsecond, 0, 1700000000123456, `"t_us":1700000000123456`},
+	} {
+		store := NewHistoryStore(3)
+		store.SetPrecision(tc.p)
+		store.now = func() time.Time { return at }
+		store.Record([]types.CakeStats{{Interface: "eth0"}}, time.Second)
+		store.Record([]types.CakeStats{{Interface: "eth0"}}, time.Second)
+		smp := store.Snapshot().Samples["eth0"][0]
+		if smp.T != 1700000000 || smp.TMs != tc.wantTMs || smp.TUs != tc.wantTUs {
+			t.Errorf("%v: got T=%d TMs=%d TUs=%d", tc.p, smp.T, smp.TMs, smp.TUs)
+		}
+		b, _ := json.Marshal(smp)
+		if tc.wantJSON != "" && !strings.Contains(string(b), tc.wantJSON) {
+			t.Errorf("%v: JSON %s missing %s", tc.p, b, tc.wantJSON)
+		}
+		if tc.wantJSON == "" && (strings.Contains(string(b), "t_ms") || strings.Contains(string(b), "t_us")) {
+			t.Errorf("%v: JSON %s should carry only t", tc.p, b)
+		}
+	}
+}
+
+func TestRecordWithNoopTracer(t *testing.T) {
+	store := NewHistoryStore(3)
+	store.SetTracer(noop.NewTracerProvider().Tracer("test"))
+	stats := []types.CakeStats{{Interface: "eth0"}, {Interface: "eth1"}}
+	store.Record(stats, time.Second)
+	store.Record(stats, time.Second)
+	snap := store.Snapshot().Samples
+	if len(snap["eth0"]) != 1 || len(snap["eth1"]) != 1 {
+		t.Fatalf("traced Record should behave like untraced Record, got %v", snap)
+	}
+}
+
+func TestPruneOnEmpty(t *testing.T) {
+	for _, prune := range []bool{true, false} {
+		store := NewHistoryStore(3)
+		store.SetPruneOnEmpty(prune)
+		eth0 := []types.CakeStats{{Interface: "eth0"}}
+		store.Record(eth0, time.Second)
+		store.Record(eth0, time.Second)
+		for i := 0; i < defaultDeadIfaceTTL; i++ {
+			store.Record([]types.CakeStats{}, time.Second)
+		}
+		_, kept := store.Snapshot().Samples["eth0"]
+		if kept == prune {
+			t.Errorf("PruneOnEmpty=%v: eth0 kept=%v after %d empty polls", prune, kept, defaultDeadIfaceTTL)
+		}
+	}
+}
+
+func TestNearestSample(t *testing.T) {
+	store := NewHistoryStore(10)
+	clock := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return clock }
+	for i := 0; i < 4; i++ {
+		store.Record([]types.CakeStats{{Interface: "eth0", SentBytes: uint64(i) * 1000}}, time.Second)
+		clock = clock.Add(10 * time.Second)
+	}
+	// Samples exist at +10s, +20s, +30s.
+	for _, tc := range []struct{ at, want int64 }{
+		{1700000000, 1700000010}, {1700000019, 1700000020}, {1700000999, 1700000030},
+	} {
+		s, ok := store.NearestSample("eth0", time.Unix(tc.at, 0))
+		if !ok || s.T != tc.want {
+			t.Errorf("NearestSample(%d) = (%d, %v), want %d", tc.at, s.T, ok, tc.want)
+		}
+	}
+	if _, ok := store.NearestSample("eth9", clock); ok {
+		t.Error("unknown interface should report ok=false")
+	}
+}
+
+func TestSnapshotByNamespace(t *testing.T) {
+	store := NewHistoryStore(10)
+	for i := 0; i < 3; i++ {
+		store.Record([]types.CakeStats{
+			{Interface: "ns1/eth1", SentBytes: uint64(i) * 1000},
+			{Interface: "ns2/eth1", SentBytes: uint64(i) * 5000},
+			{Interface: "eth0", SentBytes: uint64(i)},
+		}, time.Second)
+	}
+
+	if got := store.Namespaces(); len(got) != 2 || got[0] != "ns1" || got[1] != "ns2" {
+		t.Fatalf("Namespaces: want [ns1 ns2], got %v", got)
+	}
+
+	ns1, ns2 := store.SnapshotByNamespace("ns1"), store.SnapshotByNamespace("ns2")
+	if len(ns1) != 1 || len(ns1["eth1"]) != 2 {
+		t.Fatalf("ns1: want eth1 with 2 samples, got %v", ns1)
+	}
+	if len(ns2) != 1 || len(ns2["eth1"]) != 2 {
+		t.Fatalf("ns2: want eth1 with 2 samples, got %v", ns2)
+	}
+	if ns1["eth1"][0].Tx == ns2["eth1"][0].Tx {
+		t.Error("namespaces with the same interface name must not share samples")
+	}
+	if got := store.SnapshotByNamespace("ns3"); len(got) != 0 {
+		t.Errorf("unknown namespace: want empty map, got %v", got)
+	}
+}
+
+func TestTierSnapshot(t *testing.T) {
+	store := NewHistoryStore(5)
+	store.SetTierCapacity(2)
+	clock := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return clock }
+	tiers := func(n uint64) []types.CakeTier {
+		return []types.CakeTier{
+			{Name: "Bulk", Bytes: 100 * n, Drops: n, AvDelay: "1ms", PkDelay: "2ms"},
+			{Name: "Voice", Bytes: 10 * n, AvDelay: "50us", PkDelay: "3ms"},
+		}
+	}
+	for n := uint64(1); n <= 4; n++ {
+		store.Record([]types.CakeStats{{Interface: "eth0", Tiers: tiers(n)}}, time.Second)
+		clock = clock.Add(time.Second)
+	}
+
+	samples := store.TierSnapshot()["eth0"]
+	if len(samples) != 4 {
+		t.Fatalf("want 2 tiers x 2 samples, got %d: %+v", len(samples), samples)
+	}
+	bulk, voice := samples[1], samples[3]
+	if bulk.Name != "Bulk" || voice.Name != "Voice" || samples[0].Name != "Bulk" {
+		t.Fatalf("samples not grouped by tier: %+v", samples)
+	}
+	if bulk.T != 1700000003 || bulk.TxBytesPerS != 100 || bulk.DrRate != 1 || bulk.AvMs != 1 || bulk.PkMs != 2 {
+		t.Errorf("Bulk: got %+v", bulk)
+	}
+	if voice.TxBytesPerS != 10 || voice.DrRate != 0 || voice.AvMs != 0.05 {
+		t.Errorf("Voice: got %+v", voice)
+	}
+
+	// A diffserv change renames the tins: tier history restarts.
+	store.Record([]types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{{Name: "Best Effort"}}}}, time.Second)
+	if got := store.TierSnapshot()["eth0"]; len(got) != 0 {
+		t.Errorf("after tin change want no tier samples, got %+v", got)
+	}
+}
+
+func TestSpDelayAndRequeueRate(t *testing.T) {
+	store := NewHistoryStore(3)
+	clock := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return clock }
+	store.Record([]types.CakeStats{{Interface: "eth0", Requeues: 10}}, time.Second)
+	clock = clock.Add(2 * time.Second)
+	store.Record([]types.CakeStats{{
+		Interface: "eth0",
+		Requeues:  30,
+		Tiers:     []types.CakeTier{{SpDelay: "800us"}, {SpDelay: "1.5ms"}},
+	}}, time.Second)
+	samples := store.Snapshot().Samples["eth0"]
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(samples))
+	}
+	if got := samples[0].Sp; math.Abs(got-1.5) > 1e-9 {
+		t.Errorf("Sp: want 1.5, got %v", got)
+	}
+	if got := samples[0].Rq; math.Abs(got-10) > 1e-9 {
+		t.Errorf("Rq: want 10, got %v", got)
+	}
+}
+
+func TestEMATx(t *testing.T) {
+	store := NewHistoryStore(5)
+	store.SetEMAAlpha(0.5)
+	clock := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return clock }
+	for _, sent := range []uint64{0, 100, 100, 500} {
+		store.Record([]types.CakeStats{{Interface: "eth0", SentBytes: sent}}, time.Second)
+		clock = clock.Add(time.Second)
+	}
+	// Raw rates 100, 0, 400: the first seeds the average.
+	want := []float64{100, 50, 225}
+	samples := store.Snapshot().Samples["eth0"]
+	if len(samples) != len(want) {
+		t.Fatalf("expected %d samples, got %d", len(want), len(samples))
+	}
+	for i, w := range want {
+		if math.Abs(samples[i].Tx-w) > 1e-9 {
+			t.Errorf("sample %d: Tx want %v, got %v", i, w, samples[i].Tx)
+		}
+	}
+
+	stats := []types.CakeStats{{Interface: "eth0", SentBytes: 1500}}
+	store.Record(stats, time.Second)
+	if stats[0].TxBytesPerS != 1000 {
+		t.Errorf("CakeStats.TxBytesPerS must stay raw: want 1000, got %v", stats[0].TxBytesPerS)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	store := NewHistoryStore(10)
+	store.Record([]types.CakeStats{{Interface: "eth0"}}, time.Second)
+	for _, sp := range []string{"7ms", "3ms", "9ms", "1ms", "5ms"} {
+		store.Record([]types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{{SpDelay: sp}}}}, time.Second)
+	}
+	for _, tc := range []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1}, {100, 9}, {50, 5},
+	} {
+		got, err := store.Percentile("eth0", "sp", tc.p)
+		if err != nil {
+			t.Fatalf("p%v: %v", tc.p, err)
+		}
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("p%v: want %v, got %v", tc.p, tc.want, got)
+		}
+	}
+	if _, err := store.Percentile("eth0", "bogus", 50); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("field bogus: want ErrUnknownField, got %v", err)
+	}
+	if _, err := store.Percentile("eth0", "sp", 101); !errors.Is(err, ErrInvalidPercentile) {
+		t.Errorf("p101: want ErrInvalidPercentile, got %v", err)
+	}
+}
+
+func TestPeaks(t *testing.T) {
+	store := NewHistoryStore(3)
+	clock := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return clock }
+	poll := func(sent, dropped uint64, pk string) {
+		store.Record([]types.CakeStats{{
+			Interface: "eth0", SentBytes: sent, Dropped: dropped,
+			Tiers: []types.CakeTier{{PkDelay: pk, AvDelay: "1ms"}},
+		}}, time.Second)
+		clock = clock.Add(time.Second)
+	}
+	poll(0, 0, "1ms")
+	poll(5000, 2, "8ms")
+	poll(6000, 2, "3ms")
+	// Five samples later the peak has left the 3-sample ring but is kept.
+	for i := 0; i < 5; i++ {
+		poll(6000, 2, "2ms")
+	}
+
+	peaks := store.PeakSnapshot()["eth0"]
+	want := types.PeakStats{Tx: 5000, Pk: 8, Av: 1, Dr: 2, Since: time.Unix(1700000000, 0)}
+	if peaks != want {
+		t.Errorf("peaks: want %+v, got %+v", want, peaks)
+	}
+
+	if !store.ResetPeaks("eth0") {
+		t.Fatal("ResetPeaks(eth0) reported unknown interface")
+	}
+	poll(6100, 2, "2ms")
+	peaks = store.PeakSnapshot()["eth0"]
+	if peaks.Tx != 100 || peaks.Pk != 2 || peaks.Dr != 0 || !peaks.Since.Equal(time.Unix(1700000008, 0)) {
+		t.Errorf("after reset: got %+v", peaks)
+	}
+	if store.ResetPeaks("eth9") {
+		t.Error("ResetPeaks(eth9) should report an unknown interface")
+	}
+}
+
+func TestUtilizationPct(t *testing.T) {
+	store := NewHistoryStore(3)
+	clock := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return clock }
+	store.Record([]types.CakeStats{
+		{Interface: "eth0", BandwidthBps: 50_000_000},
+		{Interface: "ifb4eth0"},
+	}, time.Second)
+	clock = clock.Add(time.Second)
+	// 25 Mbit/s = 3,125,000 bytes in one second.
+	stats := []types.CakeStats{
+		{Interface: "eth0", BandwidthBps: 50_000_000, SentBytes: 3_125_000},
+		{Interface: "ifb4eth0", SentBytes: 3_125_000}, // autorate-ingress: no fixed rate
+	}
+	store.Record(stats, time.Second)
+
+	if got := stats[0].UtilizationPct; math.Abs(got-50) > 1e-9 {
+		t.Errorf("eth0: want 50%%, got %v", got)
+	}
+	if got := store.Snapshot().Samples["eth0"][0].Util; math.Abs(got-50) > 1e-9 {
+		t.Errorf("eth0 sample: want 50%%, got %v", got)
+	}
+	if got := stats[1].UtilizationPct; got != 0 || math.IsNaN(got) {
+		t.Errorf("autorate: want 0, got %v", got)
+	}
+}
+
+func TestMemoryPct(t *testing.T) {
+	store := NewHistoryStore(3)
+	clock := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return clock }
+	stats := []types.CakeStats{
+		{Interface: "eth0", MemoryUsed: "1Mb", MemoryTotal: "4Mb"},
+		{Interface: "eth1", MemoryUsed: "238656b"},
+	}
+	store.Record(stats, time.Second)
+	if got := stats[0].MemoryPct; got != 25 {
+		t.Errorf("baseline poll: want 25%%, got %v", got)
+	}
+	clock = clock.Add(time.Second)
+	stats = []types.CakeStats{
+		{Interface: "eth0", MemoryUsed: "2Mb", MemoryTotal: "4Mb"},
+		{Interface: "eth1", MemoryUsed: "238656b"},
+	}
+	store.Record(stats, time.Second)
+
+	if got := store.Snapshot().Samples["eth0"][0].Mem; got != 50 {
+		t.Errorf("eth0 sample: want 50%%, got %v", got)
+	}
+	if got := stats[1].MemoryPct; got != 0 {
+		t.Errorf("no memory_total: want 0, got %v", got)
+	}
+}
+
+func TestMarksAndAckDropRates(t *testing.T) {
+	store := NewHistoryStore(3)
+	clock := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return clock }
+	store.Record([]types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{
+		{Marks: 10, AckDrop: 100}, {Marks: 5},
+	}}}, time.Second)
+	clock = clock.Add(2 * time.Second)
+	stats := []types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{
+		{Marks: 30, AckDrop: 160}, {Marks: 25},
+	}}}
+	store.Record(stats, time.Second)
+
+	if stats[0].MarksPerS != 20 || stats[0].AckDropPerS != 30 {
+		t.Errorf("CakeStats: want marks 20/s, ack drops 30/s, got %v, %v", stats[0].MarksPerS, stats[0].AckDropPerS)
+	}
+	samples := store.Snapshot().Samples["eth0"]
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(samples))
+	}
+	if samples[0].Mk != 20 || samples[0].Ad != 30 {
+		t.Errorf("sample: want Mk 20, Ad 30, got %v, %v", samples[0].Mk, samples[0].Ad)
+	}
+}
+
+func TestMaxFlowsSample(t *testing.T) {
+	store := NewHistoryStore(3)
+	tiers := []types.CakeTier{
+		{SpFlows: 1, BkFlows: 2},
+		{SpFlows: 4, BkFlows: 3, UnFlows: 1},
+	}
+	store.Record([]types.CakeStats{{Interface: "eth0", Tiers: tiers}}, time.Second)
+	store.Record([]types.CakeStats{{Interface: "eth0", Tiers: tiers}}, time.Second)
+	if got := store.Snapshot().Samples["eth0"][0].Fl; got != 8 {
+		t.Errorf("Fl: want 8, got %v", got)
+	}
+}
+
+func TestWayColsRate(t *testing.T) {
+	store := NewHistoryStore(3)
+	clock := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return clock }
+	store.Record([]types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{{WayCols: 0}}}}, time.Second)
+	clock = clock.Add(time.Second)
+	stats := []types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{{WayCols: 60}, {WayCols: 40}}}}
+	store.Record(stats, time.Second)
+	if got := stats[0].WayColsPerS; math.Abs(got-100) > 1e-9 {
+		t.Errorf("WayColsPerS: want 100, got %v", got)
+	}
+}
+
+func TestCounterWrap(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		threshold uint64
+		prev      uint64
+		curr      uint64
+		want      float64
+	}{
+		{"32-bit wrap", 0, 1<<32 - 1000, 500, 1500},
+		{"reset", 0, 3_000_000, 100, 0},
+		{"64-bit counter", 0, 1<<40 + 5, 100, 0},
+		{"wrap detection off", 1, 1<<32 - 1000, 500, 0},
+		{"increase above threshold", 1000, 1<<32 - 1000, 500, 0},
+	} {
+		store := NewHistoryStore(3)
+		store.SetWrapThreshold(tc.threshold)
+		clock := time.Unix(1700000000, 0)
+		store.now = func() time.Time { return clock }
+		store.Record([]types.CakeStats{{Interface: "eth0", SentBytes: tc.prev, Dropped: tc.prev}}, time.Second)
+		clock = clock.Add(time.Second)
+		stats := []types.CakeStats{{Interface: "eth0", SentBytes: tc.curr, Dropped: tc.curr}}
+		store.Record(stats, time.Second)
+		if stats[0].TxBytesPerS != tc.want || stats[0].DropsPerS != tc.want {
+			t.Errorf("%s: want %v, got tx %v drops %v", tc.name, tc.want, stats[0].TxBytesPerS, stats[0].DropsPerS)
+		}
+	}
+}
+
+func TestClockStepBackwards(t *testing.T) {
+	store := NewHistoryStore(10)
+	base := time.Unix(1700000000, 0)
+	var clock time.Time
+	store.now = func() time.Time { return clock }
+	var sent uint64
+	record := func(at time.Duration) float64 {
+		clock = base.Add(at)
+		sent += 1000
+		stats := []types.CakeStats{{Interface: "eth0", SentBytes: sent}}
+		store.Record(stats, time.Second)
+		return stats[0].TxBytesPerS
+	}
+	record(0)
+	record(time.Second)
+	// One reading 30s in the past: the rate assumes one interval, and the
+	// next poll still measures from t=1s.
+	if got := record(-29 * time.Second); got != 1000 {
+		t.Errorf("stepped-back poll: want 1000 B/s, got %v", got)
+	}
+	if got := record(3 * time.Second); got != 500 {
+		t.Errorf("poll after a one-off step: want 1000 B over 2s, got %v", got)
+	}
+	// A lasting step: the second poll behind adopts the new time base.
+	record(-60 * time.Second)
+	if got := record(-59 * time.Second); got != 1000 {
+		t.Errorf("second poll behind: want 1000 B/s, got %v", got)
+	}
+	if got := record(-57 * time.Second); got != 500 {
+		t.Errorf("poll on the new time base: want 1000 B over 2s, got %v", got)
+	}
+}
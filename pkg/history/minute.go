@@ -0,0 +1,87 @@
+package history
+
+import "github.com/galpt/cake-stats/pkg/types"
+
+// DefaultMinuteCapacity is the number of one-minute samples kept per
+// interface: 24 hours.
+const DefaultMinuteCapacity = 1440
+
+// minuteAgg accumulates the full-resolution samples of the current minute.
+// When a sample from a later minute arrives the accumulated minute is
+// promoted to the minute ring as a single sample: rates are averaged,
+// delays, flows and memory keep their maximum and the way_miss delta is summed, so a short
+// spike is not averaged away.
+type minuteAgg struct {
+	minute int64 // unix minute being accumulated
+	n      int
+	sum    types.HistorySample
+}
+
+// add folds s into the aggregate.  If s starts a new minute the finished one
+// is returned with ok set.
+func (a *minuteAgg) add(s types.HistorySample) (done types.HistorySample, ok bool) {
+	minute := SampleTime(s).Unix() / 60
+	if a.n > 0 && minute != a.minute {
+		done, ok = a.result(), true
+		a.n = 0
+	}
+	if a.n == 0 {
+		a.minute, a.sum = minute, types.HistorySample{}
+	}
+	a.n++
+	a.sum.Tx += s.Tx
+	a.sum.Dr += s.Dr
+	a.sum.Wi += s.Wi
+	a.sum.Rq += s.Rq
+	a.sum.Mk += s.Mk
+	a.sum.Ad += s.Ad
+	a.sum.Util += s.Util
+	a.sum.Wm += s.Wm
+	a.sum.Av = max(a.sum.Av, s.Av)
+	a.sum.Pk = max(a.sum.Pk, s.Pk)
+	a.sum.Sp = max(a.sum.Sp, s.Sp)
+	a.sum.Fl = max(a.sum.Fl, s.Fl)
+	a.sum.Mem = max(a.sum.Mem, s.Mem)
+	return done, ok
+}
+
+// result returns the accumulated minute, stamped with its first second.
+func (a *minuteAgg) result() types.HistorySample {
+	n := float64(a.n)
+	r := a.sum
+	r.T = a.minute * 60
+	r.Tx /= n
+	r.Dr /= n
+	r.Wi /= n
+	r.Rq /= n
+	r.Mk /= n
+	r.Ad /= n
+	r.Util /= n
+	return r
+}
+
+// SetMinuteCapacity sets how many one-minute samples each interface keeps;
+// values below 2 restore DefaultMinuteCapacity.  Existing interfaces keep
+// their current minute ring.
+func (hs *HistoryStore) SetMinuteCapacity(capacity int) {
+	if capacity < 2 {
+		capacity = DefaultMinuteCapacity
+	}
+	hs.mu.Lock()
+	hs.minuteCapacity = capacity
+	hs.mu.Unlock()
+}
+
+// promote feeds a new full-resolution sample to the minute aggregate and
+// pushes the previous minute once it is complete.  The minute ring fills
+// slowly, so it is always a lazyRing.  Callers must hold hs.mu.
+func (hs *HistoryStore) promote(st *ifaceState, s types.HistorySample) {
+	done, ok := st.minuteAgg.add(s)
+	if !ok {
+		return
+	}
+	if st.minute == nil {
+		st.minute = newLazyRing[types.HistorySample](hs.minuteCapacity)
+	}
+	st.minute.push(done)
+}
@@ -0,0 +1,54 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestSnapshot_Minute(t *testing.T) {
+	store := NewHistoryStore(300)
+	store.SetMinuteCapacity(2)
+	clock := time.Unix(1700000020, 0) // 20 s before the minute starting at 1700000040
+	store.now = func() time.Time { return clock }
+	var sent, dropped uint64
+	poll := func(tx, dr uint64, av string) {
+		sent += tx
+		dropped += dr
+		store.Record([]types.CakeStats{{
+			Interface: "eth0", SentBytes: sent, Dropped: dropped,
+			Tiers: []types.CakeTier{{AvDelay: av}},
+		}}, 10*time.Second)
+		clock = clock.Add(10 * time.Second)
+	}
+	store.SetEMAAlpha(1)
+	poll(0, 0, "1ms") // baseline at :20
+	poll(100, 10, "1ms")
+	if got := store.Snapshot().Minute["eth0"]; len(got) != 0 {
+		t.Fatalf("minute in progress must not be exposed, got %+v", got)
+	}
+	// :00 .. :50 of the next minute, then :00 of the one after closes it.
+	poll(200, 0, "2ms")
+	poll(400, 0, "9ms")
+	poll(0, 60, "3ms")
+	poll(0, 0, "1ms")
+	poll(0, 0, "1ms")
+	poll(0, 0, "1ms")
+	poll(0, 0, "1ms")
+
+	got := store.Snapshot().Minute["eth0"]
+	if len(got) != 2 {
+		t.Fatalf("want 2 minute samples, got %d: %+v", len(got), got)
+	}
+	first, second := got[0], got[1]
+	if first.T != 1699999980 || first.Tx != 10 || first.Dr != 1 {
+		t.Errorf("first minute: got %+v", first)
+	}
+	if second.T != 1700000040 || second.Tx != 10 || second.Dr != 1 || second.Av != 9 {
+		t.Errorf("second minute: want T=1700000040 tx=10 dr=1 av=9, got %+v", second)
+	}
+	if n := len(store.Snapshot().Samples["eth0"]); n != 8 {
+		t.Errorf("full-resolution ring: want 8 samples, got %d", n)
+	}
+}
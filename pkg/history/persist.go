@@ -0,0 +1,193 @@
+package history
+
+import (
+	"encoding/binary"
+	"time"
+
+	easyjson "github.com/mailru/easyjson"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/galpt/cake-stats/pkg/log"
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// OpenDB persists history to a bbolt database at path, creating it if
+// needed, and restores the newest samples of every interface found there, up
+// to that interface's ring capacity.  Call it before the first Record.
+//
+// Each interface has its own bucket holding its samples under increasing
+// sequence keys.  The database is only ever appended to and trimmed from the
+// front: each flush adds the new samples and drops whatever no longer fits
+// in the ring, so the file stays bounded by capacity.  Reads keep going to
+// the in-memory rings.
+//
+// Record only queues its samples; they are written in one transaction, and
+// one fsync, every DBFlushInterval and by CloseDB.  A crash loses at most
+// that much history, in exchange for not writing to flash on every poll.
+func (hs *HistoryStore) OpenDB(path string) error {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return hs.restore(string(name), b)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+	hs.db = db
+	every := hs.dbFlushInterval
+	if every <= 0 {
+		every = DBFlushInterval
+	}
+	hs.dbFlushing, hs.dbFlushed = make(chan struct{}), make(chan struct{})
+	go hs.runFlusher(every, hs.dbFlushing, hs.dbFlushed)
+	return nil
+}
+
+// DBFlushInterval is how often samples queued by Record are written to the
+// database opened by OpenDB.
+const DBFlushInterval = 10 * time.Second
+
+// runFlusher calls flushDB every interval until stop is closed.
+func (hs *HistoryStore) runFlusher(every time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			hs.flushDB()
+		}
+	}
+}
+
+// flushDB writes the samples queued since the last flush.
+func (hs *HistoryStore) flushDB() {
+	hs.dbMu.Lock()
+	defer hs.dbMu.Unlock()
+	hs.mu.Lock()
+	db, batch := hs.db, hs.pending
+	hs.pending = nil
+	hs.mu.Unlock()
+	if db != nil && len(batch) > 0 {
+		persist(db, batch)
+	}
+}
+
+// CloseDB writes the queued samples and closes the database opened by
+// OpenDB, if any.
+func (hs *HistoryStore) CloseDB() error {
+	hs.mu.Lock()
+	stop, done := hs.dbFlushing, hs.dbFlushed
+	hs.dbFlushing, hs.dbFlushed = nil, nil
+	hs.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	<-done
+	hs.flushDB()
+	hs.dbMu.Lock()
+	defer hs.dbMu.Unlock()
+	hs.mu.Lock()
+	db := hs.db
+	hs.db = nil
+	hs.mu.Unlock()
+	return db.Close()
+}
+
+// restore loads the newest samples of one bucket into a fresh ring.  The
+// interface has no counters yet, so the next Record only re-baselines it.
+// Callers must hold hs.mu.
+func (hs *HistoryStore) restore(iface string, b *bolt.Bucket) error {
+	var newest [][]byte
+	c := b.Cursor()
+	for k, v := c.Last(); k != nil && len(newest) < hs.capacityFor(iface); k, v = c.Prev() {
+		newest = append(newest, v)
+	}
+	if len(newest) == 0 {
+		return nil
+	}
+	ring := hs.newRing(iface)
+	for i := len(newest) - 1; i >= 0; i-- {
+		var s types.HistorySample
+		if err := easyjson.Unmarshal(newest[i], &s); err != nil {
+			return err
+		}
+		ring.push(s)
+	}
+	hs.ifaces[iface] = &ifaceState{ring: ring, restored: true}
+	return nil
+}
+
+// persisted is one sample appended by Record, waiting to be written.
+type persisted struct {
+	iface    string
+	sample   types.HistorySample
+	capacity int // the interface's ring capacity, to trim its bucket to
+}
+
+// persist appends samples to their interface buckets in one transaction and
+// trims each bucket to capacity.  Failures are logged, not returned: the
+// in-memory history is unaffected.
+func persist(db *bolt.DB, samples []persisted) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, p := range samples {
+			b, err := tx.CreateBucketIfNotExists([]byte(p.iface))
+			if err != nil {
+				return err
+			}
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			v, err := easyjson.Marshal(&p.sample)
+			if err != nil {
+				return err
+			}
+			var key [8]byte
+			binary.BigEndian.PutUint64(key[:], seq)
+			if err := b.Put(key[:], v); err != nil {
+				return err
+			}
+			// Keys are consecutive sequence numbers, so everything below
+			// seq-capacity+1 has fallen out of the ring.
+			if seq > uint64(p.capacity) {
+				var cutoff [8]byte
+				binary.BigEndian.PutUint64(cutoff[:], seq-uint64(p.capacity)+1)
+				c := b.Cursor()
+				for k, _ := c.First(); k != nil && string(k) < string(cutoff[:]); k, _ = c.First() {
+					if err := c.Delete(); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Logger.Warn().Err(err).Msg("history: persisting samples failed")
+	}
+}
+
+// forget deletes iface's bucket so a Reset interface does not come back on
+// the next start.
+func forget(db *bolt.DB, iface string) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(iface)) == nil {
+			return nil
+		}
+		return tx.DeleteBucket([]byte(iface))
+	})
+	if err != nil {
+		log.Logger.Warn().Err(err).Str("iface", iface).Msg("history: deleting persisted samples failed")
+	}
+}
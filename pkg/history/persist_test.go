@@ -0,0 +1,110 @@
+package history
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestOpenDB_Restore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	clock := time.Unix(1700000000, 0)
+
+	store := NewHistoryStore(20)
+	store.now = func() time.Time { return clock }
+	if err := store.OpenDB(path); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= 10; i++ {
+		store.Record([]types.CakeStats{{Interface: "eth0", SentBytes: uint64(i) * 1000}}, time.Second)
+		clock = clock.Add(time.Second)
+	}
+	want := store.Snapshot().Samples["eth0"]
+	if len(want) != 10 {
+		t.Fatalf("want 10 samples, got %d", len(want))
+	}
+	if err := store.CloseDB(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewHistoryStore(20)
+	reopened.now = func() time.Time { return clock }
+	if err := reopened.OpenDB(path); err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.CloseDB()
+	if got := reopened.Snapshot().Samples["eth0"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("restored samples differ:\nwant %+v\ngot  %+v", want, got)
+	}
+
+	// The first poll after a restart only re-baselines the counters: a
+	// counter reset must not show up as a rate spike.
+	reopened.Record([]types.CakeStats{{Interface: "eth0", SentBytes: 5}}, time.Second)
+	if got := len(reopened.Snapshot().Samples["eth0"]); got != 10 {
+		t.Errorf("baseline poll appended a sample: %d samples", got)
+	}
+}
+
+func TestOpenDB_TrimAndReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store := NewHistoryStore(3)
+	if err := store.OpenDB(path); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		store.Record([]types.CakeStats{{Interface: "eth0"}, {Interface: "eth1"}}, time.Second)
+	}
+	store.Reset("eth1")
+	store.CloseDB()
+
+	reopened := NewHistoryStore(10)
+	if err := reopened.OpenDB(path); err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.CloseDB()
+	snap := reopened.Snapshot().Samples
+	if got := len(snap["eth0"]); got != 3 {
+		t.Errorf("eth0: want the 3 samples that fit the old ring, got %d", got)
+	}
+	if _, ok := snap["eth1"]; ok {
+		t.Error("eth1 was reset and must not be restored")
+	}
+}
+
+func TestOpenDB_BatchesWrites(t *testing.T) {
+	store := NewHistoryStore(20)
+	store.dbFlushInterval = 50 * time.Millisecond
+	if err := store.OpenDB(filepath.Join(t.TempDir(), "history.db")); err != nil {
+		t.Fatal(err)
+	}
+	defer store.CloseDB()
+	stored := func() int {
+		n := 0
+		store.db.View(func(tx *bolt.Tx) error {
+			if b := tx.Bucket([]byte("eth0")); b != nil {
+				n = b.Stats().KeyN
+			}
+			return nil
+		})
+		return n
+	}
+
+	for i := 0; i < 3; i++ {
+		store.Record([]types.CakeStats{{Interface: "eth0", SentBytes: uint64(i) * 1000}}, time.Second)
+	}
+	if n := stored(); n != 0 {
+		t.Errorf("want nothing written before the flush, got %d samples", n)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for stored() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("want the 2 samples written by the flush, got %d", stored())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
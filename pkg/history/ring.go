@@ -0,0 +1,105 @@
+package history
+
+// ringBuffer holds the most recent samples of one series, up to a fixed
+// capacity, overwriting the oldest once full.
+type ringBuffer[T any] interface {
+	push(s T)
+	// ordered returns a copy of the retained samples, oldest first, or nil
+	// when empty.
+	ordered() []T
+	len() int
+	capacity() int
+}
+
+// eagerRing allocates its full capacity up front.  Pushes never allocate,
+// which suits the usual small capacities.
+type eagerRing[T any] struct {
+	buf   []T
+	head  int
+	count int
+}
+
+func newEagerRing[T any](capacity int) *eagerRing[T] {
+	return &eagerRing[T]{buf: make([]T, capacity)}
+}
+
+func (r *eagerRing[T]) push(s T) {
+	r.buf[r.head] = s
+	r.head = (r.head + 1) % len(r.buf)
+	if r.count < len(r.buf) {
+		r.count++
+	}
+}
+
+func (r *eagerRing[T]) ordered() []T {
+	if r.count == 0 {
+		return nil
+	}
+	out := make([]T, r.count)
+	if r.count < len(r.buf) {
+		copy(out, r.buf[:r.count])
+	} else {
+		n := copy(out, r.buf[r.head:])
+		copy(out[n:], r.buf[:r.head])
+	}
+	return out
+}
+
+func (r *eagerRing[T]) len() int { return r.count }
+
+func (r *eagerRing[T]) capacity() int { return len(r.buf) }
+
+// lazyRing grows its backing slice on demand, doubling up to capacity, and
+// only starts overwriting once it is full.  With very large capacities this
+// avoids paying for the whole buffer on interfaces that are short-lived or
+// were only just discovered.
+type lazyRing[T any] struct {
+	buf   []T
+	head  int // next slot to overwrite; meaningful once len(buf) == limit
+	limit int
+}
+
+func newLazyRing[T any](capacity int) *lazyRing[T] {
+	return &lazyRing[T]{limit: capacity}
+}
+
+func (r *lazyRing[T]) push(s T) {
+	if len(r.buf) < r.limit {
+		if len(r.buf) == cap(r.buf) {
+			// Grow by hand so the final allocation is exactly capacity
+			// rather than whatever append would round up to.
+			grown := make([]T, len(r.buf), min(max(2*cap(r.buf), 16), r.limit))
+			copy(grown, r.buf)
+			r.buf = grown
+		}
+		r.buf = append(r.buf, s)
+		return
+	}
+	r.buf[r.head] = s
+	r.head = (r.head + 1) % r.limit
+}
+
+func (r *lazyRing[T]) ordered() []T {
+	if len(r.buf) == 0 {
+		return nil
+	}
+	out := make([]T, len(r.buf))
+	n := copy(out, r.buf[r.head:])
+	copy(out[n:], r.buf[:r.head])
+	return out
+}
+
+func (r *lazyRing[T]) len() int { return len(r.buf) }
+
+func (r *lazyRing[T]) capacity() int { return r.limit }
+
+// resizeRing returns a new ring of the given capacity holding the newest
+// samples of r in their original order.
+func resizeRing[T any](r ringBuffer[T], lazy bool, capacity int) ringBuffer[T] {
+	out := newRingOf[T](lazy, capacity)
+	samples := r.ordered()
+	for _, s := range samples[max(len(samples)-capacity, 0):] {
+		out.push(s)
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestRingBuffers_SameOrder(t *testing.T) {
+	const capacity = 40
+	eager, lazy := newEagerRing[types.HistorySample](capacity), newLazyRing[types.HistorySample](capacity)
+	if eager.ordered() != nil || lazy.ordered() != nil {
+		t.Fatal("empty ring must return nil")
+	}
+	for i := 1; i <= 3*capacity+7; i++ {
+		s := types.HistorySample{T: int64(i)}
+		eager.push(s)
+		lazy.push(s)
+		want, got := eager.ordered(), lazy.ordered()
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("after %d pushes: eager %v, lazy %v", i, want, got)
+		}
+		if got[len(got)-1].T != int64(i) {
+			t.Fatalf("after %d pushes: newest sample is T=%d", i, got[len(got)-1].T)
+		}
+	}
+	if lazy.len() != capacity || cap(lazy.buf) != capacity {
+		t.Errorf("full lazy ring: len %d cap %d, want %d", lazy.len(), cap(lazy.buf), capacity)
+	}
+}
+
+func TestHistoryStore_LazyRing(t *testing.T) {
+	store := NewHistoryStore(5)
+	store.SetLazyRing(true)
+	for i := 0; i < 10; i++ {
+		store.Record([]types.CakeStats{{Interface: "eth0", SentBytes: uint64(i)}}, 0)
+	}
+	if got := len(store.Snapshot().Samples["eth0"]); got != 5 {
+		t.Errorf("want 5 retained samples, got %d", got)
+	}
+}
+
+// The ring benchmarks fill a large buffer only partially, the case lazyRing
+// is meant for; compare allocs/op and B/op.
+const benchRingCapacity = 100_000
+
+func benchmarkRing(b *testing.B, newRing func(int) ringBuffer[types.HistorySample]) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r := newRing(benchRingCapacity)
+		for j := 0; j < 100; j++ {
+			r.push(types.HistorySample{T: int64(j)})
+		}
+	}
+}
+
+func BenchmarkEagerRing(b *testing.B) {
+	benchmarkRing(b, func(n int) ringBuffer[types.HistorySample] { return newEagerRing[types.HistorySample](n) })
+}
+
+func BenchmarkLazyRing(b *testing.B) {
+	benchmarkRing(b, func(n int) ringBuffer[types.HistorySample] { return newLazyRing[types.HistorySample](n) })
+}
+
+func TestHistoryStore_SetCapacity(t *testing.T) {
+	store := NewHistoryStore(5)
+	clock := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return clock }
+	record := func(n int) {
+		for i := 0; i < n; i++ {
+			store.Record([]types.CakeStats{{Interface: "eth0"}, {Interface: "eth1"}}, time.Second)
+			clock = clock.Add(time.Second)
+		}
+	}
+	times := func(iface string) []int64 {
+		var out []int64
+		for _, s := range store.Snapshot().Samples[iface] {
+			out = append(out, s.T-1700000000)
+		}
+		return out
+	}
+	record(5) // samples at t=1..4
+
+	store.SetCapacity("eth0", 8)
+	store.SetCapacity("eth1", 2)
+	record(1) // resize, then append t=5
+	if got, want := times("eth0"), []int64{1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("grown eth0: want %v, got %v", want, got)
+	}
+	if got, want := times("eth1"), []int64{4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shrunk eth1: want %v, got %v", want, got)
+	}
+
+	record(5) // t=6..10
+	if got, want := times("eth0"), []int64{3, 4, 5, 6, 7, 8, 9, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("eth0 at new capacity: want %v, got %v", want, got)
+	}
+	if got := len(times("eth1")); got != 2 {
+		t.Errorf("eth1: want 2 samples, got %d", got)
+	}
+}
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/rs/zerolog"
@@ -13,3 +14,38 @@ var Logger zerolog.Logger
 func init() {
 	Logger = zerolog.New(os.Stderr).With().Timestamp().Logger()
 }
+
+// SetFormat switches the output of Logger, keeping its level and context:
+// "json" (the default) writes one JSON object per line, "console" writes
+// human-readable, colourised lines.
+func SetFormat(format string) error {
+	switch format {
+	case "json":
+		Logger = Logger.Output(os.Stderr)
+	case "console":
+		Logger = Logger.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	default:
+		return fmt.Errorf("unknown log format %q (want json or console)", format)
+	}
+	return nil
+}
+
+// SetLevel sets the minimum level Logger writes: "debug", "info", "warn" or
+// "error".
+func SetLevel(level string) error {
+	var l zerolog.Level
+	switch level {
+	case "debug":
+		l = zerolog.DebugLevel
+	case "info":
+		l = zerolog.InfoLevel
+	case "warn":
+		l = zerolog.WarnLevel
+	case "error":
+		l = zerolog.ErrorLevel
+	default:
+		return fmt.Errorf("unknown log level %q (want debug, info, warn or error)", level)
+	}
+	Logger = Logger.Level(l)
+	return nil
+}
@@ -0,0 +1,32 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSetLevel(t *testing.T) {
+	old := Logger
+	t.Cleanup(func() { Logger = old })
+
+	var buf bytes.Buffer
+	Logger = zerolog.New(&buf)
+	if err := SetLevel("error"); err != nil {
+		t.Fatal(err)
+	}
+	Logger.Debug().Msg("debug")
+	Logger.Info().Msg("info")
+	if buf.Len() != 0 {
+		t.Errorf("want no output below error level, got %q", buf.String())
+	}
+	Logger.Error().Msg("error")
+	if buf.Len() == 0 {
+		t.Error("error-level message was dropped")
+	}
+
+	if err := SetLevel("trace"); err == nil {
+		t.Error("want error for unsupported level")
+	}
+}
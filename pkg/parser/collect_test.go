@@ -0,0 +1,261 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/galpt/cake-stats/pkg/log"
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// fakeTC writes an executable shell script standing in for tc and points
+// tcCommand at it for the duration of the test.  Netlink collection is
+// switched off so CollectStats goes straight to the fake.
+func fakeTC(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell-script fake tc requires a POSIX shell")
+	}
+	path := filepath.Join(t.TempDir(), "tc")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old, oldOff := tcCommand, netlinkOff.Load()
+	tcCommand = path
+	netlinkOff.Store(true)
+	lastExecFailure.Store("")
+	t.Cleanup(func() {
+		tcCommand = old
+		netlinkOff.Store(oldOff)
+	})
+	return path
+}
+
+// captureLog redirects log.Logger into a buffer for the duration of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	old := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = old })
+	return &buf
+}
+
+func TestCollectStats_ExecFailureLogged(t *testing.T) {
+	path := fakeTC(t, `echo "RTNETLINK answers: Operation not permitted" >&2; exit 2`)
+	buf := captureLog(t)
+
+	_, err := CollectStats(context.Background())
+	if err == nil {
+		t.Fatal("expected an error from failing tc")
+	}
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("want ErrPermissionDenied in chain, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "RTNETLINK answers: Operation not permitted") {
+		t.Errorf("error should carry tc's stderr, got %q", err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log output is not a single JSON object: %v\n%s", err, buf.String())
+	}
+	if entry["message"] != "tc exec failed" {
+		t.Errorf("message: got %v", entry["message"])
+	}
+	if entry["path"] != path {
+		t.Errorf("path: want %q, got %v", path, entry["path"])
+	}
+	if entry["exit_code"] != float64(2) {
+		t.Errorf("exit_code: want 2, got %v", entry["exit_code"])
+	}
+	if entry["stderr"] != "RTNETLINK answers: Operation not permitted" {
+		t.Errorf("stderr: got %v", entry["stderr"])
+	}
+	for _, k := range []string{"elapsed", "dir", "error", "hint"} {
+		if _, ok := entry[k]; !ok {
+			t.Errorf("missing field %q in %s", k, buf.String())
+		}
+	}
+}
+
+func TestCollectStatsForDevice(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args")
+	// The fake ignores the device filter and prints everything, so the
+	// result also shows CollectStatsForDevice dropping other interfaces.
+	fakeTC(t, `echo "$@" > `+argsFile+"\ncat <<'EOF'\n"+sampleTCOutput+"\nEOF")
+
+	stats, err := CollectStatsForDevice(context.Background(), "eth1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, _ := os.ReadFile(argsFile)
+	if got := string(bytes.TrimSpace(args)); got != "-s qdisc show dev eth1" {
+		t.Errorf("tc args: want %q, got %q", "-s qdisc show dev eth1", got)
+	}
+	if len(stats) != 1 || stats[0].Interface != "eth1" {
+		t.Errorf("want only eth1, got %+v", stats)
+	}
+}
+
+func TestCollectDevices_OneNetlinkDump(t *testing.T) {
+	netlinkOff.Store(false)
+	netlinkRetryAt.Store(0)
+	calls := 0
+	old := netlinkCollect
+	netlinkCollect = func(context.Context) ([]types.CakeStats, error) {
+		calls++
+		return []types.CakeStats{{Interface: "eth0"}, {Interface: "eth1"}, {Interface: "ifb4eth0"}}, nil
+	}
+	t.Cleanup(func() { netlinkCollect = old })
+
+	stats, err := Collector{}.CollectDevices(context.Background(), []string{"ifb4eth0", "eth0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("want one netlink dump for both devices, got %d", calls)
+	}
+	if len(stats) != 2 || stats[0].Interface != "eth0" || stats[1].Interface != "ifb4eth0" {
+		t.Errorf("want eth0 and ifb4eth0, got %+v", stats)
+	}
+}
+
+func TestCollectDevices_TCPerDevice(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args")
+	fakeTC(t, `echo "$@" >> `+argsFile+"\ncat <<'EOF'\n"+sampleTCOutput+"\nEOF")
+
+	stats, err := Collector{}.CollectDevices(context.Background(), []string{"ifb4eth1", "eth1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, _ := os.ReadFile(argsFile)
+	lines := strings.Split(strings.TrimSpace(string(args)), "\n")
+	slices.Sort(lines)
+	if want := []string{"-s qdisc show dev eth1", "-s qdisc show dev ifb4eth1"}; !slices.Equal(lines, want) {
+		t.Errorf("tc runs: want %q, got %q", want, lines)
+	}
+	if len(stats) != 2 || stats[0].Interface != "ifb4eth1" || stats[1].Interface != "eth1" {
+		t.Errorf("want ifb4eth1 then eth1, got %+v", stats)
+	}
+}
+
+func TestCollectStats_ExecFailureNotRepeated(t *testing.T) {
+	fakeTC(t, `echo "Cannot find device" >&2; exit 1`)
+	buf := captureLog(t)
+
+	for i := 0; i < 3; i++ {
+		_, err := CollectStats(context.Background())
+		if err == nil || errors.Is(err, ErrPermissionDenied) {
+			t.Fatalf("poll %d: want a non-permission error, got %v", i, err)
+		}
+	}
+	if n := strings.Count(buf.String(), "tc exec failed"); n != 1 {
+		t.Errorf("identical failures should log once, logged %d times", n)
+	}
+}
+
+func TestCollector_Netns(t *testing.T) {
+	tc := fakeTC(t, "cat <<'EOF'\n"+sampleTCOutput+"\nEOF")
+	var gotName string
+	var gotArgs []string
+	old := execCommand
+	execCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		gotName, gotArgs = name, args
+		return exec.CommandContext(ctx, tc)
+	}
+	t.Cleanup(func() { execCommand = old })
+
+	cases := []struct {
+		netns string
+		name  string
+		args  []string
+	}{
+		{"", tc, []string{"-s", "qdisc"}},
+		{"wan", "ip", []string{"netns", "exec", "wan", tc, "-s", "qdisc"}},
+		{"/proc/42/ns/net", "nsenter", []string{"--net=/proc/42/ns/net", tc, "-s", "qdisc"}},
+	}
+	for _, c := range cases {
+		stats, err := Collector{Netns: c.netns}.Collect(context.Background())
+		if err != nil {
+			t.Fatalf("netns %q: %v", c.netns, err)
+		}
+		if len(stats) != 2 {
+			t.Errorf("netns %q: want 2 qdiscs, got %d", c.netns, len(stats))
+		}
+		if gotName != c.name || !slices.Equal(gotArgs, c.args) {
+			t.Errorf("netns %q: want %s %q, got %s %q", c.netns, c.name, c.args, gotName, gotArgs)
+		}
+	}
+}
+
+func TestCollector_NetnsSkipsNetlink(t *testing.T) {
+	fakeTC(t, "cat <<'EOF'\n"+sampleTCOutput+"\nEOF")
+	netlinkOff.Store(false)
+	netlinkRetryAt.Store(0)
+	old := netlinkCollect
+	netlinkCollect = func(context.Context) ([]types.CakeStats, error) {
+		t.Error("netlink used for a foreign namespace")
+		return nil, nil
+	}
+	t.Cleanup(func() { netlinkCollect = old })
+	tc := tcCommand
+	oldExec := execCommand
+	execCommand = func(ctx context.Context, _ string, _ ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, tc)
+	}
+	t.Cleanup(func() { execCommand = oldExec })
+
+	if _, err := (Collector{Netns: "wan"}).CollectDevice(context.Background(), "eth1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectStats_TCNotFound(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	oldCmd, oldCand, oldOff := tcCommand, tcCandidates, netlinkOff.Load()
+	tcCommand, tcCandidates = "tc", []string{filepath.Join(dir, "sbin", "tc")}
+	netlinkOff.Store(true)
+	tcResolved.Store(nil)
+	t.Cleanup(func() {
+		tcCommand, tcCandidates = oldCmd, oldCand
+		netlinkOff.Store(oldOff)
+	})
+
+	_, err := CollectStats(context.Background())
+	if !errors.Is(err, ErrTCNotFound) {
+		t.Fatalf("want ErrTCNotFound, got %v", err)
+	}
+}
+
+func TestLookTC_Cached(t *testing.T) {
+	path := fakeTC(t, "exit 0")
+	if got, err := lookTC(); err != nil || got != path {
+		t.Fatalf("want %s, got %q, %v", path, got, err)
+	}
+	// A cached path is not checked again...
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := lookTC(); err != nil || got != path {
+		t.Fatalf("cached: want %s, got %q, %v", path, got, err)
+	}
+	// ...until running it finds it gone.
+	if _, err := CollectStats(context.Background()); err == nil {
+		t.Fatal("want an error for a removed tc")
+	}
+	if r := tcResolved.Load(); r != nil {
+		t.Errorf("cache not cleared: %+v", r)
+	}
+}
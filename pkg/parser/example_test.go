@@ -0,0 +1,45 @@
+package parser_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/galpt/cake-stats/pkg/parser"
+)
+
+func ExampleParseBlock() {
+	block := `qdisc cake 8001: dev wan root refcnt 2 bandwidth 20Mbit besteffort triple-isolate nonat nowash no-ack-filter split-gso rtt 100ms noatm overhead 18 mpu 64
+ Sent 1048576 bytes 2048 pkt (dropped 3, overlimits 12 requeues 0)
+ backlog 0b 0p requeues 0
+ memory used: 16Kb of 4Mb
+ capacity estimate: 20Mbit
+
+                  Tin 0
+  thresh         20Mbit
+  av_delay          42us
+  drops                3`
+
+	cs, ok := parser.ParseBlock(strings.Split(block, "\n"))
+	fmt.Println(ok, cs.Interface, cs.Bandwidth, cs.DiffservMode)
+	fmt.Println(cs.SentBytes, cs.Dropped, len(cs.Tiers), cs.Tiers[0].Name, cs.Tiers[0].AvDelay)
+	// Output:
+	// true wan 20Mbit besteffort
+	// 1048576 3 1 Tin 0 42us
+}
+
+func ExampleParseJSON() {
+	raw := []byte(`[{"kind":"fq_codel","dev":"lo"},
+	{"kind":"cake","dev":"wan","handle":"8001:","options":{"diffserv":"diffserv3","rtt":100000},
+	 "bytes":1048576,"packets":2048,"drops":3}]`)
+
+	stats, err := parser.ParseJSON(raw)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, cs := range stats {
+		fmt.Println(cs.Interface, cs.DiffservMode, cs.RTT, cs.SentBytes, cs.Dropped)
+	}
+	// Output:
+	// wan diffserv3 100ms 1048576 3
+}
@@ -0,0 +1,528 @@
+package parser
+
+// Netlink collection path.  CollectStats prefers dumping qdiscs straight from
+// the kernel with an RTM_GETQDISC request, which avoids a fork+exec of tc on
+// every poll.  The decoder below is platform independent so it can be tested
+// anywhere; only the socket lives in netlink_linux.go.
+//
+// Values are rendered in the same units and formats tc prints ("50Mbit",
+// "5.81ms", "238656b", …) so the rest of the program, which was written
+// against the text parser, cannot tell the two paths apart.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mdlayher/netlink"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// errNetlinkUnsupported is returned by collectNetlink on platforms without
+// rtnetlink.
+var errNetlinkUnsupported = errors.New("netlink qdisc dump not supported on this platform")
+
+// errNetlinkDecode wraps a qdisc message collectNetlink could not decode.
+// The kernel will send the same layout on every poll, so it is permanent.
+var errNetlinkDecode = errors.New("undecodable qdisc message")
+
+// tcmsgLen is sizeof(struct tcmsg): family, 3 bytes padding, ifindex, handle,
+// parent, info.
+const tcmsgLen = 20
+
+// Top-level qdisc attributes (TCA_*).
+const (
+	tcaKind   = 1
+	tcaOpts   = 2
+	tcaXStats = 4
+	tcaStats2 = 7
+)
+
+// Nested TCA_STATS2 attributes (TCA_STATS_*).
+const (
+	tcaStatsBasic = 1
+	tcaStatsQueue = 3
+	tcaStatsApp   = 4
+	tcaStatsPkt64 = 8
+)
+
+// CAKE qdisc options (TCA_CAKE_*).
+const (
+	tcaCakeBaseRate64   = 2
+	tcaCakeDiffservMode = 3
+	tcaCakeATM          = 4
+	tcaCakeFlowMode     = 5
+	tcaCakeOverhead     = 6
+	tcaCakeRTT          = 7
+	tcaCakeAutorate     = 9
+	tcaCakeMemory       = 10
+	tcaCakeNAT          = 11
+	tcaCakeWash         = 13
+	tcaCakeMPU          = 14
+	tcaCakeIngress      = 15
+	tcaCakeAckFilter    = 16
+	tcaCakeSplitGSO     = 17
+	tcaCakeFwmark       = 18
+)
+
+// CAKE extended statistics (TCA_CAKE_STATS_*).
+const (
+	cakeStatsCapacityEstimate64 = 2
+	cakeStatsMemoryLimit        = 3
+	cakeStatsMemoryUsed         = 4
+	cakeStatsAvgNetoff          = 5
+	cakeStatsMinNetlen          = 6
+	cakeStatsMaxNetlen          = 7
+	cakeStatsMinAdjlen          = 8
+	cakeStatsMaxAdjlen          = 9
+	cakeStatsTinStats           = 10
+)
+
+// Per-tin statistics (TCA_CAKE_TIN_STATS_*).
+const (
+	tinSentPackets        = 2
+	tinSentBytes64        = 3
+	tinDroppedPackets     = 4
+	tinAcksDroppedPackets = 6
+	tinECNMarkedPackets   = 8
+	tinBacklogBytes       = 11
+	tinThresholdRate64    = 12
+	tinTargetUs           = 13
+	tinIntervalUs         = 14
+	tinWayIndirectHits    = 15
+	tinWayMisses          = 16
+	tinWayCollisions      = 17
+	tinPeakDelayUs        = 18
+	tinAvgDelayUs         = 19
+	tinBaseDelayUs        = 20
+	tinSparseFlows        = 21
+	tinBulkFlows          = 22
+	tinUnresponsiveFlows  = 23
+	tinMaxSkblen          = 24
+	tinFlowQuantum        = 25
+)
+
+// Kernel enum values mapped to the keywords tc prints for them.
+var (
+	netlinkDiffservModes = []string{"diffserv3", "diffserv4", "diffserv8", "besteffort", "precedence"}
+	netlinkFlowModes     = []string{"flowblind", "srchost", "dsthost", "hosts", "flows", "dual-srchost", "dual-dsthost", "triple-isolate"}
+	netlinkATMModes      = []string{"noatm", "atm", "ptm"}
+)
+
+// tcRoot and tcIngress are the TC_H_ROOT and TC_H_INGRESS parent handles;
+// qdiscs attached there have no cake_mq parent.
+const (
+	tcRoot    = 0xFFFFFFFF
+	tcIngress = 0xFFFFFFF1
+)
+
+// decodeQdisc decodes one RTM_NEWQDISC payload (struct tcmsg followed by
+// attributes).  ok is false for qdisc kinds other than cake and cake_mq.
+// ifname resolves an interface index to its name.
+func decodeQdisc(data []byte, ifname func(int) string) (e qdiscEntry, ok bool, err error) {
+	if len(data) < tcmsgLen {
+		return e, false, fmt.Errorf("tcmsg too short: %d bytes", len(data))
+	}
+	ifindex := int(int32(binary.NativeEndian.Uint32(data[4:8])))
+	handle := binary.NativeEndian.Uint32(data[8:12])
+	parent := binary.NativeEndian.Uint32(data[12:16])
+
+	ad, err := netlink.NewAttributeDecoder(data[tcmsgLen:])
+	if err != nil {
+		return e, false, err
+	}
+	var kind string
+	var opts, stats2, xstats []byte
+	for ad.Next() {
+		switch ad.Type() {
+		case tcaKind:
+			kind = ad.String()
+		case tcaOpts:
+			opts = ad.Bytes()
+		case tcaStats2:
+			stats2 = ad.Bytes()
+		case tcaXStats:
+			xstats = ad.Bytes()
+		}
+	}
+	if err := ad.Err(); err != nil {
+		return e, false, err
+	}
+	if kind != "cake" && kind != "cake_mq" {
+		return e, false, nil
+	}
+
+	cs := types.CakeStats{
+		Interface: ifname(ifindex),
+		Handle:    fmt.Sprintf("%x", handle>>16),
+		UpdatedAt: time.Now().UTC(),
+	}
+	if err := decodeCakeOptions(&cs, opts); err != nil {
+		return e, false, fmt.Errorf("%s options: %w", cs.Interface, err)
+	}
+	app, err := decodeStats2(&cs, stats2)
+	if err != nil {
+		return e, false, fmt.Errorf("%s stats: %w", cs.Interface, err)
+	}
+	if app == nil {
+		app = xstats
+	}
+	if err := decodeCakeXStats(&cs, app); err != nil {
+		return e, false, fmt.Errorf("%s xstats: %w", cs.Interface, err)
+	}
+
+	e.cs = cs
+	e.isCakeMQ = kind == "cake_mq"
+	if !e.isCakeMQ && parent != tcRoot && parent != tcIngress {
+		e.parentHandle = fmt.Sprintf("%x", parent>>16)
+	}
+	return e, true, nil
+}
+
+// decodeCakeOptions fills the configuration fields parseHeader would read
+// from the qdisc header line.
+func decodeCakeOptions(cs *types.CakeStats, b []byte) error {
+	cs.Direction = "egress"
+	cs.Bandwidth = "unlimited"
+	cs.ATMMode = "noatm"
+	if len(b) > 0 {
+		ad, err := netlink.NewAttributeDecoder(b)
+		if err != nil {
+			return err
+		}
+		var autorate bool
+		for ad.Next() {
+			v := nlUint(ad.Bytes())
+			switch ad.Type() {
+			case tcaCakeBaseRate64:
+				if v > 0 {
+					cs.Bandwidth = formatRate(v)
+					cs.BandwidthBps = v * 8
+				}
+			case tcaCakeDiffservMode:
+				cs.DiffservMode = enumName(netlinkDiffservModes, v)
+			case tcaCakeATM:
+				cs.ATMMode = enumName(netlinkATMModes, v)
+			case tcaCakeFlowMode:
+				cs.DualMode = enumName(netlinkFlowModes, v)
+			case tcaCakeOverhead:
+				cs.Overhead = fmt.Sprintf("%d", int32(v))
+			case tcaCakeRTT:
+				cs.RTT = formatTime(v)
+			case tcaCakeAutorate:
+				autorate = v != 0
+			case tcaCakeMemory:
+				if v > 0 {
+					cs.MemLimit = formatSize(v)
+					cs.MemLimitBytes = v
+				}
+			case tcaCakeNAT:
+				cs.NATEnabled = v != 0
+			case tcaCakeWash:
+				cs.WashEnabled = v != 0
+			case tcaCakeMPU:
+				if v > 0 {
+					cs.MPU = fmt.Sprintf("%d", v)
+				}
+			case tcaCakeAckFilter:
+				cs.NoAckFilter = v == 0
+			case tcaCakeSplitGSO:
+				cs.SplitGSO = v != 0
+			case tcaCakeIngress:
+				if v != 0 {
+					cs.Direction = "ingress"
+				}
+			case tcaCakeFwmark:
+				if v != 0 {
+					cs.FwmarkMask = fmt.Sprintf("0x%x", v)
+				}
+			}
+		}
+		if err := ad.Err(); err != nil {
+			return err
+		}
+		if autorate {
+			cs.Bandwidth = "autorate-ingress"
+			cs.BandwidthBps = 0
+		}
+	}
+	cs.MPUBytes = mpuBytes(cs.MPU)
+	cs.DiffservCode = diffservCodeMap[cs.DiffservMode]
+	applyIFBDirection(cs)
+	return nil
+}
+
+// decodeStats2 fills the "Sent …" and "backlog …" counters from TCA_STATS2
+// and returns the nested TCA_STATS_APP payload, if any.
+func decodeStats2(cs *types.CakeStats, b []byte) (app []byte, err error) {
+	cs.BacklogBytes = formatSize(0)
+	if len(b) == 0 {
+		return nil, nil
+	}
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		return nil, err
+	}
+	var pkt64 uint64
+	for ad.Next() {
+		v := ad.Bytes()
+		switch ad.Type() {
+		case tcaStatsBasic:
+			// struct gnet_stats_basic { __u64 bytes; __u32 packets; }
+			if len(v) >= 12 {
+				cs.SentBytes = binary.NativeEndian.Uint64(v[0:8])
+				cs.SentPkts = uint64(binary.NativeEndian.Uint32(v[8:12]))
+			}
+		case tcaStatsPkt64:
+			pkt64 = nlUint(v)
+		case tcaStatsQueue:
+			// struct gnet_stats_queue { qlen, backlog, drops, requeues, overlimits }
+			if len(v) >= 20 {
+				cs.BacklogPkts = uint64(binary.NativeEndian.Uint32(v[0:4]))
+				cs.BacklogBytes = formatSize(uint64(binary.NativeEndian.Uint32(v[4:8])))
+				cs.Dropped = uint64(binary.NativeEndian.Uint32(v[8:12]))
+				cs.Requeues = uint64(binary.NativeEndian.Uint32(v[12:16]))
+				cs.Overlimits = uint64(binary.NativeEndian.Uint32(v[16:20]))
+			}
+		case tcaStatsApp:
+			app = v
+		}
+	}
+	if pkt64 > 0 {
+		cs.SentPkts = pkt64
+	}
+	return app, ad.Err()
+}
+
+// decodeCakeXStats fills the memory, capacity, packet-size and per-tin
+// statistics from CAKE's extended stats.
+func decodeCakeXStats(cs *types.CakeStats, b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		return err
+	}
+	var memUsed, memLimit uint64
+	var haveMem bool
+	for ad.Next() {
+		v := ad.Bytes()
+		switch ad.Type() {
+		case cakeStatsCapacityEstimate64:
+			if n := nlUint(v); n > 0 {
+				cs.CapacityEst = formatRate(n)
+			}
+		case cakeStatsMemoryLimit:
+			memLimit, haveMem = nlUint(v), true
+		case cakeStatsMemoryUsed:
+			memUsed, haveMem = nlUint(v), true
+		case cakeStatsAvgNetoff:
+			cs.AvgHdrOffset = fmt.Sprintf("%d", nlUint(v))
+		case cakeStatsMinNetlen:
+			cs.MinNetSize = fmt.Sprintf("%d", nlUint(v))
+		case cakeStatsMaxNetlen:
+			cs.MaxNetSize = fmt.Sprintf("%d", nlUint(v))
+		case cakeStatsMinAdjlen:
+			cs.MinAdjSize = fmt.Sprintf("%d", nlUint(v))
+		case cakeStatsMaxAdjlen:
+			cs.MaxAdjSize = fmt.Sprintf("%d", nlUint(v))
+		case cakeStatsTinStats:
+			tiers, err := decodeTins(v)
+			if err != nil {
+				return err
+			}
+			names := tinNames(cs.DiffservMode, len(tiers))
+			for i := range tiers {
+				tiers[i].Name = names[i]
+			}
+			cs.Tiers = tiers
+			cs.MaxFlows = setFlowTotals(tiers)
+		}
+	}
+	if haveMem {
+		cs.MemoryUsed = formatSize(memUsed)
+		cs.MemoryTotal = formatSize(memLimit)
+	}
+	return ad.Err()
+}
+
+// decodeTins decodes TCA_CAKE_STATS_TIN_STATS: one nested attribute per tin,
+// numbered from 1 in tin order.
+func decodeTins(b []byte) ([]types.CakeTier, error) {
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		return nil, err
+	}
+	var tiers []types.CakeTier
+	for ad.Next() {
+		tad, err := netlink.NewAttributeDecoder(ad.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		t := types.CakeTier{Backlog: formatSize(0)}
+		for tad.Next() {
+			v := nlUint(tad.Bytes())
+			switch tad.Type() {
+			case tinSentPackets:
+				t.Pkts = v
+			case tinSentBytes64:
+				t.Bytes = v
+			case tinDroppedPackets:
+				t.Drops = v
+			case tinAcksDroppedPackets:
+				t.AckDrop = v
+			case tinECNMarkedPackets:
+				t.Marks = v
+			case tinBacklogBytes:
+				t.Backlog = formatSize(v)
+			case tinThresholdRate64:
+				t.Thresh = formatRate(v)
+			case tinTargetUs:
+				t.Target = formatTime(v)
+			case tinIntervalUs:
+				t.Interval = formatTime(v)
+			case tinWayIndirectHits:
+				t.WayInds = v
+			case tinWayMisses:
+				t.WayMiss = v
+			case tinWayCollisions:
+				t.WayCols = v
+			case tinPeakDelayUs:
+				t.PkDelay = formatTime(v)
+			case tinAvgDelayUs:
+				t.AvDelay = formatTime(v)
+			case tinBaseDelayUs:
+				t.SpDelay = formatTime(v)
+			case tinSparseFlows:
+				t.SpFlows = v
+			case tinBulkFlows:
+				t.BkFlows = v
+			case tinUnresponsiveFlows:
+				t.UnFlows = v
+			case tinMaxSkblen:
+				t.MaxLen = v
+			case tinFlowQuantum:
+				t.Quantum = v
+			}
+		}
+		if err := tad.Err(); err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, ad.Err()
+}
+
+// tinNames returns the column headings tc prints for n tins in the given
+// diffserv mode.
+func tinNames(mode string, n int) []string {
+	var named []string
+	switch mode {
+	case "diffserv3":
+		named = []string{"Bulk", "Best Effort", "Voice"}
+	case "diffserv4":
+		named = []string{"Bulk", "Best Effort", "Video", "Voice"}
+	}
+	names := make([]string, n)
+	for i := range names {
+		if len(named) == n {
+			names[i] = named[i]
+		} else {
+			names[i] = fmt.Sprintf("Tin %d", i)
+		}
+	}
+	return names
+}
+
+// interfaceNames returns an ifindex resolver backed by a single snapshot of
+// the host's interfaces.  Unknown indexes render as "if<N>".
+func interfaceNames() func(int) string {
+	byIndex := make(map[int]string)
+	if ifs, err := net.Interfaces(); err == nil {
+		for _, ifc := range ifs {
+			byIndex[ifc.Index] = ifc.Name
+		}
+	}
+	return func(idx int) string {
+		if name, ok := byIndex[idx]; ok {
+			return name
+		}
+		return fmt.Sprintf("if%d", idx)
+	}
+}
+
+// nlUint reads a native-endian unsigned attribute of 1, 2, 4 or 8 bytes.
+func nlUint(b []byte) uint64 {
+	switch len(b) {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(binary.NativeEndian.Uint16(b))
+	case 4:
+		return uint64(binary.NativeEndian.Uint32(b))
+	case 8:
+		return binary.NativeEndian.Uint64(b)
+	}
+	return 0
+}
+
+func enumName(names []string, v uint64) string {
+	if v < uint64(len(names)) {
+		return names[v]
+	}
+	return fmt.Sprintf("unknown(%d)", v)
+}
+
+// formatRate renders a byte-per-second rate the way tc does: in bits, using
+// the largest decimal unit that divides the value exactly (or that keeps it
+// below 1000), e.g. 390625 B/s → "3125Kbit".
+func formatRate(bytesPerSec uint64) string {
+	units := []string{"bit", "Kbit", "Mbit", "Gbit", "Tbit"}
+	rate := bytesPerSec * 8
+	i := 0
+	for ; i < len(units)-1; i++ {
+		if rate < 1000 || (rate%1000 != 0 && rate < 1000*1000) {
+			break
+		}
+		rate /= 1000
+	}
+	return fmt.Sprintf("%d%s", rate, units[i])
+}
+
+// formatTime renders a microsecond duration the way tc does: three
+// significant digits in s or ms, whole microseconds below 1ms.
+func formatTime(us uint64) string {
+	switch {
+	case us >= 1_000_000:
+		return fmt.Sprintf("%.3gs", float64(us)/1e6)
+	case us >= 1000:
+		return fmt.Sprintf("%.3gms", float64(us)/1e3)
+	default:
+		return fmt.Sprintf("%dus", us)
+	}
+}
+
+// formatSize renders a byte count the way tc does: Mb or Kb when the value
+// is (nearly) a whole multiple, plain bytes otherwise.
+func formatSize(b uint64) string {
+	const kb, mb = 1024, 1024 * 1024
+	switch {
+	case b >= mb && absDiff(mb*((b+mb/2)/mb), b) < kb:
+		return fmt.Sprintf("%dMb", (b+mb/2)/mb)
+	case b >= kb && absDiff(kb*((b+kb/2)/kb), b) < 16:
+		return fmt.Sprintf("%dKb", (b+kb/2)/kb)
+	default:
+		return fmt.Sprintf("%db", b)
+	}
+}
+
+func absDiff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// collectNetlink dumps every qdisc over rtnetlink and returns the CAKE ones,
+// with cake_mq sub-queues aggregated exactly as parseText does.
+func collectNetlink(ctx context.Context) ([]types.CakeStats, error) {
+	c, err := netlink.Dial(unix.NETLINK_ROUTE, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		_ = c.SetDeadline(dl)
+	}
+
+	msgs, err := c.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  unix.RTM_GETQDISC,
+			Flags: netlink.Request | netlink.Dump,
+		},
+		Data: make([]byte, tcmsgLen), // AF_UNSPEC, all interfaces
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ifname := interfaceNames()
+	entries := entryPool.Get().(*[]qdiscEntry)
+	defer putEntries(entries)
+	parsed := *entries
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWQDISC {
+			continue
+		}
+		e, ok, err := decodeQdisc(m.Data, ifname)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errNetlinkDecode, err)
+		}
+		if ok {
+			parsed = append(parsed, e)
+		}
+	}
+	*entries = parsed
+	return assembleQdiscs(parsed), nil
+}
+
+// netlinkPermanent reports whether a collectNetlink error will recur on every
+// poll: no permission for the socket, no rtnetlink in this kernel or
+// sandbox, or a message layout the decoder does not understand.  Anything
+// else, such as EINTR, ENOBUFS or a poll timeout, is worth retrying.
+func netlinkPermanent(err error) bool {
+	return errors.Is(err, errNetlinkDecode) ||
+		errors.Is(err, os.ErrPermission) ||
+		errors.Is(err, unix.EPROTONOSUPPORT) ||
+		errors.Is(err, unix.EAFNOSUPPORT) ||
+		errors.Is(err, unix.EOPNOTSUPP)
+}
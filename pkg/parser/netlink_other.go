@@ -0,0 +1,19 @@
+//go:build !linux
+
+package parser
+
+import (
+	"context"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// collectNetlink is unavailable off Linux; CollectStats falls back to tc.
+func collectNetlink(context.Context) ([]types.CakeStats, error) {
+	return nil, errNetlinkUnsupported
+}
+
+// netlinkPermanent is always true off Linux: collectNetlink never works.
+func netlinkPermanent(error) bool {
+	return true
+}
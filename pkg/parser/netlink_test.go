@@ -0,0 +1,300 @@
+package parser
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/mdlayher/netlink"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// qdiscMsg builds an RTM_NEWQDISC payload the way the kernel lays it out.
+func qdiscMsg(t *testing.T, ifindex int32, handle, parent uint32, attrs func(*netlink.AttributeEncoder)) []byte {
+	t.Helper()
+	hdr := make([]byte, tcmsgLen)
+	binary.NativeEndian.PutUint32(hdr[4:8], uint32(ifindex))
+	binary.NativeEndian.PutUint32(hdr[8:12], handle)
+	binary.NativeEndian.PutUint32(hdr[12:16], parent)
+	ae := netlink.NewAttributeEncoder()
+	attrs(ae)
+	b, err := ae.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(hdr, b...)
+}
+
+// cakeAttrs encodes a diffserv3 cake qdisc with three tins, each tin's
+// counters offset by its index so mix-ups are visible.
+func cakeAttrs(kind string, sentBytes uint64) func(*netlink.AttributeEncoder) {
+	return func(ae *netlink.AttributeEncoder) {
+		ae.String(tcaKind, kind)
+		ae.Nested(tcaOpts, func(o *netlink.AttributeEncoder) error {
+			o.Uint64(tcaCakeBaseRate64, 12_500_000) // 100Mbit
+			o.Uint32(tcaCakeDiffservMode, 0)        // diffserv3
+			o.Uint32(tcaCakeATM, 2)                 // ptm
+			o.Uint32(tcaCakeFlowMode, 7)            // triple-isolate
+			o.Uint32(tcaCakeOverhead, uint32(0xFFFFFFFC))
+			o.Uint32(tcaCakeRTT, 100_000)
+			o.Uint32(tcaCakeNAT, 1)
+			o.Uint32(tcaCakeMPU, 84)
+			o.Uint32(tcaCakeMemory, 32<<20)
+			o.Uint32(tcaCakeFwmark, 0xfc)
+			o.Uint32(tcaCakeAckFilter, 0)
+			o.Uint32(tcaCakeSplitGSO, 1)
+			return nil
+		})
+		ae.Nested(tcaStats2, func(s *netlink.AttributeEncoder) error {
+			basic := make([]byte, 16)
+			binary.NativeEndian.PutUint64(basic[0:8], sentBytes)
+			binary.NativeEndian.PutUint32(basic[8:12], 4096)
+			s.Bytes(tcaStatsBasic, basic)
+			queue := make([]byte, 20)
+			for i, v := range []uint32{3, 4542, 28, 1, 99} {
+				binary.NativeEndian.PutUint32(queue[i*4:], v)
+			}
+			s.Bytes(tcaStatsQueue, queue)
+			s.Nested(tcaStatsApp, func(x *netlink.AttributeEncoder) error {
+				x.Uint64(cakeStatsCapacityEstimate64, 12_500_000)
+				x.Uint32(cakeStatsMemoryLimit, 32<<20)
+				x.Uint32(cakeStatsMemoryUsed, 238656)
+				x.Uint32(cakeStatsAvgNetoff, 14)
+				x.Uint32(cakeStatsMinNetlen, 28)
+				x.Uint32(cakeStatsMaxNetlen, 1500)
+				x.Uint32(cakeStatsMinAdjlen, 56)
+				x.Uint32(cakeStatsMaxAdjlen, 1528)
+				x.Nested(cakeStatsTinStats, func(tins *netlink.AttributeEncoder) error {
+					for i := uint32(0); i < 3; i++ {
+						tins.Nested(uint16(i+1), func(tin *netlink.AttributeEncoder) error {
+							tin.Uint32(tinSentPackets, 100+i)
+							tin.Uint64(tinSentBytes64, 1000+uint64(i))
+							tin.Uint32(tinDroppedPackets, i)
+							tin.Uint32(tinAcksDroppedPackets, 10+i)
+							tin.Uint32(tinECNMarkedPackets, 20+i)
+							tin.Uint32(tinBacklogBytes, 0)
+							tin.Uint64(tinThresholdRate64, 390_625) // 3125Kbit
+							tin.Uint32(tinTargetUs, 5810)
+							tin.Uint32(tinIntervalUs, 100_000)
+							tin.Uint32(tinWayIndirectHits, 30+i)
+							tin.Uint32(tinWayMisses, 40+i)
+							tin.Uint32(tinWayCollisions, i)
+							tin.Uint32(tinPeakDelayUs, 545)
+							tin.Uint32(tinAvgDelayUs, 42+i)
+							tin.Uint32(tinBaseDelayUs, 2)
+							tin.Uint32(tinSparseFlows, 1)
+							tin.Uint32(tinBulkFlows, 2)
+							tin.Uint32(tinUnresponsiveFlows, 0)
+							tin.Uint32(tinMaxSkblen, 1514)
+							tin.Uint32(tinFlowQuantum, 1514)
+							return nil
+						})
+					}
+					return nil
+				})
+				return nil
+			})
+			return nil
+		})
+	}
+}
+
+func testIfname(idx int) string { return map[int]string{2: "eth0", 3: "ifb4eth0"}[idx] }
+
+func TestDecodeQdisc_Cake(t *testing.T) {
+	data := qdiscMsg(t, 2, 0x80110000, tcRoot, cakeAttrs("cake", 1<<40))
+	e, ok, err := decodeQdisc(data, testIfname)
+	if err != nil || !ok {
+		t.Fatalf("decodeQdisc: ok=%v err=%v", ok, err)
+	}
+	if e.isCakeMQ || e.parentHandle != "" {
+		t.Errorf("root cake: isCakeMQ=%v parentHandle=%q", e.isCakeMQ, e.parentHandle)
+	}
+	cs := e.cs
+	assertEqual(t, "interface", "eth0", cs.Interface)
+	assertEqual(t, "handle", "8011", cs.Handle)
+	assertEqual(t, "direction", "egress", cs.Direction)
+	assertEqual(t, "bandwidth", "100Mbit", cs.Bandwidth)
+	assertUint(t, "bandwidth_bps", 100_000_000, cs.BandwidthBps)
+	assertEqual(t, "diffserv_mode", "diffserv3", cs.DiffservMode)
+	assertEqual(t, "atm_mode", "ptm", cs.ATMMode)
+	assertEqual(t, "dual_mode", "triple-isolate", cs.DualMode)
+	assertEqual(t, "overhead", "-4", cs.Overhead)
+	assertEqual(t, "rtt", "100ms", cs.RTT)
+	assertEqual(t, "mpu", "84", cs.MPU)
+	assertEqual(t, "memlimit", "32Mb", cs.MemLimit)
+	assertUint(t, "memlimit_bytes", 32<<20, cs.MemLimitBytes)
+	assertEqual(t, "fwmark_mask", "0xfc", cs.FwmarkMask)
+	assertEqual(t, "backlog_bytes", "4542b", cs.BacklogBytes)
+	assertEqual(t, "memory_used", "238656b", cs.MemoryUsed)
+	assertEqual(t, "memory_total", "32Mb", cs.MemoryTotal)
+	assertEqual(t, "capacity_estimate", "100Mbit", cs.CapacityEst)
+	assertEqual(t, "max_adj_size", "1528", cs.MaxAdjSize)
+	assertEqual(t, "avg_hdr_offset", "14", cs.AvgHdrOffset)
+	assertUint(t, "sent_bytes", 1<<40, cs.SentBytes)
+	assertUint(t, "sent_pkts", 4096, cs.SentPkts)
+	assertUint(t, "backlog_pkts", 3, cs.BacklogPkts)
+	assertUint(t, "dropped", 28, cs.Dropped)
+	assertUint(t, "requeues", 1, cs.Requeues)
+	assertUint(t, "overlimits", 99, cs.Overlimits)
+	if !cs.SplitGSO || !cs.NoAckFilter {
+		t.Errorf("split_gso=%v no_ack_filter=%v, want both true", cs.SplitGSO, cs.NoAckFilter)
+	}
+	if !cs.NATEnabled || cs.WashEnabled || cs.MPUBytes != 84 || cs.DiffservCode != 3 {
+		t.Errorf("nat=%v wash=%v mpu_bytes=%d diffserv_code=%d", cs.NATEnabled, cs.WashEnabled, cs.MPUBytes, cs.DiffservCode)
+	}
+
+	if len(cs.Tiers) != 3 {
+		t.Fatalf("want 3 tiers, got %d", len(cs.Tiers))
+	}
+	for i, name := range []string{"Bulk", "Best Effort", "Voice"} {
+		assertEqual(t, "tier name", name, cs.Tiers[i].Name)
+	}
+	tier := cs.Tiers[1]
+	assertEqual(t, "thresh", "3125Kbit", tier.Thresh)
+	assertEqual(t, "target", "5.81ms", tier.Target)
+	assertEqual(t, "interval", "100ms", tier.Interval)
+	assertEqual(t, "pk_delay", "545us", tier.PkDelay)
+	assertEqual(t, "av_delay", "43us", tier.AvDelay)
+	assertEqual(t, "sp_delay", "2us", tier.SpDelay)
+	assertEqual(t, "backlog", "0b", tier.Backlog)
+	assertUint(t, "pkts", 101, tier.Pkts)
+	assertUint(t, "bytes", 1001, tier.Bytes)
+	assertUint(t, "drops", 1, tier.Drops)
+	assertUint(t, "ack_drop", 11, tier.AckDrop)
+	assertUint(t, "marks", 21, tier.Marks)
+	assertUint(t, "way_inds", 31, tier.WayInds)
+	assertUint(t, "way_miss", 41, tier.WayMiss)
+	assertUint(t, "way_cols", 1, tier.WayCols)
+	assertUint(t, "max_len", 1514, tier.MaxLen)
+	assertUint(t, "quantum", 1514, tier.Quantum)
+}
+
+func TestDecodeQdisc_SkipsOtherKinds(t *testing.T) {
+	data := qdiscMsg(t, 2, 0, tcRoot, func(ae *netlink.AttributeEncoder) { ae.String(tcaKind, "fq_codel") })
+	if _, ok, err := decodeQdisc(data, testIfname); ok || err != nil {
+		t.Errorf("fq_codel: ok=%v err=%v, want skipped", ok, err)
+	}
+	if _, _, err := decodeQdisc(data[:10], testIfname); err == nil {
+		t.Error("truncated tcmsg: want error")
+	}
+}
+
+// TestDecodeQdisc_CakeMQ checks that netlink entries go through the same
+// cake_mq aggregation as the text parser, and that an IFB without the
+// ingress option is still reported as ingress.
+func TestDecodeQdisc_CakeMQ(t *testing.T) {
+	var parsed []qdiscEntry
+	for _, m := range [][]byte{
+		qdiscMsg(t, 3, 0x80010000, tcRoot, cakeAttrs("cake_mq", 0)),
+		qdiscMsg(t, 3, 0x80020000, 0x80010001, cakeAttrs("cake", 1000)),
+		qdiscMsg(t, 3, 0x80030000, 0x80010002, cakeAttrs("cake", 2000)),
+	} {
+		e, ok, err := decodeQdisc(m, testIfname)
+		if err != nil || !ok {
+			t.Fatalf("decodeQdisc: ok=%v err=%v", ok, err)
+		}
+		parsed = append(parsed, e)
+	}
+	if parsed[1].parentHandle != "8001" {
+		t.Fatalf("sub-queue parentHandle: want 8001, got %q", parsed[1].parentHandle)
+	}
+	stats := assembleQdiscs(parsed)
+	if len(stats) != 1 {
+		t.Fatalf("want 1 aggregated interface, got %d", len(stats))
+	}
+	cs := stats[0]
+	assertEqual(t, "interface", "ifb4eth0", cs.Interface)
+	assertEqual(t, "handle", "8001", cs.Handle)
+	assertEqual(t, "direction", "ingress", cs.Direction)
+	assertUint(t, "sent_bytes", 3000, cs.SentBytes)
+}
+
+func TestNetlinkFormatting(t *testing.T) {
+	for _, tc := range []struct{ got, want string }{
+		{formatRate(390_625), "3125Kbit"},
+		{formatRate(6_250_000), "50Mbit"},
+		{formatRate(125_000_000), "1Gbit"},
+		{formatRate(100), "800bit"},
+		{formatTime(0), "0us"},
+		{formatTime(42), "42us"},
+		{formatTime(5000), "5ms"},
+		{formatTime(5810), "5.81ms"},
+		{formatTime(1_500_000), "1.5s"},
+		{formatSize(0), "0b"},
+		{formatSize(238656), "238656b"},
+		{formatSize(14 << 10), "14Kb"},
+		{formatSize(32 << 20), "32Mb"},
+		{tinNames("diffserv8", 8)[7], "Tin 7"},
+		{tinNames("besteffort", 1)[0], "Tin 0"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("got %q, want %q", tc.got, tc.want)
+		}
+	}
+}
+
+func TestCollectStats_NetlinkFallback(t *testing.T) {
+	fakeTC(t, "cat <<'EOF'\n"+sampleTCOutput+"\nEOF")
+	captureLog(t)
+	netlinkOff.Store(false)
+	netlinkRetryAt.Store(0)
+	calls := 0
+	old := netlinkCollect
+	netlinkCollect = func(context.Context) ([]types.CakeStats, error) {
+		calls++
+		return nil, fmt.Errorf("dial: %w", os.ErrPermission)
+	}
+	t.Cleanup(func() { netlinkCollect = old })
+
+	for i := 0; i < 2; i++ {
+		stats, err := CollectStats(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stats) != len(parseText(sampleTCOutput)) {
+			t.Fatalf("poll %d: want tc fallback result, got %d interfaces", i, len(stats))
+		}
+	}
+	if calls != 1 {
+		t.Errorf("netlink should be tried once then abandoned, tried %d times", calls)
+	}
+}
+
+func TestCollectStats_NetlinkTransientRetried(t *testing.T) {
+	fakeTC(t, "cat <<'EOF'\n"+sampleTCOutput+"\nEOF")
+	captureLog(t)
+	netlinkOff.Store(false)
+	netlinkRetryAt.Store(0)
+	t.Cleanup(func() { netlinkRetryAt.Store(0); netlinkFailing.Store(false) })
+	calls := 0
+	fail := true
+	old := netlinkCollect
+	netlinkCollect = func(context.Context) ([]types.CakeStats, error) {
+		calls++
+		if fail {
+			return nil, context.DeadlineExceeded
+		}
+		return []types.CakeStats{{Interface: "eth0"}}, nil
+	}
+	t.Cleanup(func() { netlinkCollect = old })
+
+	for i := 0; i < 2; i++ {
+		if stats, err := CollectStats(context.Background()); err != nil || len(stats) != len(parseText(sampleTCOutput)) {
+			t.Fatalf("poll %d: want the tc result, got %d interfaces, err %v", i, len(stats), err)
+		}
+	}
+	if calls != 1 || netlinkOff.Load() {
+		t.Fatalf("want one attempt, then tc while backing off; calls=%d off=%v", calls, netlinkOff.Load())
+	}
+
+	// Once the backoff has passed netlink is used again.
+	fail = false
+	netlinkRetryAt.Store(0)
+	if stats, err := CollectStats(context.Background()); err != nil || len(stats) != 1 || calls != 2 {
+		t.Errorf("after the backoff: want the netlink result, got %d interfaces, err %v, calls %d", len(stats), err, calls)
+	}
+}
@@ -1,21 +1,26 @@
 package parser
 
-// The current implementation of CollectStats shells out to `tc` and prefers
-// the JSON output (tc -j).  If even lower latency is required the
-// implementation can be swapped to use a netlink client such as
-// github.com/jsimonetti/rtnetlink to query qdisc statistics directly,
-// avoiding fork/exec entirely.
+// CollectStats queries the kernel over rtnetlink (see netlink.go) and falls
+// back to shelling out to `tc -s qdisc` when netlink is unavailable, for
+// example on non-Linux builds or inside restricted sandboxes.
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 
+	"github.com/galpt/cake-stats/pkg/log"
 	"github.com/galpt/cake-stats/pkg/types"
 	"github.com/galpt/cake-stats/pkg/util"
 )
@@ -37,28 +42,305 @@ func supportsJSON() bool {
 	return jsonSupport
 }
 
-// CollectStats polls the kernel via `tc` and returns a slice of CakeStats.
-// Always uses the human-readable text output from `tc -s qdisc` for maximum
-// field coverage.  The JSON path (tc -j) is intentionally avoided because the
-// JSON tin representation omits many fields that the text output provides
-// (tier names, target, interval, delay values, per-tier packet counters, etc.).
+// CollectStats polls the kernel and returns a slice of CakeStats.
+//
+// It first dumps qdiscs over rtnetlink, which costs no process spawn.  When
+// that fails it logs why and runs `tc -s qdisc` instead, parsing its
+// human-readable text output: for good if netlink cannot work here (no
+// permission or support), otherwise until it is retried a little later.
+// The JSON output (tc -j, see ParseJSON) carries every per-tin field on
+// iproute2 5.14+ but not on older versions, so the text output is used.
 func CollectStats(ctx context.Context) ([]types.CakeStats, error) {
-	out, err := exec.CommandContext(ctx, "tc", "-s", "qdisc").Output()
+	return Collector{}.Collect(ctx)
+}
+
+// CollectStatsForDevice is CollectStats restricted to the qdiscs on iface.
+// On the tc path it runs `tc -s qdisc show dev <iface>`, so the kernel only
+// reports that device and the parser sees a fraction of the output.  On the
+// netlink path the full dump is filtered here instead; see CollectDevices for
+// polling several devices with one dump.
+func CollectStatsForDevice(ctx context.Context, iface string) ([]types.CakeStats, error) {
+	return Collector{}.CollectDevice(ctx, iface)
+}
+
+// Collector polls CAKE statistics.  The zero value collects in the current
+// network namespace and is what CollectStats uses.
+type Collector struct {
+	// Netns runs tc inside another network namespace: a name under
+	// /run/netns goes through `ip netns exec <ns>`, anything containing a
+	// slash is treated as a namespace file and goes through
+	// `nsenter --net=<path>`.  The netlink socket is bound to our own
+	// namespace, so a Collector with Netns set always uses tc.
+	Netns string
+}
+
+// Collect is CollectStats for c's namespace.
+func (c Collector) Collect(ctx context.Context) ([]types.CakeStats, error) {
+	if c.Netns == "" {
+		if stats, ok := tryNetlink(ctx); ok {
+			return stats, nil
+		}
+	}
+	return c.collectExec(ctx)
+}
+
+// CollectDevice is CollectStatsForDevice for c's namespace.
+func (c Collector) CollectDevice(ctx context.Context, iface string) ([]types.CakeStats, error) {
+	var stats []types.CakeStats
+	ok := false
+	if c.Netns == "" {
+		stats, ok = tryNetlink(ctx)
+	}
+	if !ok {
+		var err error
+		if stats, err = c.collectExec(ctx, "show", "dev", iface); err != nil {
+			return nil, err
+		}
+	}
+	return keepDevices(stats, []string{iface}), nil
+}
+
+// CollectDevices is CollectDevice for several interfaces.  On the netlink
+// path one dump serves every device and the results keep the kernel's order;
+// on the tc path each device gets its own `tc -s qdisc show dev`, run
+// concurrently, and the results follow ifaces.  An error names the device
+// that failed.
+func (c Collector) CollectDevices(ctx context.Context, ifaces []string) ([]types.CakeStats, error) {
+	if c.Netns == "" {
+		if stats, ok := tryNetlink(ctx); ok {
+			return keepDevices(stats, ifaces), nil
+		}
+	}
+	results := make([][]types.CakeStats, len(ifaces))
+	errs := make([]error, len(ifaces))
+	var wg sync.WaitGroup
+	for i, iface := range ifaces {
+		wg.Go(func() { results[i], errs[i] = c.collectExec(ctx, "show", "dev", iface) })
+	}
+	wg.Wait()
+	var stats []types.CakeStats
+	for i, iface := range ifaces {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("%s: %w", iface, errs[i])
+		}
+		stats = append(stats, keepDevices(results[i], ifaces[i:i+1])...)
+		ReleaseStats(results[i])
+	}
+	return stats, nil
+}
+
+// keepDevices filters stats in place down to the entries of ifaces.
+func keepDevices(stats []types.CakeStats, ifaces []string) []types.CakeStats {
+	out := stats[:0]
+	for _, cs := range stats {
+		if slices.Contains(ifaces, cs.Interface) {
+			out = append(out, cs)
+		}
+	}
+	return out
+}
+
+// command returns the program and arguments that run `<tc> args...` in c's
+// namespace.
+func (c Collector) command(tc string, args []string) (string, []string) {
+	switch {
+	case c.Netns == "":
+		return tc, args
+	case strings.ContainsRune(c.Netns, '/'):
+		return nsenterCommand, append([]string{"--net=" + c.Netns, tc}, args...)
+	default:
+		return ipCommand, append([]string{"netns", "exec", c.Netns, tc}, args...)
+	}
+}
+
+var (
+	// netlinkCollect is the netlink collector; tests replace it.
+	netlinkCollect = collectNetlink
+	// netlinkOff is set once netlink has failed in a way that will not go
+	// away (see netlinkPermanent), so every later poll goes straight to tc.
+	netlinkOff atomic.Bool
+	// netlinkRetryAt is when netlink is tried again after a transient
+	// failure, in Unix nanoseconds; polls before then use tc.
+	netlinkRetryAt atomic.Int64
+	// netlinkFailing is set from a transient failure until the next
+	// successful dump, so a flapping socket is logged once per outage.
+	netlinkFailing atomic.Bool
+)
+
+// netlinkRetryDelay is how long polls use tc after a transient netlink
+// failure before netlink is tried again.
+const netlinkRetryDelay = 30 * time.Second
+
+// tryNetlink runs the netlink collector unless it has failed for good or is
+// backing off after a transient failure.  ok is false when the caller should
+// fall back to tc.
+func tryNetlink(ctx context.Context) (stats []types.CakeStats, ok bool) {
+	if netlinkOff.Load() || time.Now().UnixNano() < netlinkRetryAt.Load() {
+		return nil, false
+	}
+	stats, err := netlinkCollect(ctx)
+	switch {
+	case err == nil:
+		if netlinkFailing.Swap(false) {
+			log.Logger.Info().Msg("netlink qdisc dump recovered")
+		}
+		return stats, true
+	case netlinkPermanent(err):
+		netlinkOff.Store(true)
+		log.Logger.Info().Err(err).Msg("netlink qdisc dump unavailable, falling back to tc")
+	default:
+		netlinkRetryAt.Store(time.Now().Add(netlinkRetryDelay).UnixNano())
+		if !netlinkFailing.Swap(true) {
+			log.Logger.Info().Err(err).Dur("retry_in", netlinkRetryDelay).Msg("netlink qdisc dump failed, using tc until it is retried")
+		}
+	}
+	return nil, false
+}
+
+// collectExec runs `tc -s qdisc [extra...]` and parses its text output.
+func (c Collector) collectExec(ctx context.Context, extra ...string) ([]types.CakeStats, error) {
+	tc, err := lookTC()
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"-s", "qdisc"}, extra...)
+	name, argv := c.command(tc, args)
+	cmd := execCommand(ctx, name, argv...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	start := time.Now()
+	out, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("tc -s qdisc: %w", err)
+		if errors.Is(err, os.ErrNotExist) {
+			// tc was moved or removed since it was resolved.
+			tcResolved.Store(nil)
+		}
+		return nil, execError(cmd, args, err, time.Since(start), strings.TrimSpace(stderr.String()))
 	}
+	lastExecFailure.Store("")
 	return parseText(util.BytesToString(out)), nil
 }
 
-// parseJSON handles the JSON output from "tc -j -s qdisc".  We don't try to
-// mirror every field the kernel sends; the goal is to populate a minimal
-// CakeStats value with the same information our text parser would produce.
-func parseJSON(raw []byte) ([]types.CakeStats, error) {
+// ErrTCNotFound is returned when tc is neither on PATH nor in any of the
+// usual sbin directories, which service managers often leave off PATH.
+var ErrTCNotFound = errors.New("tc binary not found")
+
+// resolvedTC is a path found by lookTC and the tcCommand it was found for.
+type resolvedTC struct{ command, path string }
+
+// tcResolved caches lookTC so PATH is not searched on every poll.
+var tcResolved atomic.Pointer[resolvedTC]
+
+// lookTC resolves tcCommand, trying tcCandidates when it is not on PATH.
+// The result is cached; a failure is not, so tc installed later is found.
+func lookTC() (string, error) {
+	if r := tcResolved.Load(); r != nil && r.command == tcCommand {
+		return r.path, nil
+	}
+	for _, name := range append([]string{tcCommand}, tcCandidates...) {
+		if path, err := exec.LookPath(name); err == nil {
+			tcResolved.Store(&resolvedTC{command: tcCommand, path: path})
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%w (searched PATH and %s)", ErrTCNotFound, strings.Join(tcCandidates, ", "))
+}
+
+// ErrPermissionDenied is wrapped into the CollectStats error when tc was
+// refused access to the kernel, typically because cake-stats lacks
+// CAP_NET_ADMIN.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// permissionHint is logged alongside ErrPermissionDenied failures.
+const permissionHint = "run cake-stats as root or grant it CAP_NET_ADMIN " +
+	"(e.g. setcap cap_net_admin+ep /path/to/cake-stats)"
+
+// execError logs a failed tc run and builds the error returned to the caller,
+// including tc's own stderr text, which says far more than "exit status 2".
+func execError(cmd *exec.Cmd, args []string, err error, elapsed time.Duration, stderr string) error {
+	perm := isPermissionError(err, stderr)
+	logExecFailure(cmd, err, elapsed, stderr, perm)
+	msg := "tc " + strings.Join(args, " ")
+	if stderr != "" {
+		err = fmt.Errorf("%w: %s", err, stderr)
+	}
+	if perm {
+		return fmt.Errorf("%s: %w: %w", msg, ErrPermissionDenied, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// isPermissionError reports whether a tc failure was EPERM/EACCES, either
+// from the kernel (reported on stderr) or from exec itself.
+func isPermissionError(err error, stderr string) bool {
+	return errors.Is(err, os.ErrPermission) ||
+		strings.Contains(stderr, "Operation not permitted") ||
+		strings.Contains(stderr, "Permission denied")
+}
+
+// lastExecFailure remembers the previous failure's exit code and stderr so an
+// identical failure on every poll is logged once rather than ten times a
+// second.  Cleared by the next successful run.
+var lastExecFailure atomic.Value
+
+var (
+	// tcCommand is the tc binary CollectStats runs; tests point it at a fake.
+	tcCommand = "tc"
+	// tcCandidates are tried in order when tcCommand is not on PATH.
+	tcCandidates = []string{"/sbin/tc", "/usr/sbin/tc", "/usr/bin/tc"}
+	// ipCommand and nsenterCommand wrap tc for Collector.Netns.
+	ipCommand      = "ip"
+	nsenterCommand = "nsenter"
+	// execCommand builds every tc invocation; tests replace it to inspect
+	// the argument list.
+	execCommand = exec.CommandContext
+)
+
+// logExecFailure emits one structured warning describing a failed tc run so
+// the cause (missing binary, permissions, bad kernel module) is visible
+// without re-running the command by hand.  Repeats of the previous failure
+// are skipped.
+func logExecFailure(cmd *exec.Cmd, err error, elapsed time.Duration, stderr string, perm bool) {
+	exitCode := -1
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		exitCode = ee.ExitCode()
+	}
+	key := fmt.Sprintf("%d\x00%s", exitCode, stderr)
+	if prev, _ := lastExecFailure.Swap(key).(string); prev == key {
+		return
+	}
+	dir := cmd.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	ev := log.Logger.Warn().
+		Err(err).
+		Str("path", cmd.Path).
+		Str("dir", dir).
+		Int("exit_code", exitCode).
+		Dur("elapsed", elapsed).
+		Str("stderr", stderr)
+	if perm {
+		ev = ev.Str("hint", permissionHint)
+	}
+	ev.Msg("tc exec failed")
+}
+
+// ParseJSON parses the JSON output of "tc -j -s qdisc" into one CakeStats per
+// CAKE qdisc; other qdisc kinds are skipped.  Every per-tin field of
+// iproute2 5.14+ is decoded (see parseJSONTin), so each CakeTier is as
+// complete as the text parser's; tier names, which the JSON lacks, come
+// from the diffserv mode.
+//
+// It returns the json.Unmarshal error for malformed input, and an empty,
+// non-nil slice when the input holds no CAKE qdiscs.
+func ParseJSON(raw []byte) ([]types.CakeStats, error) {
 	var arr []map[string]interface{}
 	if err := json.Unmarshal(raw, &arr); err != nil {
 		return nil, err
 	}
-	var out []types.CakeStats
+	out := make([]types.CakeStats, 0, len(arr))
 	for _, obj := range arr {
 		if kind, _ := obj["kind"].(string); kind != "cake" {
 			continue
@@ -75,12 +357,14 @@ func parseJSON(raw []byte) ([]types.CakeStats, error) {
 				if bw > 0 {
 					// JSON bandwidth is in bytes/sec; convert to Mbit/s for display.
 					cs.Bandwidth = fmt.Sprintf("%dMbit", int64(bw)*8/1_000_000)
+					cs.BandwidthBps = uint64(bw) * 8
 				} else {
 					cs.Bandwidth = "unlimited"
 				}
 			}
 			if ds, ok := opts["diffserv"].(string); ok {
 				cs.DiffservMode = ds
+				cs.DiffservCode = diffservCodeMap[ds]
 			}
 			if nat, ok := opts["nat"].(bool); ok {
 				cs.NATEnabled = nat
@@ -88,6 +372,16 @@ func parseJSON(raw []byte) ([]types.CakeStats, error) {
 			if wash, ok := opts["wash"].(bool); ok {
 				cs.WashEnabled = wash
 			}
+			if v, ok := getUint(opts, "memlimit"); ok && v > 0 {
+				cs.MemLimit = formatSize(v)
+				cs.MemLimitBytes = v
+			}
+			if gso, ok := opts["split_gso"].(bool); ok {
+				cs.SplitGSO = gso
+			}
+			if af, ok := opts["ack-filter"].(string); ok {
+				cs.NoAckFilter = af == "disabled"
+			}
 			// The tc JSON output does not currently emit an "atm" key, but handle
 			// it defensively in case future iproute2 versions add it.
 			if atm, ok := opts["atm"].(string); ok && atm != "" {
@@ -95,6 +389,7 @@ func parseJSON(raw []byte) ([]types.CakeStats, error) {
 			}
 			if v, ok := getUint(opts, "mpu"); ok && v > 0 {
 				cs.MPU = fmt.Sprintf("%d", v)
+				cs.MPUBytes = mpuBytes(cs.MPU)
 			}
 			if ov, ok := opts["overhead"].(float64); ok {
 				cs.Overhead = fmt.Sprintf("%v", int64(ov))
@@ -141,78 +436,181 @@ func parseJSON(raw []byte) ([]types.CakeStats, error) {
 			var tiers []types.CakeTier
 			for _, ti := range tins {
 				if m, ok := ti.(map[string]interface{}); ok {
-					var t types.CakeTier
-					if thr, ok := getUint(m, "threshold_rate"); ok {
-						t.Thresh = fmt.Sprintf("%d", thr)
-					}
-					if sb, ok := getUint(m, "sent_bytes"); ok {
-						t.Bytes = sb
-					}
-					if dr, ok := getUint(m, "drops"); ok {
-						t.Drops = dr
-					}
-					if ml, ok := getUint(m, "max_pkt_len"); ok {
-						t.MaxLen = ml
-					}
-					if q, ok := getUint(m, "flow_quantum"); ok {
-						t.Quantum = q
-					}
-					tiers = append(tiers, t)
+					tiers = append(tiers, parseJSONTin(m))
 				}
 			}
+			names := tinNames(cs.DiffservMode, len(tiers))
+			for i := range tiers {
+				tiers[i].Name = names[i]
+			}
 			cs.Tiers = tiers
+			cs.MaxFlows = setFlowTotals(tiers)
 		}
 		out = append(out, cs)
 	}
 	return out, nil
 }
 
-func parseText(raw string) []types.CakeStats {
-	lines := util.Split(raw, "\n")
-	type block struct{ lines []string }
-	var blocks []block
-	cur := block{}
-	for _, l := range lines {
-		if strings.HasPrefix(l, "qdisc ") && len(cur.lines) > 0 {
+// parseJSONTin converts one element of the "tins" array.  iproute2 5.14+
+// emits every per-tin counter; rates, times and sizes arrive as raw numbers
+// (bytes/s, µs, bytes) and are rendered the way the text output prints them.
+// Keys missing from older iproute2 versions leave the field zero.
+func parseJSONTin(m map[string]interface{}) types.CakeTier {
+	t := types.CakeTier{}
+	if v, ok := getUint(m, "threshold_rate"); ok {
+		t.Thresh = formatRate(v)
+	}
+	for key, dst := range map[string]*string{
+		"target_us":     &t.Target,
+		"interval_us":   &t.Interval,
+		"peak_delay_us": &t.PkDelay,
+		"avg_delay_us":  &t.AvDelay,
+		"base_delay_us": &t.SpDelay,
+	} {
+		if v, ok := getUint(m, key); ok {
+			*dst = formatTime(v)
+		}
+	}
+	if v, ok := getUint(m, "backlog_bytes"); ok {
+		t.Backlog = formatSize(v)
+	}
+	for key, dst := range map[string]*uint64{
+		"sent_packets":       &t.Pkts,
+		"sent_bytes":         &t.Bytes,
+		"way_indirect_hits":  &t.WayInds,
+		"way_misses":         &t.WayMiss,
+		"way_collisions":     &t.WayCols,
+		"drops":              &t.Drops,
+		"ecn_mark":           &t.Marks,
+		"ack_drops":          &t.AckDrop,
+		"sparse_flows":       &t.SpFlows,
+		"bulk_flows":         &t.BkFlows,
+		"unresponsive_flows": &t.UnFlows,
+		"max_pkt_len":        &t.MaxLen,
+		"flow_quantum":       &t.Quantum,
+	} {
+		if v, ok := getUint(m, key); ok {
+			*dst = v
+		}
+	}
+	return t
+}
+
+// SplitBlocks splits raw `tc -s qdisc` output into one line group per qdisc:
+// each group starts at a "qdisc " header line and runs up to the next one.
+// The groups can be filtered and handed to ParseBlock individually.  Empty or
+// whitespace-only input yields an empty (non-nil) slice.
+func SplitBlocks(raw string) [][]string {
+	blocks := [][]string{}
+	if util.TrimSpace(raw) == "" {
+		return blocks
+	}
+	var cur []string
+	for _, l := range util.Split(raw, "\n") {
+		if strings.HasPrefix(l, "qdisc ") && len(cur) > 0 {
 			blocks = append(blocks, cur)
-			cur = block{}
+			cur = nil
 		}
-		cur.lines = append(cur.lines, l)
+		cur = append(cur, l)
 	}
-	if len(cur.lines) > 0 {
+	if len(cur) > 0 {
 		blocks = append(blocks, cur)
 	}
+	return blocks
+}
 
-	// Intermediate parse result annotated with routing metadata.
-	type blockResult struct {
-		cs           types.CakeStats
-		parentHandle string // non-empty for cake sub-queues attached to a cake_mq
-		isCakeMQ     bool   // true for the cake_mq parent qdisc block
+func parseText(raw string) []types.CakeStats {
+	// tc exits 0 with no output when, for example, sch_cake is not loaded and
+	// no qdiscs are listed.  That is "no CAKE interfaces", not an error.
+	if util.TrimSpace(raw) == "" {
+		return nil
 	}
-	var parsed []blockResult
+	blocks := SplitBlocks(raw)
 
+	entries := entryPool.Get().(*[]qdiscEntry)
+	defer putEntries(entries)
+	parsed := *entries
 	for _, b := range blocks {
-		if len(b.lines) == 0 {
+		if len(b) == 0 {
 			continue
 		}
-		header := b.lines[0]
+		header := b[0]
 		switch {
 		case strings.Contains(header, "qdisc cake_mq "):
 			// cake_mq parent block: parse header only for handle/interface/direction.
-			if cs, ok := parseCakeBlock(b.lines); ok {
-				parsed = append(parsed, blockResult{cs: cs, isCakeMQ: true})
+			if cs, ok := parseCakeBlock(b); ok {
+				parsed = append(parsed, qdiscEntry{cs: cs, isCakeMQ: true})
 			}
 		case strings.Contains(header, "qdisc cake "):
 			// Traditional standalone cake OR a cake sub-qdisc under cake_mq.
-			if cs, ok := parseCakeBlock(b.lines); ok {
-				parsed = append(parsed, blockResult{
+			if cs, ok := parseCakeBlock(b); ok {
+				parsed = append(parsed, qdiscEntry{
 					cs:           cs,
 					parentHandle: headerParentHandle(header),
 				})
 			}
 		}
 	}
+	*entries = parsed
+	return assembleQdiscs(parsed)
+}
+
+// statsPool recycles the slices assembleQdiscs returns; see ReleaseStats.
+// The *[]CakeStats that carried a slice out of statsPool waits, emptied, in
+// boxPool until ReleaseStats needs one to put a slice back, so releasing
+// does not allocate a new header.  entryPool recycles the qdiscEntry scratch
+// slices of one collection.
+var (
+	statsPool = sync.Pool{New: func() any { s := make([]types.CakeStats, 0, 8); return &s }}
+	boxPool   sync.Pool
+	entryPool = sync.Pool{New: func() any { s := make([]qdiscEntry, 0, 8); return &s }}
+)
+
+// getStats takes an empty slice from statsPool.
+func getStats() []types.CakeStats {
+	box := statsPool.Get().(*[]types.CakeStats)
+	stats := (*box)[:0]
+	*box = nil
+	boxPool.Put(box)
+	return stats
+}
+
+// ReleaseStats hands a slice returned by a collector back for reuse by a
+// later collection, saving the allocation on the next poll.  Neither stats
+// nor any slice sharing its array may be used afterwards.  Releasing is
+// optional: a slice that is never released is simply garbage collected.
+func ReleaseStats(stats []types.CakeStats) {
+	if cap(stats) == 0 {
+		return
+	}
+	// Drop the strings and tiers the old elements point at.
+	clear(stats[:cap(stats)])
+	box, _ := boxPool.Get().(*[]types.CakeStats)
+	if box == nil {
+		box = new([]types.CakeStats)
+	}
+	*box = stats[:0]
+	statsPool.Put(box)
+}
+
+func putEntries(entries *[]qdiscEntry) {
+	clear((*entries)[:cap(*entries)])
+	*entries = (*entries)[:0]
+	entryPool.Put(entries)
+}
+
+// qdiscEntry is one parsed cake or cake_mq qdisc annotated with the routing
+// metadata assembleQdiscs needs.  Both the text and the netlink collectors
+// produce these.
+type qdiscEntry struct {
+	cs           types.CakeStats
+	parentHandle string // non-empty for cake sub-queues attached to a cake_mq
+	isCakeMQ     bool   // true for the cake_mq parent qdisc
+}
 
+// assembleQdiscs folds cake sub-queues into their cake_mq parent and returns
+// one CakeStats per logical CAKE instance, preserving input order.
+func assembleQdiscs(parsed []qdiscEntry) []types.CakeStats {
 	// Build a lookup from (interface, major-handle) to the cake_mq parent entry.
 	type ifaceHandle struct{ iface, handle string }
 	mqParents := make(map[ifaceHandle]types.CakeStats)
@@ -233,8 +631,8 @@ func parseText(raw string) []types.CakeStats {
 		}
 	}
 
-	// Emit results, preserving original block order.
-	var result []types.CakeStats
+	// Emit results, preserving original order.
+	result := getStats()
 	emittedMQ := make(map[ifaceHandle]bool)
 	for _, r := range parsed {
 		switch {
@@ -256,6 +654,9 @@ func parseText(raw string) []types.CakeStats {
 				continue
 			}
 			// Orphaned sub-qdisc (no cake_mq parent visible) – emit verbatim.
+			// Its direction was already resolved by parseHeader from the
+			// "ingress" keyword or the ifb* interface-name fallback, so an
+			// orphan on an IFB is not mislabelled egress.
 			result = append(result, r.cs)
 		default:
 			// Standalone root cake qdisc.
@@ -267,6 +668,16 @@ func parseText(raw string) []types.CakeStats {
 
 // --- helpers below ---
 
+// ParseBlock parses a single `tc -s qdisc` block — the "qdisc cake ..." header
+// line followed by its indented statistics lines — into a CakeStats.  It is
+// intended for callers that receive tc output incrementally (for example over
+// an SSH stream) and split it themselves.  The boolean is false when lines is
+// empty.  cake_mq sub-queue aggregation needs the whole tc output and is
+// therefore not applied here.
+func ParseBlock(lines []string) (types.CakeStats, bool) {
+	return parseCakeBlock(lines)
+}
+
 func parseCakeBlock(lines []string) (types.CakeStats, bool) {
 	if len(lines) == 0 {
 		return types.CakeStats{}, false
@@ -320,6 +731,7 @@ func parseCakeBlock(lines []string) (types.CakeStats, bool) {
 	}
 	if len(tierNames) > 0 {
 		cs.Tiers = assembleTiers(tierNames, tierFieldBuf)
+		cs.MaxFlows = setFlowTotals(cs.Tiers)
 	}
 	return cs, true
 }
@@ -383,6 +795,14 @@ func parseHeader(cs *types.CakeStats, line string) {
 			cs.WashEnabled = true
 		case "nowash":
 			cs.WashEnabled = false
+		case "split-gso":
+			cs.SplitGSO = true
+		case "no-split-gso":
+			cs.SplitGSO = false
+		case "no-ack-filter":
+			cs.NoAckFilter = true
+		case "ack-filter", "ack-filter-aggressive":
+			cs.NoAckFilter = false
 		case "dual-srchost", "dual-dsthost", "triple-isolate", "single":
 			cs.DualMode = tok
 		case "ingress":
@@ -390,21 +810,83 @@ func parseHeader(cs *types.CakeStats, line string) {
 		case "memlimit":
 			if i+1 < len(fs) {
 				cs.MemLimit = fs[i+1]
+				cs.MemLimitBytes = util.ParseBytesStr(cs.MemLimit)
 				i++
 			}
 		}
 	}
-	// An IFB (Intermediate Functional Block) interface is always used to
-	// redirect ingress traffic.  Some tc / kernel builds omit the "ingress"
-	// keyword from the qdisc header even when the physical traffic direction is
-	// ingress (confirmed by segal_72's cake_mq output where ifb4eth1 showed
-	// no "ingress" token).  Fall back to interface-name detection so the
-	// frontend never mis-labels an IFB as [EGRESS].
+	cs.MPUBytes = mpuBytes(cs.MPU)
+	cs.BandwidthBps = parseBandwidthBps(cs.Bandwidth)
+	cs.DiffservCode = diffservCodeMap[cs.DiffservMode]
+	applyIFBDirection(cs)
+}
+
+// applyIFBDirection marks IFB interfaces as ingress.  An IFB (Intermediate
+// Functional Block) interface is always used to redirect ingress traffic.
+// Some tc / kernel builds omit the "ingress" keyword from the qdisc header
+// even when the physical traffic direction is ingress (confirmed by
+// segal_72's cake_mq output where ifb4eth1 showed no "ingress" token).  Fall
+// back to interface-name detection so the frontend never mis-labels an IFB
+// as [EGRESS].
+func applyIFBDirection(cs *types.CakeStats) {
 	if cs.Direction == "egress" && strings.HasPrefix(cs.Interface, "ifb") {
 		cs.Direction = "ingress"
 	}
 }
 
+// parseBandwidthBps converts a tc rate such as "50Mbit" or "22500Kbit" to bits
+// per second.  Units are decimal as tc prints them (bit, Kbit, Mbit, Gbit,
+// Tbit).  "autorate-ingress", "unlimited" and anything else unparseable
+// yield 0.
+func parseBandwidthBps(s string) uint64 {
+	num, ok := strings.CutSuffix(s, "bit")
+	if !ok {
+		return 0
+	}
+	mult := 1.0
+	if n := len(num); n > 0 {
+		switch num[n-1] {
+		case 'K':
+			mult = 1e3
+		case 'M':
+			mult = 1e6
+		case 'G':
+			mult = 1e9
+		case 'T':
+			mult = 1e12
+		}
+		if mult != 1 {
+			num = num[:n-1]
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return uint64(v*mult + 0.5)
+}
+
+// diffservCodeMap maps a CAKE diffserv keyword to its numeric DiffservCode.
+// The number follows the diffservN keyword; besteffort is a single tin and
+// precedence, which has no diffserv number, maps to 0.
+var diffservCodeMap = map[string]int{
+	"besteffort": 1,
+	"diffserv3":  3,
+	"diffserv4":  4,
+	"diffserv8":  8,
+	"precedence": 0,
+}
+
+// mpuBytes converts the MPU string to an int, returning 0 when it is empty or
+// not a number.
+func mpuBytes(mpu string) int {
+	n, err := strconv.Atoi(mpu)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
 func parseSentLine(cs *types.CakeStats, line string) {
 	fs := util.Fields(line)
 	if len(fs) >= 4 {
@@ -495,6 +977,17 @@ func parseTierNames(words []string) []string {
 	return names
 }
 
+// setFlowTotals fills in TotalFlows on every tier and returns the largest.
+func setFlowTotals(tiers []types.CakeTier) uint64 {
+	var most uint64
+	for i := range tiers {
+		t := &tiers[i]
+		t.TotalFlows = t.SpFlows + t.BkFlows + t.UnFlows
+		most = max(most, t.TotalFlows)
+	}
+	return most
+}
+
 func assembleTiers(names []string, buf map[string][]string) []types.CakeTier {
 	tiers := make([]types.CakeTier, len(names))
 	for i, name := range names {
@@ -536,10 +1029,17 @@ func assembleTiers(names []string, buf map[string][]string) []types.CakeTier {
 	return tiers
 }
 
+// getUint reads a non-negative integer counter from a decoded tc JSON object.
+// JSON null, missing keys and negative numbers (which a buggy kernel has been
+// seen to emit and which would otherwise wrap to a huge uint64) all report
+// (0, false).
 func getUint(m map[string]interface{}, key string) (uint64, bool) {
 	if v, ok := m[key]; ok {
 		switch t := v.(type) {
 		case float64:
+			if t < 0 {
+				return 0, false
+			}
 			return uint64(t), true
 		case string:
 			return util.ParseUint64(t), true
@@ -625,6 +1125,7 @@ func aggregateCakeMQSubQueues(parent types.CakeStats, subs []types.CakeStats) ty
 			queueTiers[i] = s.Tiers
 		}
 		agg.Tiers = aggregateCakeTiers(queueTiers)
+		agg.MaxFlows = setFlowTotals(agg.Tiers)
 	}
 	return agg
 }
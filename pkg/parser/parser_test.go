@@ -1,8 +1,14 @@
 package parser
 
 import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/galpt/cake-stats/pkg/types"
 	"github.com/galpt/cake-stats/pkg/util"
 )
 
@@ -87,6 +93,132 @@ func TestParseTCOutput_Count(t *testing.T) {
 	}
 }
 
+func TestReleaseStats_Reuse(t *testing.T) {
+	// UpdatedAt is the parse time, so it is cleared before comparing.
+	want := parseText(sampleTCOutput)
+	for i := range want {
+		want[i].UpdatedAt = time.Time{}
+	}
+	for range 3 {
+		got := parseText(sampleTCOutput)
+		for i := range got {
+			got[i].UpdatedAt = time.Time{}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("reused slice parsed differently:\n got %+v\nwant %+v", got, want)
+		}
+		ReleaseStats(got)
+	}
+	// A shorter result must not carry elements of a longer released one.
+	ReleaseStats(parseText(sampleTCOutput + sampleCakeMQ4Output))
+	if got := parseText(sampleTCOutput); len(got) != len(want) {
+		t.Errorf("want %d interfaces, got %d", len(want), len(got))
+	}
+}
+
+func TestReleaseStats_NoAlloc(t *testing.T) {
+	stats := parseText(sampleTCOutput)
+	allocs := testing.AllocsPerRun(100, func() {
+		ReleaseStats(stats)
+		stats = getStats()
+	})
+	if allocs != 0 {
+		t.Errorf("ReleaseStats: want 0 allocs, got %v", allocs)
+	}
+}
+
+// BenchmarkPollCycle parses one round of tc output, as every poll does, with
+// and without handing the result back through ReleaseStats.
+func BenchmarkPollCycle(b *testing.B) {
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_ = parseText(sampleTCOutput)
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			ReleaseStats(parseText(sampleTCOutput))
+		}
+	})
+}
+
+// benchTCOutput returns tc output with n CAKE interfaces: copies of the
+// eth1 qdisc from sampleTCOutput, and from n = 16 on, four of them replaced
+// by cake_mq instances of four queues each (sampleCakeMQ4Output), so the
+// aggregation path runs under load too.
+func benchTCOutput(n int) string {
+	start := strings.Index(sampleTCOutput, "qdisc cake 800d:")
+	end := strings.Index(sampleTCOutput, "qdisc ingress")
+	cake := sampleTCOutput[start:end]
+	mq := 0
+	if n >= 16 {
+		mq = 4
+	}
+	var b strings.Builder
+	for i := range n - mq {
+		b.WriteString(strings.ReplaceAll(cake, "dev eth1 ", fmt.Sprintf("dev eth%d ", i)))
+	}
+	for i := range mq {
+		b.WriteString(strings.ReplaceAll(sampleCakeMQ4Output, "dev eth3 ", fmt.Sprintf("dev mq%d ", i)))
+	}
+	return b.String()
+}
+
+// benchParseText parses benchTCOutput(n).  The results are kept in
+// testdata/bench.txt; after a parser change, regenerate it with
+//
+//	go test -run '^$' -bench ParseText -benchmem -count 6 ./pkg/parser > pkg/parser/testdata/bench.txt
+//
+// and compare against the previous version with benchstat.  CI fails when
+// allocs/op rises above the recorded values.
+func benchParseText(b *testing.B, n int) {
+	out := benchTCOutput(n)
+	if got := len(parseText(out)); got != n {
+		b.Fatalf("want %d interfaces, got %d", n, got)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(out)))
+	for b.Loop() {
+		_ = parseText(out)
+	}
+}
+
+func BenchmarkParseText1(b *testing.B)  { benchParseText(b, 1) }
+func BenchmarkParseText4(b *testing.B)  { benchParseText(b, 4) }
+func BenchmarkParseText16(b *testing.B) { benchParseText(b, 16) }
+
+func TestParseBlock_MatchesParseText(t *testing.T) {
+	// Take the eth1 block on its own so parseText sees a single interface.
+	lines := strings.Split(sampleTCOutput, "\n")
+	start, end := -1, len(lines)
+	for i, l := range lines {
+		if strings.HasPrefix(l, "qdisc cake 800d:") {
+			start = i
+		} else if start >= 0 && strings.HasPrefix(l, "qdisc ") {
+			end = i
+			break
+		}
+	}
+	if start < 0 {
+		t.Fatal("fixture: eth1 cake block not found")
+	}
+	eth1 := strings.Join(lines[start:end], "\n")
+	fromText := parseText(eth1)
+	if len(fromText) != 1 {
+		t.Fatalf("parseText: expected 1 interface, got %d", len(fromText))
+	}
+	fromBlock, ok := ParseBlock(strings.Split(eth1, "\n"))
+	if !ok {
+		t.Fatal("ParseBlock returned ok=false")
+	}
+	fromText[0].UpdatedAt, fromBlock.UpdatedAt = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(fromText[0], fromBlock) {
+		t.Errorf("ParseBlock and parseText differ:\n%+v\n%+v", fromBlock, fromText[0])
+	}
+}
+
 func assertEqual(t *testing.T, field, want, got string) {
 	t.Helper()
 	if got != want {
@@ -198,9 +330,9 @@ func TestParseHeader_FwmarkMask(t *testing.T) {
 
 func TestParseJSONMinimal(t *testing.T) {
 	jsonData := `[{"kind":"cake","dev":"eth0","handle":"800d:","options":{"bandwidth":5000000,"diffserv":"diffserv4","nat":true,"atm":"atm","overhead":48,"rtt":100000},"bytes":123,"packets":456,"drops":7,"overlimits":8,"requeues":9,"memory_used":100,"memory_limit":33554432,"capacity_estimate":5000000,"min_network_size":28,"max_network_size":1500,"avg_hdr_offset":14,"tins":[{"threshold_rate":3125,"sent_bytes":0,"drops":0,"max_pkt_len":0,"flow_quantum":300}]}]`
-	stats, err := parseJSON([]byte(jsonData))
+	stats, err := ParseJSON([]byte(jsonData))
 	if err != nil {
-		t.Fatalf("parseJSON error: %v", err)
+		t.Fatalf("ParseJSON error: %v", err)
 	}
 	if len(stats) != 1 {
 		t.Fatalf("expected 1 entry, got %d", len(stats))
@@ -211,6 +343,95 @@ func TestParseJSONMinimal(t *testing.T) {
 	assertUint(t, "max_len", 0, cs.Tiers[0].MaxLen)
 }
 
+// sampleJSONDiffserv3 is `tc -j -s qdisc show dev eth0` from iproute2 6.1
+// with every per-tin key CAKE emits.
+const sampleJSONDiffserv3 = `[{"kind":"cake","handle":"8011:","root":true,"refcnt":2,"options":{"bandwidth":6250000,"diffserv":"diffserv3","flowmode":"triple-isolate","nat":false,"wash":false,"ingress":false,"ack-filter":"disabled","split_gso":true,"rtt":100000,"raw":false,"overhead":18,"fwmark":"0"},"bytes":891238412,"packets":903121,"drops":28,"overlimits":1201,"requeues":0,"backlog":0,"qlen":0,"memory_used":238656,"memory_limit":33554432,"capacity_estimate":6250000,"min_network_size":28,"max_network_size":1500,"min_adj_size":46,"max_adj_size":1518,"avg_hdr_offset":14,"tins":[
+{"threshold_rate":390625,"sent_bytes":1202,"backlog_bytes":0,"target_us":5810,"interval_us":100810,"peak_delay_us":0,"avg_delay_us":0,"base_delay_us":0,"sent_packets":13,"way_indirect_hits":0,"way_misses":4,"way_collisions":0,"drops":0,"ecn_mark":0,"ack_drops":0,"sparse_flows":0,"bulk_flows":0,"unresponsive_flows":0,"max_pkt_len":98,"flow_quantum":300},
+{"threshold_rate":6250000,"sent_bytes":890201210,"backlog_bytes":3028,"target_us":5000,"interval_us":100000,"peak_delay_us":545,"avg_delay_us":42,"base_delay_us":3,"sent_packets":901012,"way_indirect_hits":8712,"way_misses":1203,"way_collisions":1,"drops":28,"ecn_mark":117,"ack_drops":9,"sparse_flows":2,"bulk_flows":1,"unresponsive_flows":0,"max_pkt_len":1514,"flow_quantum":1514},
+{"threshold_rate":1562500,"sent_bytes":1036000,"backlog_bytes":0,"target_us":5000,"interval_us":100000,"peak_delay_us":35,"avg_delay_us":7,"base_delay_us":1,"sent_packets":2096,"way_indirect_hits":3,"way_misses":12,"way_collisions":0,"drops":0,"ecn_mark":0,"ack_drops":0,"sparse_flows":1,"bulk_flows":0,"unresponsive_flows":0,"max_pkt_len":590,"flow_quantum":1514}]}]`
+
+// TestParseJSON_FullTierFields checks that every per-tin key in modern tc
+// JSON lands in CakeTier, formatted as the text parser would.
+func TestParseJSON_FullTierFields(t *testing.T) {
+	stats, err := ParseJSON([]byte(sampleJSONDiffserv3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || len(stats[0].Tiers) != 3 {
+		t.Fatalf("want 1 interface with 3 tiers, got %+v", stats)
+	}
+	for i, name := range []string{"Bulk", "Best Effort", "Voice"} {
+		assertEqual(t, "name", name, stats[0].Tiers[i].Name)
+	}
+	got := stats[0].Tiers[1]
+	want := types.CakeTier{
+		Name:     "Best Effort",
+		Thresh:   "50Mbit",
+		Target:   "5ms",
+		Interval: "100ms",
+		PkDelay:  "545us",
+		AvDelay:  "42us",
+		SpDelay:  "3us",
+		Backlog:  "3028b",
+		Pkts:     901012,
+		Bytes:    890201210,
+		WayInds:  8712,
+		WayMiss:  1203,
+		WayCols:  1,
+		Drops:    28,
+		Marks:    117,
+		AckDrop:  9,
+		SpFlows:  2,
+		BkFlows:  1,
+		UnFlows:  0,
+		MaxLen:   1514,
+		Quantum:  1514,
+
+		TotalFlows: 3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Best Effort tier:\n got  %+v\n want %+v", got, want)
+	}
+	if !stats[0].SplitGSO || !stats[0].NoAckFilter {
+		t.Errorf("split_gso=%v no_ack_filter=%v, want both true", stats[0].SplitGSO, stats[0].NoAckFilter)
+	}
+	assertEqual(t, "bulk thresh", "3125Kbit", stats[0].Tiers[0].Thresh)
+	assertEqual(t, "bulk target", "5.81ms", stats[0].Tiers[0].Target)
+}
+
+func TestParseJSON_Error(t *testing.T) {
+	if _, err := ParseJSON([]byte(`[{"kind":"cake"`)); err == nil {
+		t.Error("truncated JSON: want error, got nil")
+	}
+}
+
+func TestParseJSON_Empty(t *testing.T) {
+	stats, err := ParseJSON([]byte("[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats == nil || len(stats) != 0 {
+		t.Errorf("want empty non-nil slice, got %#v", stats)
+	}
+}
+
+func TestGetUint_Negative(t *testing.T) {
+	m := map[string]interface{}{"threshold_rate": -1.0}
+	if v, ok := getUint(m, "threshold_rate"); ok || v != 0 {
+		t.Errorf("getUint(-1)=(%d, %v) want (0, false)", v, ok)
+	}
+}
+
+func TestGetUint_Null(t *testing.T) {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"threshold_rate":null}`), &m); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := getUint(m, "threshold_rate"); ok || v != 0 {
+		t.Errorf("getUint(null)=(%d, %v) want (0, false)", v, ok)
+	}
+}
+
 func TestParseUint64_Safe(t *testing.T) {
 	cases := []struct {
 		in   string
@@ -353,6 +574,181 @@ func TestCakeMQ_GlobalCounters(t *testing.T) {
 	assertEqual(t, "capacity_est", "100Mbit", cs.CapacityEst)
 }
 
+// sampleCakeMQ4Output is a cake_mq instance on a four-queue NIC.  The worst
+// delay and largest max_len for each tier come from different queues, so the
+// aggregation cannot pass by picking the first or last queue.
+const sampleCakeMQ4Output = `qdisc cake_mq 2: dev eth3 root refcnt 9 
+qdisc cake 0: dev eth3 parent 2:1 refcnt 2 bandwidth 200Mbit diffserv4 triple-isolate nonat nowash no-ack-filter split-gso rtt 100ms noatm overhead 18 memlimit 32Mb 
+ Sent 100000000 bytes 350000 pkt (dropped 40, overlimits 500000 requeues 0) 
+ backlog 0b 0p requeues 0
+ memory used: 90000b of 32Mb
+ capacity estimate: 200Mbit
+ min/max network layer size:           28 /    1500
+ min/max overhead-adjusted size:       46 /    1518
+ average network hdr offset:           14
+
+                       Bulk  Best Effort        Video        Voice
+  thresh          12500Kbit      200Mbit      100Mbit       50Mbit
+  target                5ms          5ms          5ms          5ms
+  interval            100ms        100ms        100ms        100ms
+  pk_delay              0us        300us         15us        900us
+  av_delay              0us         25us          3us         70us
+  sp_delay              0us          2us          1us          1us
+  backlog                0b           0b           0b           0b
+  pkts                    0       348000           80         1900
+  bytes                   0    100500000         8000       280000
+  way_inds                0         5000            0            5
+  way_miss                0         4000           20           60
+  way_cols                0            0            0            0
+  drops                   0           40            0            0
+  marks                   0            0            0            0
+  ack_drop                0            0            0            0
+  sp_flows                0            1            0            1
+  bk_flows                0            1            0            0
+  un_flows                0            0            0            0
+  max_len                 0         9000          200          300
+  quantum              1514         1514         1514         1514
+
+qdisc cake 0: dev eth3 parent 2:2 refcnt 2 bandwidth 200Mbit diffserv4 triple-isolate nonat nowash no-ack-filter split-gso rtt 100ms noatm overhead 18 memlimit 32Mb 
+ Sent 120000000 bytes 420000 pkt (dropped 55, overlimits 610000 requeues 2) 
+ backlog 0b 0p requeues 2
+ memory used: 110000b of 32Mb
+ capacity estimate: 200Mbit
+ min/max network layer size:           28 /    1500
+ min/max overhead-adjusted size:       46 /    1518
+ average network hdr offset:           14
+
+                       Bulk  Best Effort        Video        Voice
+  thresh          12500Kbit      200Mbit      100Mbit       50Mbit
+  target                5ms          5ms          5ms          5ms
+  interval            100ms        100ms        100ms        100ms
+  pk_delay              0us        1.8ms         25us        400us
+  av_delay              0us         60us          5us         35us
+  sp_delay              0us          4us          1us          1us
+  backlog                0b           0b           0b           0b
+  pkts                    0       417500          120         2300
+  bytes                   0    120600000        12000       330000
+  way_inds                0         6000            0            8
+  way_miss                0         4500           30           70
+  way_cols                0            0            0            0
+  drops                   0           55            0            0
+  marks                   0            0            0            0
+  ack_drop                0            0            0            0
+  sp_flows                0            1            0            1
+  bk_flows                0            1            0            0
+  un_flows                0            0            0            0
+  max_len                 0        12000          350          420
+  quantum              1514         1514         1514         1514
+
+qdisc cake 0: dev eth3 parent 2:3 refcnt 2 bandwidth 200Mbit diffserv4 triple-isolate nonat nowash no-ack-filter split-gso rtt 100ms noatm overhead 18 memlimit 32Mb 
+ Sent 90000000 bytes 300000 pkt (dropped 20, overlimits 420000 requeues 1) 
+ backlog 0b 0p requeues 1
+ memory used: 80000b of 32Mb
+ capacity estimate: 200Mbit
+ min/max network layer size:           28 /    1500
+ min/max overhead-adjusted size:       46 /    1518
+ average network hdr offset:           14
+
+                       Bulk  Best Effort        Video        Voice
+  thresh          12500Kbit      200Mbit      100Mbit       50Mbit
+  target                5ms          5ms          5ms          5ms
+  interval            100ms        100ms        100ms        100ms
+  pk_delay              0us        700us         40us        350us
+  av_delay              0us         40us          9us         30us
+  sp_delay              0us          3us          2us          1us
+  backlog                0b           0b           0b           0b
+  pkts                    0       298200           60         1700
+  bytes                   0     90300000         6000       250000
+  way_inds                0         4000            0            4
+  way_miss                0         3000           10           40
+  way_cols                0            0            0            0
+  drops                   0           20            0            0
+  marks                   0            0            0            0
+  ack_drop                0            0            0            0
+  sp_flows                0            1            0            1
+  bk_flows                0            1            0            0
+  un_flows                0            0            0            0
+  max_len                 0        64000          260          380
+  quantum              1514         1514         1514         1514
+
+qdisc cake 0: dev eth3 parent 2:4 refcnt 2 bandwidth 200Mbit diffserv4 triple-isolate nonat nowash no-ack-filter split-gso rtt 100ms noatm overhead 18 memlimit 32Mb 
+ Sent 110000000 bytes 380000 pkt (dropped 35, overlimits 560000 requeues 4) 
+ backlog 0b 0p requeues 4
+ memory used: 100000b of 32Mb
+ capacity estimate: 200Mbit
+ min/max network layer size:           28 /    1500
+ min/max overhead-adjusted size:       46 /    1518
+ average network hdr offset:           14
+
+                       Bulk  Best Effort        Video        Voice
+  thresh          12500Kbit      200Mbit      100Mbit       50Mbit
+  target                5ms          5ms          5ms          5ms
+  interval            100ms        100ms        100ms        100ms
+  pk_delay              0us        950us         30us        1.1ms
+  av_delay              0us         45us          7us         80us
+  sp_delay              0us          6us          1us          2us
+  backlog                0b           0b           0b           0b
+  pkts                    0       377600           90         2100
+  bytes                   0    110400000         9000       300000
+  way_inds                0         5500            0            6
+  way_miss                0         3800           25           55
+  way_cols                0            0            0            0
+  drops                   0           35            0            0
+  marks                   0            0            0            0
+  ack_drop                0            0            0            0
+  sp_flows                0            1            0            1
+  bk_flows                0            1            0            0
+  un_flows                0            0            0            0
+  max_len                 0        18000          300         1514
+  quantum              1514         1514         1514         1514
+
+`
+
+// TestCakeMQ4_GlobalCounters extends TestCakeMQ_GlobalCounters to four
+// sub-queues.
+func TestCakeMQ4_GlobalCounters(t *testing.T) {
+	results := parseText(sampleCakeMQ4Output)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 aggregated CakeStats for cake_mq, got %d", len(results))
+	}
+	cs := results[0]
+	assertEqual(t, "interface", "eth3", cs.Interface)
+	assertEqual(t, "handle", "2", cs.Handle)
+	// 100M + 120M + 90M + 110M, and so on for each counter.
+	assertUint(t, "sent_bytes", 420000000, cs.SentBytes)
+	assertUint(t, "sent_pkts", 1450000, cs.SentPkts)
+	assertUint(t, "dropped", 150, cs.Dropped)
+	assertUint(t, "overlimits", 2090000, cs.Overlimits)
+	assertUint(t, "requeues", 7, cs.Requeues)
+	assertEqual(t, "memory_used", "380000b", cs.MemoryUsed)
+	assertEqual(t, "memory_total", "32Mb", cs.MemoryTotal)
+}
+
+// TestCakeMQ4_Tiers checks summed tier counters, per-tier worst-case delays
+// and max_len across all four sub-queues.
+func TestCakeMQ4_Tiers(t *testing.T) {
+	cs := parseText(sampleCakeMQ4Output)[0]
+	if len(cs.Tiers) != 4 {
+		t.Fatalf("expected 4 tiers, got %d", len(cs.Tiers))
+	}
+	be, video, voice := cs.Tiers[1], cs.Tiers[2], cs.Tiers[3]
+	assertUint(t, "be.pkts", 1441300, be.Pkts)
+	assertUint(t, "be.bytes", 421800000, be.Bytes)
+	assertUint(t, "be.drops", 150, be.Drops)
+	assertUint(t, "be.way_inds", 20500, be.WayInds)
+	assertUint(t, "be.way_miss", 15300, be.WayMiss)
+
+	assertEqual(t, "be.pk_delay", "1.8ms", be.PkDelay)       // queue 2
+	assertEqual(t, "be.av_delay", "60us", be.AvDelay)        // queue 2
+	assertEqual(t, "be.sp_delay", "6us", be.SpDelay)         // queue 4
+	assertEqual(t, "video.av_delay", "9us", video.AvDelay)   // queue 3
+	assertEqual(t, "voice.pk_delay", "1.1ms", voice.PkDelay) // queue 4
+
+	assertUint(t, "be.max_len", 64000, be.MaxLen)      // queue 3
+	assertUint(t, "video.max_len", 350, video.MaxLen)  // queue 2
+	assertUint(t, "voice.max_len", 1514, voice.MaxLen) // queue 4
+}
+
 // TestCakeMQ_TierCount verifies that four tiers are present after aggregation.
 func TestCakeMQ_TierCount(t *testing.T) {
 	cs := parseText(sampleCakeMQOutput)[0]
@@ -407,6 +803,26 @@ func TestCakeMQ_StandaloneUnaffected(t *testing.T) {
 	}
 }
 
+// TestParseText_OrderPreserved pins the emission order: the aggregated cake_mq
+// entry appears at the position of its parent block (which precedes its
+// sub-queues), followed by standalone qdiscs in tc order.  /api/stats relies
+// on this to keep interface cards from reshuffling between polls.
+func TestParseText_OrderPreserved(t *testing.T) {
+	results := parseText(sampleCakeMQOutput + sampleTCOutput)
+	want := []string{"eth0", "eth1", "ifb4eth1"}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(results))
+	}
+	for i, name := range want {
+		assertEqual(t, fmt.Sprintf("results[%d].interface", i), name, results[i].Interface)
+	}
+	// Parsing the same input again must give the same order.
+	again := parseText(sampleCakeMQOutput + sampleTCOutput)
+	for i := range again {
+		assertEqual(t, fmt.Sprintf("repeat[%d].interface", i), results[i].Interface, again[i].Interface)
+	}
+}
+
 // sampleCakeMQIngressOutput simulates an ingress cake_mq on an IFB device
 // (ifb4eth1) where the "ingress" keyword appears only in the cake_mq parent
 // line but NOT in the sub-queue lines.  This models the tc/kernel build
@@ -674,6 +1090,200 @@ func TestParseHeader_MPU_WithATM(t *testing.T) {
 	assertEqual(t, "atm_mode", "atm", cs.ATMMode)
 }
 
+func TestParseHeader_MPUBytes_WithValue(t *testing.T) {
+	cs := parseText(minimalCakeHeader("mpu 84 noatm overhead 38"))[0]
+	if cs.MPUBytes != 84 {
+		t.Errorf("mpu_bytes: want 84, got %d", cs.MPUBytes)
+	}
+}
+
+func TestParseHeader_MPUBytes_Absent(t *testing.T) {
+	cs := parseText(minimalCakeHeader("noatm overhead 38"))[0]
+	if cs.MPUBytes != 0 {
+		t.Errorf("mpu_bytes: want 0, got %d", cs.MPUBytes)
+	}
+}
+
+// sampleDiffserv8Output is a diffserv8 qdisc: eight tins headed "Tin 0" to
+// "Tin 7", each a two-word name in the tier header.
+const sampleDiffserv8Output = `qdisc cake 8021: dev eth2 root refcnt 2 bandwidth 100Mbit diffserv8 triple-isolate nonat nowash no-ack-filter split-gso rtt 100ms noatm overhead 18 mpu 64 memlimit 32Mb 
+ Sent 3164516580 bytes 2378266 pkt (dropped 1924, overlimits 1843210 requeues 0) 
+ backlog 0b 0p requeues 0
+ memory used: 1425600b of 32Mb
+ capacity estimate: 100Mbit
+ min/max network layer size:           28 /    1500
+ min/max overhead-adjusted size:       64 /    1518
+ average network hdr offset:           14
+
+                        Tin 0        Tin 1        Tin 2        Tin 3        Tin 4        Tin 5        Tin 6        Tin 7
+  thresh              100Mbit    93750Kbit    87500Kbit    81250Kbit       75Mbit    68750Kbit    62500Kbit    56250Kbit
+  target                  5ms          5ms          5ms          5ms          5ms          5ms          5ms          5ms
+  interval              100ms        100ms        100ms        100ms        100ms        100ms        100ms        100ms
+  pk_delay               12us        1.2ms        830us          0us          0us          0us       2.05ms        210us
+  av_delay                3us        180us         95us          0us          0us          0us        410us         22us
+  sp_delay                1us          4us          3us          0us          0us          0us          6us          2us
+  backlog                  0b           0b           0b           0b           0b           0b           0b           0b
+  pkts                   1021      2230912        98122            0            0            0        41230         7781
+  bytes                 98012   3102938471     10231872            0            0            0     50213887      1021334
+  way_inds                  0        10293           21            0            0            0           87            3
+  way_miss                  7         3812          102            0            0            0           49           12
+  way_cols                  0            0            0            0            0            0            0            0
+  drops                     0         1893            4            0            0            0           27            0
+  marks                     0          211            0            0            0            0            3            0
+  ack_drop                  0            0            0            0            0            0            0            0
+  sp_flows                  1            2            1            0            0            0            1            1
+  bk_flows                  0            1            0            0            0            0            1            0
+  un_flows                  0            0            0            0            0            0            0            0
+  max_len                  98         1514         1514            0            0            0         1514          590
+  quantum                1514         1514         1514         1514         1514         1514         1514         1514`
+
+func TestParseTCOutput_Diffserv8(t *testing.T) {
+	stats := parseText(sampleDiffserv8Output)
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 CAKE interface, got %d", len(stats))
+	}
+	cs := stats[0]
+	assertEqual(t, "diffserv_mode", "diffserv8", cs.DiffservMode)
+	want := []struct{ thresh, pkDelay string }{
+		{"100Mbit", "12us"}, {"93750Kbit", "1.2ms"}, {"87500Kbit", "830us"}, {"81250Kbit", "0us"},
+		{"75Mbit", "0us"}, {"68750Kbit", "0us"}, {"62500Kbit", "2.05ms"}, {"56250Kbit", "210us"},
+	}
+	if len(cs.Tiers) != len(want) {
+		t.Fatalf("want %d tiers, got %d", len(want), len(cs.Tiers))
+	}
+	for i, w := range want {
+		tier := cs.Tiers[i]
+		assertEqual(t, "name", fmt.Sprintf("Tin %d", i), tier.Name)
+		assertEqual(t, tier.Name+" thresh", w.thresh, tier.Thresh)
+		assertEqual(t, tier.Name+" pk_delay", w.pkDelay, tier.PkDelay)
+	}
+	assertUint(t, "Tin 1 bytes", 3102938471, cs.Tiers[1].Bytes)
+	assertUint(t, "Tin 6 drops", 27, cs.Tiers[6].Drops)
+}
+
+// TestParseHeader_VLANInterfaces verifies that dotted VLAN sub-interface
+// names survive header parsing intact.
+func TestParseHeader_VLANInterfaces(t *testing.T) {
+	for _, tc := range []struct {
+		header, iface, handle, direction string
+	}{
+		{"qdisc cake 8012: dev eth0.4094 root refcnt 2 bandwidth 20Mbit diffserv3 rtt 100ms", "eth0.4094", "8012", "egress"},
+		{"qdisc cake 8013: dev bond0.100 root refcnt 2 bandwidth 100Mbit besteffort ingress rtt 100ms", "bond0.100", "8013", "ingress"},
+	} {
+		raw := tc.header + "\n Sent 0 bytes 0 pkt (dropped 0, overlimits 0 requeues 0)\n backlog 0b 0p requeues 0\n"
+		stats := parseText(raw)
+		if len(stats) != 1 {
+			t.Fatalf("%s: expected 1 CAKE interface, got %d", tc.iface, len(stats))
+		}
+		assertEqual(t, "interface", tc.iface, stats[0].Interface)
+		assertEqual(t, "handle", tc.handle, stats[0].Handle)
+		assertEqual(t, "direction", tc.direction, stats[0].Direction)
+	}
+}
+
+// TestCakeMQ_VLANInterface runs the two-queue cake_mq fixture on a VLAN
+// interface to confirm the (interface, handle) aggregation key copes with
+// dotted names.
+func TestCakeMQ_VLANInterface(t *testing.T) {
+	raw := strings.ReplaceAll(sampleCakeMQOutput, "dev eth0 ", "dev eth0.100 ")
+	results := parseText(raw)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 aggregated CakeStats, got %d", len(results))
+	}
+	cs := results[0]
+	assertEqual(t, "interface", "eth0.100", cs.Interface)
+	assertEqual(t, "handle", "1", cs.Handle)
+	assertEqual(t, "direction", "egress", cs.Direction)
+	assertUint(t, "sent_bytes", 450000000, cs.SentBytes)
+}
+
+func TestParseHeader_SplitGSOAndAckFilter(t *testing.T) {
+	for _, tc := range []struct {
+		params           string
+		splitGSO, noAckF bool
+	}{
+		{"no-ack-filter split-gso", true, true},
+		{"ack-filter no-split-gso", false, false},
+		{"ack-filter-aggressive split-gso", true, false},
+		{"", false, false},
+	} {
+		cs := parseText(minimalCakeHeader(tc.params))[0]
+		if cs.SplitGSO != tc.splitGSO || cs.NoAckFilter != tc.noAckF {
+			t.Errorf("%q: split_gso=%v no_ack_filter=%v, want %v %v",
+				tc.params, cs.SplitGSO, cs.NoAckFilter, tc.splitGSO, tc.noAckF)
+		}
+	}
+	// sampleTCOutput's eth1 header carries both keywords.
+	cs := parseText(sampleTCOutput)[0]
+	if !cs.SplitGSO || !cs.NoAckFilter {
+		t.Errorf("sampleTCOutput eth1: split_gso=%v no_ack_filter=%v, want both true", cs.SplitGSO, cs.NoAckFilter)
+	}
+}
+
+func TestParseBandwidthBps(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want uint64
+	}{
+		{"800bit", 800},
+		{"22500Kbit", 22_500_000},
+		{"3125Kbit", 3_125_000},
+		{"50Mbit", 50_000_000},
+		{"1Gbit", 1_000_000_000},
+		{"2.5Gbit", 2_500_000_000},
+		{"1Tbit", 1_000_000_000_000},
+		{"autorate-ingress", 0},
+		{"unlimited", 0},
+		{"Mbit", 0},
+		{"", 0},
+	} {
+		if got := parseBandwidthBps(tc.in); got != tc.want {
+			t.Errorf("parseBandwidthBps(%q) = %d, want %d", tc.in, got, tc.want)
+		}
+	}
+	if cs := parseText(minimalCakeHeader("")); cs[0].BandwidthBps != 100_000_000 {
+		t.Errorf("header bandwidth 100Mbit: bandwidth_bps = %d", cs[0].BandwidthBps)
+	}
+	if cs := parseText(minimalCakeHeader("autorate-ingress")); cs[0].BandwidthBps != 0 {
+		t.Errorf("autorate-ingress: bandwidth_bps = %d, want 0", cs[0].BandwidthBps)
+	}
+}
+
+func TestParseHeader_MemLimitBytes(t *testing.T) {
+	for _, tc := range []struct {
+		params string
+		want   uint64
+	}{
+		{"memlimit 32Mb", 32 << 20},
+		{"memlimit 4Mb", 4 << 20},
+		{"memlimit 1Gb", 1 << 30},
+		{"", 0},
+	} {
+		cs := parseText(minimalCakeHeader(tc.params))[0]
+		if cs.MemLimitBytes != tc.want {
+			t.Errorf("%q: memlimit_bytes = %d, want %d", tc.params, cs.MemLimitBytes, tc.want)
+		}
+	}
+}
+
+// TestParseTCOutput_DiffservCode verifies the numeric code for every diffserv
+// keyword by swapping it into the minimal header.
+func TestParseTCOutput_DiffservCode(t *testing.T) {
+	for mode, want := range map[string]int{
+		"besteffort": 1,
+		"diffserv3":  3,
+		"diffserv4":  4,
+		"diffserv8":  8,
+		"precedence": 0,
+	} {
+		cs := parseText(strings.Replace(minimalCakeHeader(""), "diffserv4", mode, 1))[0]
+		assertEqual(t, "diffserv_mode", mode, cs.DiffservMode)
+		if cs.DiffservCode != want {
+			t.Errorf("%s: diffserv_code want %d, got %d", mode, want, cs.DiffservCode)
+		}
+	}
+}
+
 // TestParseHeader_FlowModes verifies that each flow-mode keyword is stored in
 // DualMode (or left empty for flowblind which disables flow classification).
 func TestParseHeader_FlowModes(t *testing.T) {
@@ -842,6 +1452,18 @@ qdisc fq_codel 0: dev eth0 parent :1 limit 10240p flows 1024 quantum 1514 target
 	}
 }
 
+func TestParseText_EmptyInput(t *testing.T) {
+	if results := parseText(""); len(results) != 0 {
+		t.Fatalf("expected no interfaces for empty input, got %d", len(results))
+	}
+}
+
+func TestParseText_WhitespaceOnly(t *testing.T) {
+	if results := parseText(" \n\n\t \n"); len(results) != 0 {
+		t.Fatalf("expected no interfaces for whitespace-only input, got %d", len(results))
+	}
+}
+
 // TestParseTierNames_TinFormat verifies that the "Tin N" compound tier name
 // is parsed as a single name, not split into two separate names.
 func TestParseTierNames_TinFormat(t *testing.T) {
@@ -906,6 +1528,8 @@ func TestParseBytesStr(t *testing.T) {
 		{"4Mb", 4 * 1024 * 1024},
 		{"32Mb", 32 * 1024 * 1024},
 		{"1Gb", 1 * 1024 * 1024 * 1024},
+		// Raw byte counts, in case iproute2 stops scaling memlimit.
+		{"33554432b", 33554432},
 	}
 	for _, c := range cases {
 		if got := util.ParseBytesStr(c.in); got != c.want {
@@ -914,6 +1538,22 @@ func TestParseBytesStr(t *testing.T) {
 	}
 }
 
+// TestParseMemoryLine_RawBytes covers a memory line whose used and total are
+// both plain byte counts rather than Kb/Mb-scaled values.
+func TestParseMemoryLine_RawBytes(t *testing.T) {
+	var cs types.CakeStats
+	parseMemoryLine(&cs, "memory used: 33554432b of 33554432b")
+	assertEqual(t, "memory_used", "33554432b", cs.MemoryUsed)
+	assertEqual(t, "memory_total", "33554432b", cs.MemoryTotal)
+	assertUint(t, "memory_used bytes", 33554432, util.ParseBytesStr(cs.MemoryUsed))
+	assertUint(t, "memory_total bytes", 33554432, util.ParseBytesStr(cs.MemoryTotal))
+
+	cs = parseText(strings.Replace(minimalCakeHeader("memlimit 33554432b"),
+		"memory used: 14Kb of 4Mb", "memory used: 33554432b of 33554432b", 1))[0]
+	assertEqual(t, "memlimit", "33554432b", cs.MemLimit)
+	assertUint(t, "memlimit bytes", 33554432, util.ParseBytesStr(cs.MemLimit))
+}
+
 // TestHeaderParentHandle exercises the parent-handle extractor.
 func TestHeaderParentHandle(t *testing.T) {
 	cases := []struct {
@@ -1164,3 +1804,108 @@ func TestSegal72_CakeMQIFBDirection(t *testing.T) {
 	// pk_delay: max(68us, 108us, 234us, 66us) = 234us
 	assertEqual(t, "video.pk_delay", "234us", video.PkDelay)
 }
+
+// TestParseTCOutput_OrphanedSubqueue covers cake sub-qdiscs that carry a
+// "parent X:N" handle but whose cake_mq parent is not in the output (e.g. a
+// filtered dump).  They are emitted verbatim and must still get the right
+// direction from the ingress keyword or the ifb* name.
+func TestParseTCOutput_OrphanedSubqueue(t *testing.T) {
+	snippet := "qdisc cake 0: dev ifb4eth1 parent 1:1 bandwidth 50Mbit diffserv4 dual-dsthost nat nowash no-ack-filter split-gso rtt 100ms noatm overhead 0\n" +
+		" Sent 1000 bytes 10 pkt (dropped 1, overlimits 0 requeues 0)\n" +
+		" backlog 0b 0p requeues 0\n" +
+		"qdisc cake 0: dev eth1 parent 2:1 bandwidth 50Mbit diffserv4 dual-dsthost nat nowash ingress no-ack-filter split-gso rtt 100ms noatm overhead 0\n" +
+		" Sent 2000 bytes 20 pkt (dropped 2, overlimits 0 requeues 0)\n" +
+		" backlog 0b 0p requeues 0\n" +
+		"qdisc cake 0: dev eth2 parent 3:1 bandwidth 50Mbit diffserv4 dual-srchost nat nowash no-ack-filter split-gso rtt 100ms noatm overhead 0\n" +
+		" Sent 3000 bytes 30 pkt (dropped 3, overlimits 0 requeues 0)\n" +
+		" backlog 0b 0p requeues 0\n"
+	results := parseText(snippet)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 orphaned sub-queues emitted verbatim, got %d", len(results))
+	}
+	assertEqual(t, "ifb orphan interface", "ifb4eth1", results[0].Interface)
+	assertEqual(t, "ifb orphan direction", "ingress", results[0].Direction)
+	assertEqual(t, "keyword orphan direction", "ingress", results[1].Direction)
+	assertEqual(t, "plain orphan direction", "egress", results[2].Direction)
+	assertUint(t, "plain orphan dropped", 3, results[2].Dropped)
+}
+
+func TestSplitBlocks_Count(t *testing.T) {
+	want := 0
+	for _, l := range strings.Split(sampleTCOutput, "\n") {
+		if strings.HasPrefix(l, "qdisc ") {
+			want++
+		}
+	}
+	blocks := SplitBlocks(sampleTCOutput)
+	if len(blocks) != want {
+		t.Fatalf("expected %d blocks, got %d", want, len(blocks))
+	}
+	for i, b := range blocks {
+		if !strings.HasPrefix(b[0], "qdisc ") {
+			t.Errorf("block %d does not start with a qdisc header: %q", i, b[0])
+		}
+	}
+}
+
+func TestSplitBlocks_Empty(t *testing.T) {
+	for _, in := range []string{"", " \n\t\n"} {
+		blocks := SplitBlocks(in)
+		if blocks == nil || len(blocks) != 0 {
+			t.Errorf("SplitBlocks(%q) = %#v, want empty non-nil slice", in, blocks)
+		}
+	}
+}
+
+// FuzzParseText feeds arbitrary bytes to parseText.  The only failure is a
+// panic; whatever the parser makes of garbage is acceptable.
+func FuzzParseText(f *testing.F) {
+	for _, seed := range []string{
+		sampleTCOutput,
+		sampleCakeMQOutput,
+		sampleCakeMQ4Output,
+		sampleCakeMQIngressOutput,
+		sampleBesteffortOutput,
+		sampleDiffserv8Output,
+		sampleSegal72Output,
+		sampleCakeMQ4Output + sampleCakeMQIngressOutput,
+		minimalCakeHeader(""),
+		minimalCakeHeader("atm overhead 40"),
+		minimalCakeHeader("mpu 84 noatm overhead 38"),
+		minimalCakeHeader("autorate-ingress"),
+		minimalCakeHeader("memlimit 32Mb split-gso ack-filter-aggressive"),
+		"qdisc noqueue 0: dev lo root refcnt 2 \n Sent 0 bytes 0 pkt (dropped 0, overlimits 0 requeues 0) \n",
+		"",
+		" \n\n\t \n",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parseText(string(data))
+	})
+}
+
+func TestParseTCOutput_FlowTotals(t *testing.T) {
+	for name, raw := range map[string]string{
+		"cake":       sampleTCOutput,
+		"cake_mq":    sampleCakeMQOutput,
+		"diffserv8":  sampleDiffserv8Output,
+		"besteffort": sampleBesteffortOutput,
+	} {
+		for _, cs := range parseText(raw) {
+			var most uint64
+			for _, tier := range cs.Tiers {
+				if want := tier.SpFlows + tier.BkFlows + tier.UnFlows; tier.TotalFlows != want {
+					t.Errorf("%s %s %s: TotalFlows %d, want %d", name, cs.Interface, tier.Name, tier.TotalFlows, want)
+				}
+				most = max(most, tier.TotalFlows)
+			}
+			if cs.MaxFlows != most {
+				t.Errorf("%s %s: MaxFlows %d, want %d", name, cs.Interface, cs.MaxFlows, most)
+			}
+			if len(cs.Tiers) > 0 && most == 0 {
+				t.Errorf("%s %s: fixture has no active flows; test proves nothing", name, cs.Interface)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"time"
+
+	fiber "github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+
+	"github.com/galpt/cake-stats/pkg/log"
+)
+
+// accessLog logs one line per request with its method, path, status,
+// latency and size.  /healthz is skipped, as are streamed responses (SSE),
+// which log their own connect and disconnect.  Errors are rendered here
+// rather than left to the app's error handler so the logged status is the
+// one sent.
+func accessLog() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if c.Path() == "/healthz" {
+			return c.Next()
+		}
+		start := time.Now()
+		if err := c.Next(); err != nil {
+			if herr := handleError(c, err); herr != nil {
+				return herr
+			}
+		}
+		resp := c.Response()
+		if resp.IsBodyStream() {
+			return nil
+		}
+		log.Logger.Info().
+			Str("method", c.Method()).
+			Str("path", c.Path()).
+			Int("status", resp.StatusCode()).
+			Float64("latency_ms", float64(time.Since(start).Microseconds())/1000).
+			Str("remote_ip", c.IP()).
+			Int("bytes_sent", len(resp.Body())).
+			Str("request_id", requestid.FromContext(c)).
+			Msg("request")
+		return nil
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/galpt/cake-stats/pkg/log"
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// logBuffer is a bytes.Buffer safe for the server goroutines to log into
+// while the test reads it.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// entries returns the logged JSON objects whose message is msg.
+func (b *logBuffer) entries(t *testing.T, msg string) []map[string]any {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("log line is not JSON: %q", line)
+		}
+		if m["message"] == msg {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func captureLog(t *testing.T) *logBuffer {
+	t.Helper()
+	buf := &logBuffer{}
+	old := log.Logger
+	log.Logger = zerolog.New(buf).Level(zerolog.InfoLevel)
+	t.Cleanup(func() { log.Logger = old })
+	return buf
+}
+
+func TestAccessLog(t *testing.T) {
+	buf := captureLog(t)
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	s.forcePoll()
+
+	for _, path := range []string{"/api/stats", "/healthz", "/api/nope"} {
+		if _, err := s.app.Test(httptest.NewRequest(http.MethodGet, path, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := buf.entries(t, "request")
+	if len(lines) != 2 {
+		t.Fatalf("want 2 access log lines (/healthz excluded), got %d: %v", len(lines), lines)
+	}
+	ok := lines[0]
+	for _, key := range []string{"method", "path", "status", "latency_ms", "remote_ip", "bytes_sent"} {
+		if _, present := ok[key]; !present {
+			t.Errorf("missing field %q in %v", key, ok)
+		}
+	}
+	if ok["method"] != "GET" || ok["path"] != "/api/stats" || ok["status"] != float64(200) {
+		t.Errorf("stats line: got %v", ok)
+	}
+	if n, _ := ok["bytes_sent"].(float64); n <= 0 {
+		t.Errorf("bytes_sent: got %v", ok["bytes_sent"])
+	}
+	if lines[1]["status"] != float64(404) {
+		t.Errorf("unknown route: want status 404, got %v", lines[1]["status"])
+	}
+}
+
+func TestAccessLog_SSESession(t *testing.T) {
+	buf := captureLog(t)
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	s.forcePoll()
+	addr := listen(t, s)
+
+	resp, err := http.Get("http://" + addr + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Read the initial snapshot event, then hang up.
+	if _, err := bufio.NewReader(resp.Body).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(buf.entries(t, "sse client connected")) != 1 {
+		t.Error("missing connect line")
+	}
+
+	// The stream writer only notices the hang-up on its next write.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(buf.entries(t, "sse client disconnected")) == 0 && time.Now().Before(deadline) {
+		s.forcePoll()
+		time.Sleep(10 * time.Millisecond)
+	}
+	lines := buf.entries(t, "sse client disconnected")
+	if len(lines) != 1 {
+		t.Fatalf("want one disconnect line, got %d", len(lines))
+	}
+	if _, ok := lines[0]["duration_s"].(float64); !ok {
+		t.Errorf("disconnect line without duration_s: %v", lines[0])
+	}
+	if len(buf.entries(t, "request")) != 0 {
+		t.Error("the SSE stream must not also produce an access log line")
+	}
+}
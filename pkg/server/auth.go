@@ -0,0 +1,28 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+
+	fiber "github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/basicauth"
+)
+
+// basicAuth returns middleware requiring s.authUser / s.authPass.  /healthz
+// stays open so probes need no credentials.  SSE and WebSocket clients are
+// checked on their initial request like any other route.
+func (s *Server) basicAuth() fiber.Handler {
+	// Compare digests so the comparison time does not depend on how much of
+	// a guess matches, nor on its length.
+	wantUser := sha256.Sum256([]byte(s.authUser))
+	wantPass := sha256.Sum256([]byte(s.authPass))
+	return basicauth.New(basicauth.Config{
+		Realm: "cake-stats",
+		Next:  func(c fiber.Ctx) bool { return c.Path() == "/healthz" },
+		Authorizer: func(user, pass string, _ fiber.Ctx) bool {
+			u := sha256.Sum256([]byte(user))
+			p := sha256.Sum256([]byte(pass))
+			return subtle.ConstantTimeCompare(u[:], wantUser[:])&subtle.ConstantTimeCompare(p[:], wantPass[:]) == 1
+		},
+	})
+}
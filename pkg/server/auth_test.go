@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestBasicAuth(t *testing.T) {
+	s := New("", Config{Interval: time.Second, HistoryCap: 10, AuthUser: "admin", AuthPass: "s3cret"})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		return []types.CakeStats{{Interface: "eth0"}}, nil
+	}
+	s.forcePoll()
+
+	do := func(path, user, pass string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if user != "" {
+			req.SetBasicAuth(user, pass)
+		}
+		resp, err := s.app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := do("/api/stats", "", "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("no credentials: want 401, got %d", resp.StatusCode)
+	}
+	if h := resp.Header.Get("WWW-Authenticate"); !strings.HasPrefix(h, "Basic ") {
+		t.Errorf("WWW-Authenticate: got %q", h)
+	}
+	if resp := do("/api/stats", "admin", "wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong password: want 401, got %d", resp.StatusCode)
+	}
+	if resp := do("/api/stats", "admin", "s3cret"); resp.StatusCode != http.StatusOK {
+		t.Errorf("correct credentials: want 200, got %d", resp.StatusCode)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/poll", nil)
+	if resp, _ := s.app.Test(req); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("POST /api/poll without credentials: want 401, got %d", resp.StatusCode)
+	}
+	if resp := do("/events", "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("/events without credentials: want 401, got %d", resp.StatusCode)
+	}
+	if resp := do("/healthz", "", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("/healthz without credentials: want 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestBasicAuth_Disabled(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("no auth configured: want 200, got %d", resp.StatusCode)
+	}
+}
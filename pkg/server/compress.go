@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+
+	fiber "github.com/gofiber/fiber/v3"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdEncoder is shared by all requests; EncodeAll is safe for concurrent use.
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+
+var gzipPool = sync.Pool{New: func() any { w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression); return w }}
+
+// sendCompressed writes body using the best encoding the client advertises in
+// Accept-Encoding: zstd first, then gzip, then identity.  Used for the larger
+// payloads (history) where compression pays for its CPU cost.
+func sendCompressed(c fiber.Ctx, body []byte) error {
+	c.Vary(fiber.HeaderAcceptEncoding)
+	switch responseEncoding(c) {
+	case "zstd":
+		c.Set(fiber.HeaderContentEncoding, "zstd")
+		return c.Send(zstdCompress(body))
+	case "gzip":
+		c.Set(fiber.HeaderContentEncoding, "gzip")
+		return c.Send(gzipCompress(body))
+	default:
+		return c.Send(body)
+	}
+}
+
+// responseEncoding is the content-coding sendCompressed picks for c: "zstd",
+// "gzip", or "" for identity.
+func responseEncoding(c fiber.Ctx) string {
+	ae := c.Get(fiber.HeaderAcceptEncoding)
+	switch {
+	case acceptsEncoding(ae, "zstd"):
+		return "zstd"
+	case acceptsEncoding(ae, "gzip"):
+		return "gzip"
+	}
+	return ""
+}
+
+func zstdCompress(b []byte) []byte {
+	return zstdEncoder.EncodeAll(b, make([]byte, 0, len(b)/4))
+}
+
+func gzipCompress(b []byte) []byte {
+	var buf bytes.Buffer
+	w := gzipPool.Get().(*gzip.Writer)
+	w.Reset(&buf)
+	_, _ = w.Write(b)
+	_ = w.Close()
+	gzipPool.Put(w)
+	return buf.Bytes()
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header lists enc with a
+// non-zero quality value.
+func acceptsEncoding(header, enc string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), enc) {
+			continue
+		}
+		for _, p := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if k == "q" {
+				q, err := strconv.ParseFloat(v, 64)
+				return err == nil && q > 0
+			}
+		}
+		return true
+	}
+	return false
+}
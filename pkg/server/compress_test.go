@@ -0,0 +1,75 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// historyServer returns a server whose history holds n samples for each of
+// two interfaces.
+func historyServer(n int) *Server {
+	s := New("", Config{Interval: time.Second, HistoryCap: n})
+	clock := time.Unix(1700000000, 0)
+	s.history.SetClock(func() time.Time { return clock })
+	var sent, dropped uint64
+	for i := range n {
+		sent += uint64(6_000_000 + i*137%50_000)
+		dropped += uint64(i % 3)
+		s.history.Record([]types.CakeStats{
+			{Interface: "eth1", SentBytes: sent, Dropped: dropped, Tiers: []types.CakeTier{{AvDelay: "130us", PkDelay: "2.1ms"}}},
+			{Interface: "ifb4eth1", SentBytes: sent / 4, Tiers: []types.CakeTier{{AvDelay: "90us", PkDelay: "1.3ms"}}},
+		}, time.Second)
+		clock = clock.Add(time.Second)
+	}
+	return s
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	for _, tc := range []struct {
+		header, enc string
+		want        bool
+	}{
+		{"gzip, deflate, br, zstd", "zstd", true},
+		{"gzip;q=1.0, zstd;q=0", "zstd", false},
+		{"GZIP", "gzip", true},
+		{"", "gzip", false},
+		{"br", "gzip", false},
+	} {
+		if got := acceptsEncoding(tc.header, tc.enc); got != tc.want {
+			t.Errorf("acceptsEncoding(%q, %q)=%v want %v", tc.header, tc.enc, got, tc.want)
+		}
+	}
+}
+
+// benchHistoryJSON requests /api/history for a 300-sample history with
+// Accept-Encoding set to encoding and reports the response body size.
+func benchHistoryJSON(b *testing.B, encoding string) {
+	s := historyServer(300)
+	var n int
+	b.ReportAllocs()
+	for b.Loop() {
+		req := httptest.NewRequest(http.MethodGet, "/api/history", nil)
+		if encoding != "" {
+			req.Header.Set("Accept-Encoding", encoding)
+		}
+		resp, err := s.app.Test(req)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if got := resp.Header.Get("Content-Encoding"); got != encoding {
+			b.Fatalf("Content-Encoding: want %q, got %q", encoding, got)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		n = len(body)
+	}
+	b.ReportMetric(float64(n), "resp-bytes")
+}
+
+func BenchmarkHistoryJSON_NoCompress(b *testing.B) { benchHistoryJSON(b, "") }
+func BenchmarkHistoryJSON_Gzip(b *testing.B)       { benchHistoryJSON(b, "gzip") }
+func BenchmarkHistoryJSON_Zstd(b *testing.B)       { benchHistoryJSON(b, "zstd") }
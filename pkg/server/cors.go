@@ -0,0 +1,45 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+
+	fiber "github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/cors"
+)
+
+// corsMiddleware allows cross-origin requests from origins, which are either
+// "*" or scheme://host[:port] (a host may start with "*." to cover its
+// subdomains).  With several origins the matching one is echoed back per
+// request.  Preflight OPTIONS requests get 204.  Invalid entries are
+// dropped here and reported by Run.
+func corsMiddleware(origins []string) fiber.Handler {
+	var valid []string
+	for _, o := range origins {
+		if checkOrigin(o) == nil {
+			valid = append(valid, o)
+		}
+	}
+	return cors.New(cors.Config{
+		AllowOrigins: valid,
+		AllowMethods: []string{fiber.MethodGet, fiber.MethodHead, fiber.MethodPost, fiber.MethodDelete},
+		AllowHeaders: []string{
+			fiber.HeaderAuthorization, fiber.HeaderContentType,
+			fiber.HeaderIfModifiedSince, fiber.HeaderIfNoneMatch, "Last-Event-ID",
+		},
+		ExposeHeaders: []string{fiber.HeaderLastModified, fiber.HeaderETag},
+	})
+}
+
+// checkOrigin reports whether o is usable as a CORS allowed origin.
+func checkOrigin(o string) error {
+	if o == "*" {
+		return nil
+	}
+	u, err := url.Parse(o)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("cors: invalid origin %q: want \"*\" or scheme://host[:port]", o)
+	}
+	return nil
+}
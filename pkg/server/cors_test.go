@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	get := func(s *Server, origin string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+		req.Header.Set("Origin", origin)
+		resp, err := s.app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	off := New("", Config{Interval: time.Second, HistoryCap: 10})
+	if h := get(off, "https://dash.example").Header.Get("Access-Control-Allow-Origin"); h != "" {
+		t.Errorf("disabled: want no header, got %q", h)
+	}
+
+	wild := New("", Config{Interval: time.Second, HistoryCap: 10, CORSOrigins: []string{"*"}})
+	if h := get(wild, "https://dash.example").Header.Get("Access-Control-Allow-Origin"); h != "*" {
+		t.Errorf("wildcard: want *, got %q", h)
+	}
+
+	list := New("", Config{Interval: time.Second, HistoryCap: 10, CORSOrigins: []string{"https://a.example", "http://b.example:8080", "https://*.c.example"}})
+	if h := get(list, "http://b.example:8080").Header.Get("Access-Control-Allow-Origin"); h != "http://b.example:8080" {
+		t.Errorf("listed origin: want it echoed, got %q", h)
+	}
+	if h := get(list, "https://dash.c.example").Header.Get("Access-Control-Allow-Origin"); h != "https://dash.c.example" {
+		t.Errorf("wildcard subdomain: want it echoed, got %q", h)
+	}
+	if h := get(list, "https://evil.example").Header.Get("Access-Control-Allow-Origin"); h != "" {
+		t.Errorf("unlisted origin: want no header, got %q", h)
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	// Preflights carry no credentials, so they must succeed with auth on.
+	s := New("", Config{Interval: time.Second, HistoryCap: 10, CORSOrigins: []string{"*"}, AuthUser: "u", AuthPass: "p"})
+	req := httptest.NewRequest(http.MethodOptions, "/api/stats", nil)
+	req.Header.Set("Origin", "https://dash.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "authorization")
+	resp, err := s.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("want 204, got %d", resp.StatusCode)
+	}
+	if h := resp.Header.Get("Access-Control-Allow-Methods"); h == "" {
+		t.Error("missing Access-Control-Allow-Methods")
+	}
+	if h := resp.Header.Get("Access-Control-Allow-Headers"); h == "" {
+		t.Error("missing Access-Control-Allow-Headers")
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	for _, o := range []string{"*", "https://a.example", "http://a.example:8080", "https://*.example.com"} {
+		if err := checkOrigin(o); err != nil {
+			t.Errorf("%q: %v", o, err)
+		}
+	}
+	for _, o := range []string{"a.example", "ftp://a.example", "https://a.example/path", "https://user@a.example"} {
+		if checkOrigin(o) == nil {
+			t.Errorf("%q: want error", o)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+	"github.com/mailru/easyjson/jwriter"
+)
+
+// sseEventDelta names the events on /events that carry only what changed
+// since the previous stats event; see diffStats.
+const sseEventDelta = "delta"
+
+// deltaField is one scalar field of CakeStats or CakeTier and its JSON name.
+type deltaField struct {
+	name  string
+	index int
+	kind  reflect.Kind
+	time  bool
+}
+
+var (
+	statsFields = scalarFields(reflect.TypeFor[types.CakeStats]())
+	tierFields  = scalarFields(reflect.TypeFor[types.CakeTier]())
+	tiersIndex  = fieldIndex(reflect.TypeFor[types.CakeStats](), "Tiers")
+)
+
+// scalarFields lists the JSON-encoded fields of t, skipping the slices,
+// which diffStats handles itself.
+func scalarFields(t reflect.Type) []deltaField {
+	var out []deltaField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" || f.Type.Kind() == reflect.Slice {
+			continue
+		}
+		out = append(out, deltaField{
+			name:  name,
+			index: i,
+			kind:  f.Type.Kind(),
+			time:  f.Type == reflect.TypeFor[time.Time](),
+		})
+	}
+	return out
+}
+
+func fieldIndex(t reflect.Type, name string) int {
+	f, _ := t.FieldByName(name)
+	return f.Index[0]
+}
+
+// diffStats encodes the changes from prev to curr as a delta event payload:
+//
+//	{"updated_at":"…","interfaces":{"eth0":{"sent_bytes":123456,"tiers":[null,{"pkts":999}]}}}
+//
+// Only interfaces with a changed field are listed, with only those fields;
+// a tier that did not change is null.  ok is false when the two cannot be
+// diffed field by field, because the interfaces or the number of tiers of
+// one of them differ, and the client needs the full stats event.
+func diffStats(prev, curr []types.CakeStats, updatedAt string) (delta []byte, ok bool) {
+	if len(prev) != len(curr) {
+		return nil, false
+	}
+	for i := range curr {
+		if prev[i].Interface != curr[i].Interface || len(prev[i].Tiers) != len(curr[i].Tiers) {
+			return nil, false
+		}
+	}
+	var w jwriter.Writer
+	w.RawString(`{"updated_at":`)
+	w.String(updatedAt)
+	w.RawString(`,"interfaces":{`)
+	first := true
+	for i := range curr {
+		p, c := reflect.ValueOf(&prev[i]).Elem(), reflect.ValueOf(&curr[i]).Elem()
+		if !statsDiffer(p, c) {
+			continue
+		}
+		if !first {
+			w.RawByte(',')
+		}
+		first = false
+		w.String(curr[i].Interface)
+		w.RawByte(':')
+		appendDelta(&w, p, c)
+	}
+	w.RawString("}}")
+	delta, _ = w.BuildBytes()
+	return delta, true
+}
+
+// statsDiffer reports whether any field of two CakeStats, tiers included,
+// differs.  The writer's buffer is chunked and cannot be truncated, so
+// diffStats checks before it writes.
+func statsDiffer(prev, curr reflect.Value) bool {
+	if fieldsDiffer(statsFields, prev, curr) {
+		return true
+	}
+	prevTiers, currTiers := prev.Field(tiersIndex), curr.Field(tiersIndex)
+	for i := range currTiers.Len() {
+		if fieldsDiffer(tierFields, prevTiers.Index(i), currTiers.Index(i)) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendDelta writes the object of the fields of a CakeStats that differ
+// between prev and curr, with the tiers array only if a tier changed.
+func appendDelta(w *jwriter.Writer, prev, curr reflect.Value) {
+	w.RawByte('{')
+	changed := appendFields(w, statsFields, prev, curr)
+	prevTiers, currTiers := prev.Field(tiersIndex), curr.Field(tiersIndex)
+	tierChanged := make([]bool, currTiers.Len())
+	anyTier := false
+	for i := range tierChanged {
+		tierChanged[i] = fieldsDiffer(tierFields, prevTiers.Index(i), currTiers.Index(i))
+		anyTier = anyTier || tierChanged[i]
+	}
+	if anyTier {
+		if changed {
+			w.RawByte(',')
+		}
+		w.RawString(`"tiers":[`)
+		for i, ch := range tierChanged {
+			if i > 0 {
+				w.RawByte(',')
+			}
+			if !ch {
+				w.RawString("null")
+				continue
+			}
+			w.RawByte('{')
+			appendFields(w, tierFields, prevTiers.Index(i), currTiers.Index(i))
+			w.RawByte('}')
+		}
+		w.RawByte(']')
+	}
+	w.RawByte('}')
+}
+
+// fieldsDiffer reports whether any of fields differs between prev and curr.
+func fieldsDiffer(fields []deltaField, prev, curr reflect.Value) bool {
+	for _, f := range fields {
+		if f.differs(prev, curr) {
+			return true
+		}
+	}
+	return false
+}
+
+// differs compares field f of prev and curr; times compare by instant.
+func (f deltaField) differs(prev, curr reflect.Value) bool {
+	p, c := prev.Field(f.index), curr.Field(f.index)
+	if f.time {
+		return !p.Interface().(time.Time).Equal(c.Interface().(time.Time))
+	}
+	return !p.Equal(c)
+}
+
+// appendFields writes "name":value for every field in fields that differs
+// between prev and curr, comma-separated, and reports whether it wrote any.
+func appendFields(w *jwriter.Writer, fields []deltaField, prev, curr reflect.Value) bool {
+	n := 0
+	for _, f := range fields {
+		if !f.differs(prev, curr) {
+			continue
+		}
+		c := curr.Field(f.index)
+		if n > 0 {
+			w.RawByte(',')
+		}
+		n++
+		w.String(f.name)
+		w.RawByte(':')
+		switch {
+		case f.time:
+			w.Raw(c.Interface().(time.Time).MarshalJSON())
+		case f.kind == reflect.String:
+			w.String(c.String())
+		case f.kind == reflect.Bool:
+			w.Bool(c.Bool())
+		case f.kind == reflect.Float64:
+			w.Float64(c.Float())
+		case f.kind >= reflect.Uint && f.kind <= reflect.Uint64:
+			w.Uint64(c.Uint())
+		default:
+			w.Int64(c.Int())
+		}
+	}
+	return n > 0
+}
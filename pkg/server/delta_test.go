@@ -0,0 +1,67 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func deltaFixture() []types.CakeStats {
+	return []types.CakeStats{
+		{Interface: "eth0", SentBytes: 1000, UpdatedAt: time.Unix(1700000000, 0).UTC(), Tiers: []types.CakeTier{
+			{Name: "Bulk", Pkts: 10}, {Name: "Best Effort", Pkts: 20},
+		}},
+		{Interface: "ifb4eth0", SentBytes: 500, DropsPerS: 1.5},
+	}
+}
+
+func TestDiffStats_Unchanged(t *testing.T) {
+	got, ok := diffStats(deltaFixture(), deltaFixture(), "2023-11-14T22:13:20Z")
+	if !ok {
+		t.Fatal("want a delta for an unchanged snapshot")
+	}
+	if want := `{"updated_at":"2023-11-14T22:13:20Z","interfaces":{}}`; string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestDiffStats_Changed(t *testing.T) {
+	prev, curr := deltaFixture(), deltaFixture()
+	curr[0].SentBytes = 123456
+	curr[0].UpdatedAt = curr[0].UpdatedAt.Add(time.Second)
+	curr[0].Tiers[1].Pkts = 999
+	curr[1].DropsPerS = 0
+	curr[1].Direction = "ingress"
+	got, ok := diffStats(prev, curr, "2023-11-14T22:13:21Z")
+	if !ok {
+		t.Fatal("want a delta")
+	}
+	want := `{"updated_at":"2023-11-14T22:13:21Z","interfaces":{` +
+		`"eth0":{"sent_bytes":123456,"updated_at":"2023-11-14T22:13:21Z","tiers":[null,{"pkts":999}]},` +
+		`"ifb4eth0":{"direction":"ingress","drops_per_s":0}}}`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	// A tier change alone still produces the interface's object.
+	curr = deltaFixture()
+	curr[0].Tiers[0].Name = "Video"
+	got, _ = diffStats(prev, curr, "x")
+	if want := `{"updated_at":"x","interfaces":{"eth0":{"tiers":[{"name":"Video"},null]}}}`; string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestDiffStats_Reshaped(t *testing.T) {
+	prev := deltaFixture()
+	for name, curr := range map[string][]types.CakeStats{
+		"interface gone":  deltaFixture()[:1],
+		"interface moved": {deltaFixture()[1], deltaFixture()[0]},
+		"tier added":      append(deltaFixture()[:1:1], types.CakeStats{Interface: "ifb4eth0", Tiers: []types.CakeTier{{}}}),
+	} {
+		if _, ok := diffStats(prev, curr, "x"); ok {
+			t.Errorf("%s: want no delta", name)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/galpt/cake-stats/pkg/history"
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// historyMetric describes how one HistorySample field is exported.
+type historyMetric struct {
+	name  string
+	help  string
+	value func(types.HistorySample) float64
+}
+
+var historyMetrics = []historyMetric{
+	{"cake_tx_bytes_per_second", "Transmitted bytes per second.", func(s types.HistorySample) float64 { return s.Tx }},
+	{"cake_av_delay_milliseconds", "Maximum average delay across tiers in milliseconds.", func(s types.HistorySample) float64 { return s.Av }},
+	{"cake_pk_delay_milliseconds", "Maximum peak delay across tiers in milliseconds.", func(s types.HistorySample) float64 { return s.Pk }},
+	{"cake_drops_per_second", "Packets dropped per second.", func(s types.HistorySample) float64 { return s.Dr }},
+	{"cake_way_miss_delta", "Increase in way_miss across tiers since the previous sample.", func(s types.HistorySample) float64 { return float64(s.Wm) }},
+	{"cake_way_inds_per_second", "Flow hash indirections per second across tiers.", func(s types.HistorySample) float64 { return s.Wi }},
+	{"cake_sp_delay_milliseconds", "Maximum sparse-flow delay across tiers in milliseconds.", func(s types.HistorySample) float64 { return s.Sp }},
+	{"cake_requeues_per_second", "Requeues per second.", func(s types.HistorySample) float64 { return s.Rq }},
+	{"cake_ecn_marks_per_second", "ECN marks per second across tiers.", func(s types.HistorySample) float64 { return s.Mk }},
+	{"cake_ack_drops_per_second", "ACK-filter drops per second across tiers.", func(s types.HistorySample) float64 { return s.Ad }},
+	{"cake_max_tier_flows", "Active flows in the busiest tier.", func(s types.HistorySample) float64 { return s.Fl }},
+	{"cake_utilization_percent", "Transmit rate as a percentage of the shaper bandwidth.", func(s types.HistorySample) float64 { return s.Util }},
+	{"cake_memory_percent", "Memory used as a percentage of the CAKE memory limit.", func(s types.HistorySample) float64 { return s.Mem }},
+}
+
+// sampleSeconds returns the sample timestamp in Unix seconds at the highest
+// precision the sample carries.
+func sampleSeconds(s types.HistorySample) float64 {
+	return float64(history.SampleTime(s).UnixMicro()) / 1e6
+}
+
+// writeOpenMetrics renders every checkpointed sample as OpenMetrics text: one
+// gauge family per HistorySample field, one timestamped line per sample, and
+// the mandatory trailing "# EOF".
+func writeOpenMetrics(w io.Writer, cp history.HistoryCheckpoint) error {
+	bw := bufio.NewWriter(w)
+	ifaces := cp.Interfaces()
+	var buf []byte
+	for _, m := range historyMetrics {
+		bw.WriteString("# HELP " + m.name + " " + m.help + "\n")
+		bw.WriteString("# TYPE " + m.name + " gauge\n")
+		for _, iface := range ifaces {
+			label := m.name + `{iface="` + escapeLabel(iface) + `"} `
+			for _, smp := range cp.Samples(iface) {
+				buf = append(buf[:0], label...)
+				buf = strconv.AppendFloat(buf, m.value(smp), 'g', -1, 64)
+				buf = append(buf, ' ')
+				buf = strconv.AppendFloat(buf, sampleSeconds(smp), 'f', -1, 64)
+				buf = append(buf, '\n')
+				bw.Write(buf)
+			}
+		}
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value for Prometheus/OpenMetrics text.
+func escapeLabel(v string) string { return labelEscaper.Replace(v) }
+
+// csvHeader names the per-sample columns of the CSV export.
+var csvHeader = []string{"timestamp", "tx_bytes_per_s", "av_delay_ms", "pk_delay_ms", "sp_delay_ms", "drops_per_s"}
+
+// writeCSV renders the checkpointed samples of ifaces as RFC 4180 CSV with a
+// header row.  withIface prepends an "interface" column, used when the
+// export covers more than one interface.
+func writeCSV(w io.Writer, cp history.HistoryCheckpoint, ifaces []string, withIface bool) error {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	header := csvHeader
+	if withIface {
+		header = append([]string{"interface"}, header...)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	row := make([]string, 0, len(header))
+	for _, iface := range ifaces {
+		for _, smp := range cp.Samples(iface) {
+			row = row[:0]
+			if withIface {
+				row = append(row, iface)
+			}
+			row = append(row,
+				strconv.FormatFloat(sampleSeconds(smp), 'f', -1, 64),
+				strconv.FormatFloat(smp.Tx, 'f', -1, 64),
+				strconv.FormatFloat(smp.Av, 'f', -1, 64),
+				strconv.FormatFloat(smp.Pk, 'f', -1, 64),
+				strconv.FormatFloat(smp.Sp, 'f', -1, 64),
+				strconv.FormatFloat(smp.Dr, 'f', -1, 64),
+			)
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportFilename builds an attachment filename from iface, replacing
+// anything outside [A-Za-z0-9._-] so namespaced names ("ns/eth0") and odd
+// interface names stay a single safe path component.
+func exportFilename(iface, ext string) string {
+	if iface == "" {
+		return "cake-stats." + ext
+	}
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, iface)
+	return "cake-stats-" + safe + "." + ext
+}
+
+var influxTagEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// writeInflux renders the samples of ifaces as InfluxDB line protocol under
+// the "cake_stats" measurement, tagged by iface, with nanosecond timestamps.
+// Per-tier samples follow each interface's own lines with an extra "tier"
+// tag.
+func writeInflux(w io.Writer, cp history.HistoryCheckpoint, tiers map[string][]types.TierHistorySample, ifaces []string) error {
+	bw := bufio.NewWriter(w)
+	var buf []byte
+	field := func(sep, key string, v float64) {
+		buf = append(buf, sep...)
+		buf = append(buf, key...)
+		buf = append(buf, '=')
+		buf = strconv.AppendFloat(buf, v, 'g', -1, 64)
+	}
+	for _, iface := range ifaces {
+		prefix := "cake_stats,iface=" + influxTagEscaper.Replace(iface)
+		for _, smp := range cp.Samples(iface) {
+			buf = append(buf[:0], prefix...)
+			field(" ", "tx", smp.Tx)
+			field(",", "av", smp.Av)
+			field(",", "pk", smp.Pk)
+			field(",", "sp", smp.Sp)
+			field(",", "dr", smp.Dr)
+			buf = append(buf, ' ')
+			buf = strconv.AppendInt(buf, history.SampleTime(smp).UnixNano(), 10)
+			buf = append(buf, '\n')
+			bw.Write(buf)
+		}
+		for _, ts := range tiers[iface] {
+			buf = append(buf[:0], prefix...)
+			buf = append(buf, ",tier="...)
+			buf = append(buf, influxTagEscaper.Replace(ts.Name)...)
+			field(" ", "tx", ts.TxBytesPerS)
+			field(",", "av", ts.AvMs)
+			field(",", "pk", ts.PkMs)
+			field(",", "dr", ts.DrRate)
+			buf = append(buf, ' ')
+			buf = strconv.AppendInt(buf, ts.T*1e9, 10)
+			buf = append(buf, '\n')
+			bw.Write(buf)
+		}
+	}
+	return bw.Flush()
+}
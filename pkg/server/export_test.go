@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/galpt/cake-stats/pkg/history"
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestHistoryOpenMetrics(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth1"}})
+	s.forcePoll()
+	s.forcePoll()
+	s.forcePoll()
+
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history/openmetrics", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Errorf("content-type: got %q", ct)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	out := string(b)
+	if !strings.HasPrefix(out, "# HELP ") {
+		t.Errorf("output must start with # HELP, got %q", out[:min(len(out), 40)])
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Errorf("output must end with # EOF")
+	}
+	for _, m := range historyMetrics {
+		if !strings.Contains(out, "# TYPE "+m.name+" gauge\n") {
+			t.Errorf("missing TYPE line for %s", m.name)
+		}
+		if got := strings.Count(out, m.name+`{iface="eth1"} `); got != 2 {
+			t.Errorf("%s: want 2 samples for eth1, got %d", m.name, got)
+		}
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("escapeLabel: got %q", got)
+	}
+}
+
+func TestHistoryCSV(t *testing.T) {
+	s := newTestServer([]types.CakeStats{
+		{Interface: "eth0", Tiers: []types.CakeTier{{AvDelay: "2ms", PkDelay: "5ms", SpDelay: "1ms"}}},
+		{Interface: "eth1"},
+	})
+	s.forcePoll()
+	s.forcePoll()
+	s.forcePoll()
+
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history.csv?iface=eth0", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("content-type: got %q", ct)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); cd != `attachment; filename="cake-stats-eth0.csv"` {
+		t.Errorf("content-disposition: got %q", cd)
+	}
+	rows, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("want header + 2 rows, got %d: %v", len(rows), rows)
+	}
+	if got := strings.Join(rows[0], ","); got != "timestamp,tx_bytes_per_s,av_delay_ms,pk_delay_ms,sp_delay_ms,drops_per_s" {
+		t.Errorf("header: got %q", got)
+	}
+	if row := rows[2]; row[2] != "2" || row[3] != "5" || row[4] != "1" {
+		t.Errorf("data row: got %v", row)
+	}
+
+	resp, _ = s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history.csv", nil))
+	rows, err = csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 || rows[0][0] != "interface" || rows[1][0] != "eth0" || rows[4][0] != "eth1" {
+		t.Errorf("all interfaces: got %v", rows)
+	}
+
+	resp, _ = s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history.csv?iface=eth9", nil))
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown iface: want 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestExportFilename(t *testing.T) {
+	if got := exportFilename("wan/eth0.100", "csv"); got != "cake-stats-wan_eth0.100.csv" {
+		t.Errorf("got %q", got)
+	}
+	if got := exportFilename("", "csv"); got != "cake-stats.csv" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestHistoryInflux(t *testing.T) {
+	s := newTestServer([]types.CakeStats{
+		{Interface: "eth0", Tiers: []types.CakeTier{{Name: "Best Effort", AvDelay: "2ms"}}},
+		{Interface: "eth1"},
+	})
+	s.forcePoll()
+	s.forcePoll()
+
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history.influx?iface=eth0", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("content-type: got %q", ct)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want 1 interface line + 1 tier line, got %q", lines)
+	}
+	smp := s.history.Snapshot().Samples["eth0"][0]
+	ns := strconv.FormatInt(smp.T*1e9, 10)
+	if want := "cake_stats,iface=eth0 tx=0,av=2,pk=0,sp=0,dr=0 " + strconv.FormatInt(history.SampleTime(smp).UnixNano(), 10); lines[0] != want {
+		t.Errorf("interface line:\nwant %q\ngot  %q", want, lines[0])
+	}
+	if want := `cake_stats,iface=eth0,tier=Best\ Effort tx=0,av=2,pk=0,dr=0 ` + ns; lines[1] != want {
+		t.Errorf("tier line:\nwant %q\ngot  %q", want, lines[1])
+	}
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/buildinfo"
+	"github.com/galpt/cake-stats/pkg/log"
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// influxRetries is the number of retries after a transient write failure;
+// the delay before each doubles from influxWriter.retryDelay.
+const influxRetries = 1
+
+// influxWriter writes every poll to an InfluxDB v2 bucket through
+// /api/v2/write.  Like webhook, it sends from its own goroutine behind a
+// one-slot queue, so a slow server costs samples rather than delaying the
+// poller.
+//
+// Each interface is one "cake" point tagged iface, direction and handle,
+// with the statsdMetrics as fields; each tier is one "cake_tier" point with
+// a tier tag as well and the statsdTierMetrics as fields:
+//
+//	cake,iface=eth0,direction=egress,handle=8001: tx_bytes_per_s=1250000,… 1700000000000000000
+//	cake_tier,iface=eth0,direction=egress,handle=8001:,tier=Best\ Effort av_delay_ms=0.042,… 1700000000000000000
+type influxWriter struct {
+	url        string
+	token      string
+	client     *http.Client
+	retryDelay time.Duration
+	queue      chan []byte
+	// failing is set after a failed write, so a server that stays down is
+	// logged once rather than on every poll.
+	failing bool
+}
+
+// newInfluxWriter validates the Config.Influx* fields and returns the writer
+// for them, or nil when InfluxURL is empty.
+func newInfluxWriter(cfg Config) (*influxWriter, error) {
+	if cfg.InfluxURL == "" {
+		return nil, nil
+	}
+	if cfg.InfluxOrg == "" || cfg.InfluxBucket == "" {
+		return nil, errors.New("influx: url needs an org and a bucket")
+	}
+	u, err := url.Parse(cfg.InfluxURL)
+	if err != nil {
+		return nil, fmt.Errorf("influx: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("influx: url %q is not http or https", cfg.InfluxURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v2/write"
+	u.RawQuery = url.Values{
+		"org":       {cfg.InfluxOrg},
+		"bucket":    {cfg.InfluxBucket},
+		"precision": {"ns"},
+	}.Encode()
+	return &influxWriter{
+		url:        u.String(),
+		token:      cfg.InfluxToken,
+		client:     &http.Client{Timeout: DefaultWebhookTimeout},
+		retryDelay: 500 * time.Millisecond,
+		queue:      make(chan []byte, 1),
+	}, nil
+}
+
+// enqueue hands the line protocol for stats to the write goroutine without
+// blocking, replacing a batch still waiting to be sent.  Interfaces without
+// an UpdatedAt are stamped with now.
+func (w *influxWriter) enqueue(stats []types.CakeStats, now time.Time) {
+	batch := appendInfluxLines(nil, stats, now)
+	if len(batch) == 0 {
+		return
+	}
+	for {
+		select {
+		case w.queue <- batch:
+			return
+		default:
+		}
+		select {
+		case <-w.queue:
+		default:
+		}
+	}
+}
+
+// run writes queued batches until ctx is done.
+func (w *influxWriter) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case b := <-w.queue:
+			w.logResult(ctx, w.write(ctx, b))
+		}
+	}
+}
+
+// logResult warns about the first failed write and notes the recovery.
+func (w *influxWriter) logResult(ctx context.Context, err error) {
+	switch {
+	case err != nil && ctx.Err() == nil && !w.failing:
+		w.failing = true
+		log.Logger.Warn().Err(err).Msg("influx write failed; retrying every poll")
+	case err == nil && w.failing:
+		w.failing = false
+		log.Logger.Info().Msg("influx write recovered")
+	}
+}
+
+// write POSTs batch, retrying after a transient failure (a transport
+// error, 429 or 5xx), and returns the final error.
+func (w *influxWriter) write(ctx context.Context, batch []byte) error {
+	delay := w.retryDelay
+	retry, err := w.post(ctx, batch)
+	for i := 0; err != nil && retry && i < influxRetries; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		retry, err = w.post(ctx, batch)
+	}
+	return err
+}
+
+// post makes one attempt and reports whether a failure is worth retrying.
+func (w *influxWriter) post(ctx context.Context, batch []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(batch))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "cake-stats/"+buildinfo.Version)
+	if w.token != "" {
+		req.Header.Set("Authorization", "Token "+w.token)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	// Errors come back as a JSON message worth logging; keep the start.
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("influx: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("influx: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return false, nil
+}
+
+// appendInfluxLines appends the cake and cake_tier points of stats to buf.
+func appendInfluxLines(buf []byte, stats []types.CakeStats, now time.Time) []byte {
+	appendFloat := func(buf []byte, sep byte, key string, v float64) []byte {
+		buf = append(buf, sep)
+		buf = append(buf, key...)
+		buf = append(buf, '=')
+		return strconv.AppendFloat(buf, v, 'g', -1, 64)
+	}
+	for i := range stats {
+		cs := &stats[i]
+		ts := cs.UpdatedAt
+		if ts.IsZero() {
+			ts = now
+		}
+		tags := influxTags(cs)
+		buf = append(buf, "cake"...)
+		buf = append(buf, tags...)
+		sep := byte(' ')
+		for _, m := range statsdMetrics {
+			buf = appendFloat(buf, sep, m.name, m.value(cs))
+			sep = ','
+		}
+		buf = append(buf, ' ')
+		buf = strconv.AppendInt(buf, ts.UnixNano(), 10)
+		buf = append(buf, '\n')
+		for k := range cs.Tiers {
+			t := &cs.Tiers[k]
+			buf = append(buf, "cake_tier"...)
+			buf = append(buf, tags...)
+			buf = append(buf, ",tier="...)
+			buf = append(buf, influxTagValue(t.Name)...)
+			sep := byte(' ')
+			for _, m := range statsdTierMetrics {
+				buf = appendFloat(buf, sep, strings.TrimPrefix(m.name, "tier."), m.value(t))
+				sep = ','
+			}
+			buf = append(buf, ' ')
+			buf = strconv.AppendInt(buf, ts.UnixNano(), 10)
+			buf = append(buf, '\n')
+		}
+	}
+	return buf
+}
+
+// influxTags is the ",iface=…,direction=…,handle=…" tag set of cs.  Line
+// protocol has no empty tag values, so a tag that is empty is left out.
+func influxTags(cs *types.CakeStats) string {
+	var b strings.Builder
+	for _, tag := range [...]struct{ key, value string }{
+		{"iface", cs.Interface},
+		{"direction", cs.Direction},
+		{"handle", cs.Handle},
+	} {
+		if tag.value == "" {
+			continue
+		}
+		b.WriteByte(',')
+		b.WriteString(tag.key)
+		b.WriteByte('=')
+		b.WriteString(influxTagValue(tag.value))
+	}
+	return b.String()
+}
+
+// influxTagValue escapes v for a tag value, or returns "_" for an empty one.
+func influxTagValue(v string) string {
+	if v == "" {
+		return "_"
+	}
+	return influxTagEscaper.Replace(v)
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestInflux_Write(t *testing.T) {
+	type request struct {
+		path, query, auth string
+		body              string
+	}
+	reqs := make(chan request, 4)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		reqs <- request{r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization"), string(b)}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	s := New("", Config{
+		Interval: time.Second, HistoryCap: 10,
+		InfluxURL: ts.URL + "/", InfluxToken: "s3cret", InfluxOrg: "home", InfluxBucket: "cake",
+	})
+	updated := time.Unix(1700000000, 0)
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		return []types.CakeStats{{
+			Interface: "eth0", Direction: "egress", Handle: "8001:", SentPkts: 42, UpdatedAt: updated,
+			Tiers: []types.CakeTier{{Name: "Best Effort", Pkts: 999, AvDelay: "42us"}},
+		}}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.influx.run(ctx)
+	s.forcePoll()
+
+	var r request
+	select {
+	case r = <-reqs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("influx not written")
+	}
+	if r.path != "/api/v2/write" || r.query != "bucket=cake&org=home&precision=ns" || r.auth != "Token s3cret" {
+		t.Errorf("got path %q, query %q, authorization %q", r.path, r.query, r.auth)
+	}
+	lines := strings.Split(strings.TrimSuffix(r.body, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want 2 lines, got %q", r.body)
+	}
+	for i, want := range []struct{ prefix, field string }{
+		{"cake,iface=eth0,direction=egress,handle=8001: bandwidth_bps=0,", ",sent_pkts=42,"},
+		{`cake_tier,iface=eth0,direction=egress,handle=8001:,tier=Best\ Effort target_ms=0,`, ",av_delay_ms=0.042,"},
+	} {
+		if !strings.HasPrefix(lines[i], want.prefix) || !strings.Contains(lines[i], want.field) ||
+			!strings.HasSuffix(lines[i], " 1700000000000000000") {
+			t.Errorf("line %d: got %q", i, lines[i])
+		}
+	}
+}
+
+func TestInflux_Retry(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		statuses []int
+		attempts int32
+	}{
+		{"success", []int{204}, 1},
+		{"5xx then success", []int{503, 204}, 2},
+		{"429 then success", []int{429, 204}, 2},
+		{"5xx twice gives up", []int{500, 500, 204}, 2},
+		{"4xx is not retried", []int{400, 204}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var n atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statuses[n.Add(1)-1])
+			}))
+			defer ts.Close()
+			w, err := newInfluxWriter(Config{InfluxURL: ts.URL, InfluxOrg: "o", InfluxBucket: "b"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.retryDelay = 0
+			w.write(context.Background(), []byte("cake x=1\n"))
+			if got := n.Load(); got != tc.attempts {
+				t.Errorf("want %d attempts, got %d", tc.attempts, got)
+			}
+		})
+	}
+}
+
+func TestNewInfluxWriter_Invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{InfluxURL: "http://localhost:8086", InfluxBucket: "b"},
+		{InfluxURL: "http://localhost:8086", InfluxOrg: "o"},
+		{InfluxURL: "localhost:8086", InfluxOrg: "o", InfluxBucket: "b"},
+	} {
+		if _, err := newInfluxWriter(cfg); err == nil {
+			t.Errorf("%+v: want an error", cfg)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+
+	"github.com/galpt/cake-stats/pkg/types"
+	"github.com/galpt/cake-stats/pkg/util"
+)
+
+// promMetric describes how one CakeStats field is exposed on /metrics.
+type promMetric struct {
+	name  string
+	help  string
+	typ   string // "counter" or "gauge"
+	value func(*types.CakeStats) float64
+}
+
+// promTierMetric is promMetric for one CakeTier field.
+type promTierMetric struct {
+	name  string
+	help  string
+	typ   string
+	value func(*types.CakeTier) float64
+}
+
+var promMetrics = []promMetric{
+	{"cake_sent_bytes_total", "Bytes sent by the qdisc.", "counter", func(cs *types.CakeStats) float64 { return float64(cs.SentBytes) }},
+	{"cake_sent_packets_total", "Packets sent by the qdisc.", "counter", func(cs *types.CakeStats) float64 { return float64(cs.SentPkts) }},
+	{"cake_dropped_total", "Packets dropped by the qdisc.", "counter", func(cs *types.CakeStats) float64 { return float64(cs.Dropped) }},
+	{"cake_overlimits_total", "Overlimit events of the shaper.", "counter", func(cs *types.CakeStats) float64 { return float64(cs.Overlimits) }},
+	{"cake_requeues_total", "Packets requeued by the qdisc.", "counter", func(cs *types.CakeStats) float64 { return float64(cs.Requeues) }},
+	{"cake_backlog_bytes", "Bytes queued in the qdisc.", "gauge", func(cs *types.CakeStats) float64 { return float64(util.ParseBytesStr(cs.BacklogBytes)) }},
+	{"cake_backlog_packets", "Packets queued in the qdisc.", "gauge", func(cs *types.CakeStats) float64 { return float64(cs.BacklogPkts) }},
+	{"cake_memory_used_bytes", "Memory used by the qdisc.", "gauge", func(cs *types.CakeStats) float64 { return float64(util.ParseBytesStr(cs.MemoryUsed)) }},
+	{"cake_memory_limit_bytes", "Memory limit of the qdisc.", "gauge", func(cs *types.CakeStats) float64 { return float64(util.ParseBytesStr(cs.MemoryTotal)) }},
+	{"cake_memory_percent", "Memory used as a percentage of the memory limit.", "gauge", func(cs *types.CakeStats) float64 { return cs.MemoryPct }},
+	{"cake_bandwidth_bits_per_second", "Shaper bandwidth; 0 when unlimited or autorate-ingress.", "gauge", func(cs *types.CakeStats) float64 { return float64(cs.BandwidthBps) }},
+	{"cake_tx_bytes_per_second", "Transmitted bytes per second.", "gauge", func(cs *types.CakeStats) float64 { return cs.TxBytesPerS }},
+	{"cake_drops_per_second", "Packets dropped per second.", "gauge", func(cs *types.CakeStats) float64 { return cs.DropsPerS }},
+	{"cake_ecn_marks_per_second", "ECN marks per second across tiers.", "gauge", func(cs *types.CakeStats) float64 { return cs.MarksPerS }},
+	{"cake_ack_drops_per_second", "ACK-filter drops per second across tiers.", "gauge", func(cs *types.CakeStats) float64 { return cs.AckDropPerS }},
+	{"cake_way_cols_per_second", "Flow hash collisions per second across tiers.", "gauge", func(cs *types.CakeStats) float64 { return cs.WayColsPerS }},
+	{"cake_max_av_delay_milliseconds", "Maximum average delay across tiers in milliseconds.", "gauge", func(cs *types.CakeStats) float64 { return cs.MaxAvDelayMs }},
+	{"cake_max_pk_delay_milliseconds", "Maximum peak delay across tiers in milliseconds.", "gauge", func(cs *types.CakeStats) float64 { return cs.MaxPkDelayMs }},
+	{"cake_max_flows", "Active flows in the busiest tier.", "gauge", func(cs *types.CakeStats) float64 { return float64(cs.MaxFlows) }},
+	{"cake_utilization_percent", "Transmit rate as a percentage of the shaper bandwidth.", "gauge", func(cs *types.CakeStats) float64 { return cs.UtilizationPct }},
+}
+
+var promTierMetrics = []promTierMetric{
+	{"cake_tier_sent_bytes_total", "Bytes sent by the tier.", "counter", func(t *types.CakeTier) float64 { return float64(t.Bytes) }},
+	{"cake_tier_sent_packets_total", "Packets sent by the tier.", "counter", func(t *types.CakeTier) float64 { return float64(t.Pkts) }},
+	{"cake_tier_drops_total", "Packets dropped by the tier.", "counter", func(t *types.CakeTier) float64 { return float64(t.Drops) }},
+	{"cake_tier_ecn_marks_total", "Packets ECN-marked by the tier.", "counter", func(t *types.CakeTier) float64 { return float64(t.Marks) }},
+	{"cake_tier_ack_drops_total", "Packets dropped by the ACK filter.", "counter", func(t *types.CakeTier) float64 { return float64(t.AckDrop) }},
+	{"cake_tier_way_inds_total", "Flow hash lookups that needed an indirection.", "counter", func(t *types.CakeTier) float64 { return float64(t.WayInds) }},
+	{"cake_tier_way_miss_total", "Flow hash lookups that missed.", "counter", func(t *types.CakeTier) float64 { return float64(t.WayMiss) }},
+	{"cake_tier_way_cols_total", "Flow hash collisions.", "counter", func(t *types.CakeTier) float64 { return float64(t.WayCols) }},
+	{"cake_tier_av_delay_milliseconds", "Average delay of the tier in milliseconds.", "gauge", func(t *types.CakeTier) float64 { return util.ParseDelayMs(t.AvDelay) }},
+	{"cake_tier_pk_delay_milliseconds", "Peak delay of the tier in milliseconds.", "gauge", func(t *types.CakeTier) float64 { return util.ParseDelayMs(t.PkDelay) }},
+	{"cake_tier_sp_delay_milliseconds", "Sparse-flow delay of the tier in milliseconds.", "gauge", func(t *types.CakeTier) float64 { return util.ParseDelayMs(t.SpDelay) }},
+	{"cake_tier_target_milliseconds", "AQM target delay of the tier in milliseconds.", "gauge", func(t *types.CakeTier) float64 { return util.ParseDelayMs(t.Target) }},
+	{"cake_tier_interval_milliseconds", "AQM interval of the tier in milliseconds.", "gauge", func(t *types.CakeTier) float64 { return util.ParseDelayMs(t.Interval) }},
+	{"cake_tier_backlog_bytes", "Bytes queued in the tier.", "gauge", func(t *types.CakeTier) float64 { return float64(util.ParseBytesStr(t.Backlog)) }},
+	{"cake_tier_sparse_flows", "Sparse flows in the tier.", "gauge", func(t *types.CakeTier) float64 { return float64(t.SpFlows) }},
+	{"cake_tier_bulk_flows", "Bulk flows in the tier.", "gauge", func(t *types.CakeTier) float64 { return float64(t.BkFlows) }},
+	{"cake_tier_unresponsive_flows", "Unresponsive flows in the tier.", "gauge", func(t *types.CakeTier) float64 { return float64(t.UnFlows) }},
+	{"cake_tier_flows", "Active flows in the tier.", "gauge", func(t *types.CakeTier) float64 { return float64(t.TotalFlows) }},
+	{"cake_tier_max_packet_bytes", "Largest packet seen by the tier.", "gauge", func(t *types.CakeTier) float64 { return float64(t.MaxLen) }},
+	{"cake_tier_quantum_bytes", "DRR quantum of the tier.", "gauge", func(t *types.CakeTier) float64 { return float64(t.Quantum) }},
+}
+
+// writePrometheus renders the latest stats in the Prometheus text exposition
+// format (version 0.0.4): one family per promMetrics entry labelled by iface
+// and direction, then one per promTierMetrics entry with an extra tier label.
+func writePrometheus(w io.Writer, stats []types.CakeStats) error {
+	bw := bufio.NewWriter(w)
+	labels := make([]string, len(stats))
+	for i := range stats {
+		labels[i] = `iface="` + escapeLabel(stats[i].Interface) + `",direction="` + escapeLabel(stats[i].Direction) + `"`
+	}
+	var buf []byte
+	line := func(name, labels string, v float64) {
+		buf = append(buf[:0], name...)
+		buf = append(buf, '{')
+		buf = append(buf, labels...)
+		buf = append(buf, "} "...)
+		buf = strconv.AppendFloat(buf, v, 'g', -1, 64)
+		buf = append(buf, '\n')
+		bw.Write(buf)
+	}
+	for _, m := range promMetrics {
+		bw.WriteString("# HELP " + m.name + " " + m.help + "\n")
+		bw.WriteString("# TYPE " + m.name + " " + m.typ + "\n")
+		for i := range stats {
+			line(m.name, labels[i], m.value(&stats[i]))
+		}
+	}
+	for _, m := range promTierMetrics {
+		bw.WriteString("# HELP " + m.name + " " + m.help + "\n")
+		bw.WriteString("# TYPE " + m.name + " " + m.typ + "\n")
+		for i := range stats {
+			for j := range stats[i].Tiers {
+				t := &stats[i].Tiers[j]
+				line(m.name, labels[i]+`,tier="`+escapeLabel(t.Name)+`"`, m.value(t))
+			}
+		}
+	}
+	return bw.Flush()
+}
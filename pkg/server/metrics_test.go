@@ -0,0 +1,91 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestMetrics(t *testing.T) {
+	s := newTestServer([]types.CakeStats{
+		{
+			Interface: "eth0", Direction: "egress", SentBytes: 1234, Dropped: 7,
+			Tiers: []types.CakeTier{{Name: "Best Effort", AvDelay: "2ms", Drops: 3}, {Name: "Voice"}},
+		},
+		{Interface: `we"ird`, Direction: "ingress"},
+	})
+	s.forcePoll()
+
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content-type: got %q", ct)
+	}
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		t.Fatalf("invalid exposition format: %v", err)
+	}
+	if len(families) != len(promMetrics)+len(promTierMetrics) {
+		t.Errorf("want %d families, got %d", len(promMetrics)+len(promTierMetrics), len(families))
+	}
+
+	for _, m := range promMetrics {
+		mf := families[m.name]
+		if mf == nil {
+			t.Errorf("missing %s", m.name)
+			continue
+		}
+		if mf.GetHelp() == "" {
+			t.Errorf("%s: missing HELP", m.name)
+		}
+		if want := strings.HasSuffix(m.name, "_total"); want != (mf.GetType() == dto.MetricType_COUNTER) {
+			t.Errorf("%s: type %v does not match its name", m.name, mf.GetType())
+		}
+		if len(mf.GetMetric()) != 2 {
+			t.Errorf("%s: want 2 series, got %d", m.name, len(mf.GetMetric()))
+		}
+	}
+
+	sent := families["cake_sent_bytes_total"].GetMetric()[0]
+	if got := labelMap(sent); got["iface"] != "eth0" || got["direction"] != "egress" {
+		t.Errorf("labels: got %v", got)
+	}
+	if got := sent.GetCounter().GetValue(); got != 1234 {
+		t.Errorf("cake_sent_bytes_total: want 1234, got %v", got)
+	}
+	if got := labelMap(families["cake_dropped_total"].GetMetric()[1])["iface"]; got != `we"ird` {
+		t.Errorf("escaped iface label: got %q", got)
+	}
+
+	av := families["cake_tier_av_delay_milliseconds"].GetMetric()
+	if len(av) != 2 {
+		t.Fatalf("cake_tier_av_delay_milliseconds: want one series per tier, got %d", len(av))
+	}
+	if got := labelMap(av[0]); got["tier"] != "Best Effort" || got["iface"] != "eth0" {
+		t.Errorf("tier labels: got %v", got)
+	}
+	if got := av[0].GetGauge().GetValue(); got != 2 {
+		t.Errorf("cake_tier_av_delay_milliseconds: want 2, got %v", got)
+	}
+	if got := families["cake_tier_drops_total"].GetMetric()[0].GetCounter().GetValue(); got != 3 {
+		t.Errorf("cake_tier_drops_total: want 3, got %v", got)
+	}
+}
+
+func labelMap(m *dto.Metric) map[string]string {
+	out := make(map[string]string, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		out[l.GetName()] = l.GetValue()
+	}
+	return out
+}
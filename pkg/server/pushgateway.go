@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/buildinfo"
+	"github.com/galpt/cake-stats/pkg/log"
+)
+
+// DefaultPushGatewayJob is the job label pushed when Config.PushGatewayJob
+// is empty.
+const DefaultPushGatewayJob = "cake-stats"
+
+// pushGateway pushes the /metrics exposition to a Prometheus Pushgateway for
+// setups where Prometheus cannot scrape cake-stats, e.g. behind NAT.  It runs
+// on its own goroutine and pushes the latest stats on every tick, so a slow
+// or unreachable gateway costs pushes but never delays the poller.
+type pushGateway struct {
+	// url is the group URL, .../metrics/job/<job>/instance/<instance>.
+	url    string
+	client *http.Client
+	// interval is the push period; 0 follows the poll interval.
+	interval time.Duration
+	// failing is set after a failed push, so a gateway that stays down is
+	// logged once rather than on every tick.
+	failing bool
+}
+
+// newPushGateway validates the Config.PushGateway* fields and returns the
+// pusher for them, or nil when PushGatewayURL is empty.  The job defaults
+// to DefaultPushGatewayJob and the instance to the host name.
+func newPushGateway(cfg Config) (*pushGateway, error) {
+	if cfg.PushGatewayURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(cfg.PushGatewayURL)
+	if err != nil {
+		return nil, fmt.Errorf("push gateway: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("push gateway: url %q is not http or https", cfg.PushGatewayURL)
+	}
+	job, instance := cfg.PushGatewayJob, cfg.PushGatewayInstance
+	if job == "" {
+		job = DefaultPushGatewayJob
+	}
+	if instance == "" {
+		if instance, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("push gateway: instance: %w", err)
+		}
+	}
+	return &pushGateway{
+		url: strings.TrimSuffix(u.String(), "/") + "/metrics" +
+			groupingPath("job", job) + groupingPath("instance", instance),
+		client:   &http.Client{Timeout: DefaultWebhookTimeout},
+		interval: cfg.PushGatewayInterval,
+	}, nil
+}
+
+// groupingPath is the "/<label>/<value>" URL segment of one grouping label.
+// Values the path cannot carry as-is, with a slash or empty, use the
+// Pushgateway's "<label>@base64" form, where "=" stands for empty.
+func groupingPath(label, value string) string {
+	switch {
+	case value == "":
+		return "/" + label + "@base64/="
+	case strings.Contains(value, "/"):
+		return "/" + label + "@base64/" + base64.URLEncoding.EncodeToString([]byte(value))
+	}
+	return "/" + label + "/" + url.PathEscape(value)
+}
+
+// run pushes the latest stats of s every interval until ctx is done.
+func (p *pushGateway) run(ctx context.Context, s *Server) {
+	t := time.NewTimer(p.period(s))
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		var buf bytes.Buffer
+		s.statsMu.RLock()
+		n := len(s.stats)
+		err := writePrometheus(&buf, s.stats)
+		s.statsMu.RUnlock()
+		if err == nil && n > 0 {
+			p.logResult(ctx, p.push(ctx, buf.Bytes()))
+		}
+		t.Reset(p.period(s))
+	}
+}
+
+// period is the push interval, the poll interval unless configured.
+func (p *pushGateway) period(s *Server) time.Duration {
+	if p.interval > 0 {
+		return p.interval
+	}
+	return s.interval()
+}
+
+// logResult warns about the first failed push and notes the recovery.
+func (p *pushGateway) logResult(ctx context.Context, err error) {
+	switch {
+	case err != nil && ctx.Err() == nil && !p.failing:
+		p.failing = true
+		log.Logger.Warn().Err(err).Str("url", p.url).Msg("push gateway push failed; retrying every interval")
+	case err == nil && p.failing:
+		p.failing = false
+		log.Logger.Info().Str("url", p.url).Msg("push gateway push recovered")
+	}
+}
+
+// push POSTs one exposition to the group, replacing the metrics of the
+// same names pushed before.
+func (p *pushGateway) push(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	req.Header.Set("User-Agent", "cake-stats/"+buildinfo.Version)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("push gateway: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestPushGateway_Push(t *testing.T) {
+	type request struct {
+		method, path, contentType, body string
+	}
+	reqs := make(chan request, 16)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		select {
+		case reqs <- request{r.Method, r.URL.EscapedPath(), r.Header.Get("Content-Type"), string(b)}:
+		default:
+		}
+	}))
+	defer ts.Close()
+
+	s := New("", Config{
+		Interval: time.Second, HistoryCap: 10,
+		PushGatewayURL: ts.URL, PushGatewayInstance: "router", PushGatewayInterval: 10 * time.Millisecond,
+	})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		return []types.CakeStats{{Interface: "eth0", Direction: "egress", SentPkts: 42}}, nil
+	}
+	s.forcePoll()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.pushGateway.run(ctx, s)
+
+	var r request
+	select {
+	case r = <-reqs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing pushed")
+	}
+	if r.method != http.MethodPost || r.path != "/metrics/job/cake-stats/instance/router" ||
+		r.contentType != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("got %s %s with content-type %q", r.method, r.path, r.contentType)
+	}
+	if !strings.Contains(r.body, `cake_sent_packets_total{iface="eth0",direction="egress"} 42`+"\n") {
+		t.Errorf("body is not the /metrics exposition:\n%s", r.body)
+	}
+}
+
+func TestGroupingPath(t *testing.T) {
+	for _, tc := range []struct{ value, want string }{
+		{"router", "/instance/router"},
+		{"10.0.0.1:11112", "/instance/10.0.0.1:11112"},
+		{"a b", "/instance/a%20b"},
+		{"/var/tmp", "/instance@base64/L3Zhci90bXA="},
+		{"", "/instance@base64/="},
+	} {
+		if got := groupingPath("instance", tc.value); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.value, got, tc.want)
+		}
+	}
+}
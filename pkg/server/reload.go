@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/log"
+)
+
+// Reload applies the settings that can change without a restart: Interval
+// (from the poller's next tick), HistoryCap and HistoryCapacities (rings are
+// resized on their next sample) and WebhookURL.  The other Config fields are
+// ignored.  Streaming clients stay connected.
+func (s *Server) Reload(cfg Config) {
+	// Hold pollMu so a poll sees either the old settings or the new ones.
+	s.pollMu.Lock()
+	defer s.pollMu.Unlock()
+	if cfg.Interval > 0 {
+		s.pollInterval.Store(int64(cfg.Interval))
+	}
+	if cfg.HistoryCap > 0 {
+		s.history.SetDefaultCapacity(cfg.HistoryCap)
+	}
+	for iface := range s.historyCaps {
+		if _, ok := cfg.HistoryCapacities[iface]; !ok {
+			s.history.SetCapacity(iface, 0)
+		}
+	}
+	for iface, n := range cfg.HistoryCapacities {
+		s.history.SetCapacity(iface, n)
+	}
+	s.historyCaps = cfg.HistoryCapacities
+	s.webhook.setURL(cfg.WebhookURL)
+	log.Logger.Info().
+		Dur("interval", time.Duration(s.pollInterval.Load())).
+		Int("history", cfg.HistoryCap).
+		Bool("webhook", cfg.WebhookURL != "").
+		Msg("configuration reloaded")
+}
+
+// WatchReload calls load and applies its result with Reload every time the
+// process receives SIGHUP, until ctx is done.  A load error is logged and
+// the running settings are kept.  The signal handler is installed before
+// WatchReload returns.
+func (s *Server) WatchReload(ctx context.Context, load func() (Config, error)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				cfg, err := load()
+				if err != nil {
+					log.Logger.Error().Err(err).Msg("reload failed, keeping the running configuration")
+					continue
+				}
+				s.Reload(cfg)
+			}
+		}
+	}()
+}
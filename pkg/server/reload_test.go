@@ -0,0 +1,63 @@
+//go:build unix
+
+package server
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestWatchReload_SIGHUP(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	loaded := make(chan struct{}, 1)
+	// Atomic: the signal gives the race detector no happens-before edge.
+	var fail atomic.Bool
+	s.WatchReload(ctx, func() (Config, error) {
+		defer func() { loaded <- struct{}{} }()
+		if fail.Load() {
+			return Config{}, errors.New("bad config")
+		}
+		return Config{Interval: 250 * time.Millisecond, HistoryCap: 50, WebhookURL: "http://127.0.0.1:1/hook"}, nil
+	})
+
+	hup := func() {
+		t.Helper()
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-loaded:
+		case <-time.After(2 * time.Second):
+			t.Fatal("SIGHUP did not trigger a reload")
+		}
+	}
+
+	hup()
+	// Reload runs after load returns; wait for it to land.
+	deadline := time.Now().Add(2 * time.Second)
+	for s.interval() != 250*time.Millisecond && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := s.interval(); got != 250*time.Millisecond {
+		t.Fatalf("interval: want 250ms, got %v", got)
+	}
+	if got := s.webhook.getURL(); got != "http://127.0.0.1:1/hook" {
+		t.Errorf("webhook url: got %q", got)
+	}
+
+	fail.Store(true)
+	hup()
+	time.Sleep(20 * time.Millisecond)
+	if got := s.interval(); got != 250*time.Millisecond {
+		t.Errorf("failed reload changed the interval to %v", got)
+	}
+}
@@ -2,17 +2,35 @@ package server
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	_ "embed"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/coreos/go-systemd/v22/daemon"
 	easyjson "github.com/mailru/easyjson"
+	"github.com/mailru/easyjson/buffer"
+	"github.com/mailru/easyjson/jwriter"
 
 	fiber "github.com/gofiber/fiber/v3"
 	recovermiddleware "github.com/gofiber/fiber/v3/middleware/recover"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
 
+	"github.com/galpt/cake-stats/pkg/alert"
+	"github.com/galpt/cake-stats/pkg/buildinfo"
 	"github.com/galpt/cake-stats/pkg/history"
 	"github.com/galpt/cake-stats/pkg/log"
 	"github.com/galpt/cake-stats/pkg/parser"
@@ -22,73 +40,726 @@ import (
 //go:embed index.html
 var indexHTML string
 
+// openAPISpec is the hand-maintained OpenAPI 3.0 description of the API.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
 const sseBufSize = 4
 
+// DefaultSSEHeartbeat is how often an idle SSE stream gets a comment line
+// when Config.SSEHeartbeat is zero.
+const DefaultSSEHeartbeat = 30 * time.Second
+
+var sseHeartbeatEvent = []byte(": heartbeat\n\n")
+
+// sseEventStats names the per-poll StatsResponse events on /events.
+// Browsers must listen with addEventListener("stats", …); onmessage only
+// sees unnamed events.
+const sseEventStats = "stats"
+
+// sseEventAlert names the CakeAlert events on /events.
+const sseEventAlert = "alert"
+
+// sseEventTopology names the TopologyEvent events on /events, sent when an
+// interface appears or its history is pruned.
+const sseEventTopology = "topology"
+
+// sseEventHistory names the events replayed to a reconnecting SSE client:
+// one HistoryResponse per sample timestamp missed since its Last-Event-ID.
+const sseEventHistory = "history"
+
+// Config holds the tunables passed from the command line.  Zero values select
+// the defaults.
+type Config struct {
+	Interval   time.Duration // tc poll interval
+	HistoryCap int           // samples retained per interface
+	// HistoryCapacities overrides HistoryCap for individual interfaces.
+	HistoryCapacities map[string]int
+	// MinuteHistoryCap is the number of one-minute samples kept per
+	// interface; 0 keeps history.DefaultMinuteCapacity.
+	MinuteHistoryCap int
+	// HistoryPrecision is the sample timestamp resolution: time.Second,
+	// time.Millisecond or time.Microsecond; 0 selects time.Millisecond.
+	// At time.Second polls within the same second share an SSE event ID.
+	HistoryPrecision time.Duration
+	// NoWebSocket disables the /ws and /api/live WebSocket endpoints.
+	NoWebSocket bool
+	// Interfaces, when non-empty, limits polling to these devices.  The first
+	// poll scans the whole system to find which of them carry CAKE; later
+	// polls query each of those devices in parallel.
+	Interfaces []string
+	// ExcludeInterfaces are dropped from every poll before it is recorded.
+	ExcludeInterfaces []string
+	// EMAAlpha smooths the history TX series; 0 keeps
+	// history.DefaultEMAAlpha.
+	EMAAlpha float64
+	// WrapThreshold is the largest counter increase read as a 32-bit wrap
+	// rather than a reset; 0 keeps history.DefaultWrapThreshold.
+	WrapThreshold uint64
+	// DBPath, when set, persists history to a bbolt database there and
+	// restores it on start.
+	DBPath string
+	// Netns, when set, collects from that network namespace instead of our
+	// own; see parser.Collector.
+	Netns string
+	// TLSCert and TLSKey are PEM files; when both are set the server speaks
+	// HTTPS only.  TLSSelfSigned instead serves an in-memory self-signed
+	// certificate generated at start.
+	TLSCert       string
+	TLSKey        string
+	TLSSelfSigned bool
+	// TLSRedirect, when set with TLS on, is a plain-HTTP listen address
+	// (e.g. ":80") that redirects every request to the HTTPS port.
+	TLSRedirect string
+	// AuthUser and AuthPass, when both set, require HTTP Basic credentials
+	// on every route except /healthz.
+	AuthUser string
+	AuthPass string
+	// CORSOrigins, when non-empty, enables CORS for these origins ("*" for
+	// any); see corsMiddleware.
+	CORSOrigins []string
+	// HealthzAddr, when set, also serves /healthz alone on this address,
+	// outside auth, for probes that should not reach the main port.
+	HealthzAddr string
+	// WebhookURL, when set, receives a POST of the /api/stats JSON after
+	// every successful poll; WebhookTimeout bounds each attempt (0 keeps
+	// DefaultWebhookTimeout).
+	WebhookURL     string
+	WebhookTimeout time.Duration
+	// SSEHeartbeat is the interval of the ": heartbeat" comment written to
+	// every SSE stream, so proxies keep it open and a vanished client is
+	// noticed by the failed write; 0 keeps DefaultSSEHeartbeat.
+	SSEHeartbeat time.Duration
+	// AlertRules are evaluated after every poll; each alert is logged, sent
+	// to SSE clients as an "alert" event, POSTed to WebhookURL and passed
+	// to AlertDeliveries.
+	AlertRules []alert.AlertRule
+	// AlertDeliveries receive every alert after the webhook, e.g. an
+	// alert.SMTPDelivery.
+	AlertDeliveries []alert.AlertDelivery
+	// AlertLogSize is the number of alerts /api/alerts keeps; 0 keeps
+	// alert.DefaultLogSize.
+	AlertLogSize int
+	// StatsdAddr, when set, is the host:port of a StatsD agent that
+	// receives every poll as UDP gauges; see statsdClient.
+	StatsdAddr string
+	// InfluxURL, when set, is the base URL of an InfluxDB v2 server that
+	// every poll is written to, in InfluxBucket of InfluxOrg, authorized
+	// with InfluxToken; see influxWriter.
+	InfluxURL    string
+	InfluxToken  string
+	InfluxOrg    string
+	InfluxBucket string
+	// PushGatewayURL, when set, is a Prometheus Pushgateway that receives
+	// the /metrics exposition every PushGatewayInterval (0 follows
+	// Interval), grouped by PushGatewayJob (DefaultPushGatewayJob when
+	// empty) and PushGatewayInstance (the host name when empty).
+	PushGatewayURL      string
+	PushGatewayJob      string
+	PushGatewayInstance string
+	PushGatewayInterval time.Duration
+}
+
+// frame is one broadcast message.  payload is the bare StatsResponse JSON
+// (sent as-is to WebSocket clients); event is the same payload already framed
+// for SSE.  Both are built once per poll and shared by every client.  id is
+// the poll's history timestamp, the SSE event ID.  Alert frames carry only
+// an SSE event, with no payload and no id.
+//
+// seq is the snapshot generation (statsGen) the frame carries.  delta, when
+// set, is an SSE delta event with the changes since generation seq-1, for
+// SSE clients that have seen that one.
+type frame struct {
+	payload []byte
+	event   []byte
+	delta   []byte
+	id      int64
+	seq     uint64
+}
+
 // Server encapsulates the Fiber app, polling state, SSE client registry and
 // history store.  It is safe for concurrent use.
 type Server struct {
-	app          *fiber.App
-	statsMu      sync.RWMutex
-	stats        []types.CakeStats
-	ssesMu       sync.Mutex
-	clients      map[chan []byte]struct{}
-	pollInterval time.Duration
+	app     *fiber.App
+	statsMu sync.RWMutex
+	stats   []types.CakeStats
+	ssesMu  sync.Mutex
+	clients map[chan frame]struct{}
+	// pollInterval is the poller's tick as a time.Duration; Reload changes
+	// it while running.
+	pollInterval atomic.Int64
 	history      *history.HistoryStore
-	stopOnce     sync.Once
+	dbPath       string
+	// tlsCert, tlsKey, tlsSelfSigned and tlsRedirect mirror the Config TLS
+	// fields; see tlsConfig.
+	tlsCert       string
+	tlsKey        string
+	tlsSelfSigned bool
+	tlsRedirect   string
+	// authUser and authPass mirror Config; see basicAuth.
+	authUser string
+	authPass string
+	// corsOrigins mirrors Config.CORSOrigins, validated by Run.
+	corsOrigins []string
+	// healthApp serves /healthz on Config.HealthzAddr; nil when unset.
+	healthApp  *fiber.App
+	healthAddr string
+	// webhook delivers to Config.WebhookURL; it idles while the URL is
+	// empty.
+	webhook *webhook
+	// deliveries receive every alert: the webhook, then
+	// Config.AlertDeliveries.
+	deliveries []alert.AlertDelivery
+	// alerts evaluates Config.AlertRules; nil without rules.  alertsErr is
+	// a rule validation error, reported by Run.
+	alerts    *alert.AlertManager
+	alertsErr error
+	// statsdAddr mirrors Config.StatsdAddr; statsd is its client while Run
+	// is running, nil otherwise.
+	statsdAddr string
+	statsd     *statsdClient
+	// influx writes to Config.InfluxURL; nil when unset.  influxErr is a
+	// configuration error, reported by Run.
+	influx    *influxWriter
+	influxErr error
+	// pushGateway pushes to Config.PushGatewayURL; nil when unset.
+	// pushGatewayErr is a configuration error, reported by Run.
+	pushGateway    *pushGateway
+	pushGatewayErr error
+	// ready decides when to send systemd READY=1; see markReady.
+	ready readiness
+	// historyCaps are the per-interface capacities last applied, so Reload
+	// can drop the ones that disappear.  Guarded by pollMu after New.
+	historyCaps  map[string]int
+	sseHeartbeat time.Duration
+	// started is when New ran, for the /healthz uptime.
+	started  time.Time
+	stopOnce sync.Once
+	// done is closed by shutdown to end the SSE and WebSocket streams;
+	// sseWG counts the stream writers still running, which shutdown waits
+	// for.  streamMu orders a WebSocket's sseWG.Add, which happens after the
+	// connection is hijacked, before the close of done.
+	done     chan struct{}
+	sseWG    sync.WaitGroup
+	streamMu sync.Mutex
+	// pollMu serializes forcePoll.
+	pollMu sync.Mutex
+	// collector fetches the stats.  Its slices come from the parser's pool
+	// and go back to it through recycle once the server is done with them.
+	collector parser.Collector
+	// collect and collectDevices, when set, replace collector's Collect and
+	// CollectDevices; tests use them to inject fixtures, which are never
+	// recycled.
+	collect        func(context.Context) ([]types.CakeStats, error)
+	collectDevices func(context.Context, []string) ([]types.CakeStats, error)
+	// ifaces is Config.Interfaces as a set; nil polls everything.
+	ifaces map[string]bool
+	// exclude is Config.ExcludeInterfaces as a set.
+	exclude map[string]bool
+	// devices are the interfaces from ifaces found to carry CAKE by the last
+	// full scan.  Empty means the next poll scans again.  Guarded by statsMu.
+	devices []string
+	// pollErrMu guards pollErrKinds, the poll error kinds already logged at
+	// warn level since the last successful poll.
+	pollErrMu    sync.Mutex
+	pollErrKinds map[string]bool
+	// tcMissingOnce guards the single log line for parser.ErrTCNotFound.
+	tcMissingOnce sync.Once
+	// lastHistoryModified is the time the history store last gained samples,
+	// used for Last-Modified / If-Modified-Since on /api/history, and
+	// historyGen the statsGen of that poll, which goes into its ETag.  Both
+	// guarded by statsMu.
+	lastHistoryModified time.Time
+	historyGen          uint64
+	// statsUpdated is when the poller last replaced stats; it is the
+	// updated_at of /api/stats, so the body only changes with the snapshot.
+	// statsGen counts those replacements and goes into the ETag, since two
+	// polls within one second can produce the same body.  Both guarded by
+	// statsMu.
+	statsUpdated time.Time
+	statsGen     uint64
+	// statsID is the history sample time (unix milliseconds) of the poll
+	// that produced stats; it is the SSE event ID.  Guarded by statsMu.
+	statsID int64
+	// eventSize is the length of the last stats event, the buffer size
+	// buildStatsEvent starts the next one with.
+	eventSize atomic.Int64
 }
 
-func New(addr string, interval time.Duration, histCap int) *Server {
+func New(addr string, cfg Config) *Server {
 	s := &Server{
-		clients:      make(map[chan []byte]struct{}),
-		pollInterval: interval,
-		history:      history.NewHistoryStore(histCap),
+		clients:       make(map[chan frame]struct{}),
+		done:          make(chan struct{}),
+		history:       history.NewHistoryStore(cfg.HistoryCap),
+		dbPath:        cfg.DBPath,
+		tlsCert:       cfg.TLSCert,
+		tlsKey:        cfg.TLSKey,
+		tlsSelfSigned: cfg.TLSSelfSigned,
+		tlsRedirect:   cfg.TLSRedirect,
+		authUser:      cfg.AuthUser,
+		authPass:      cfg.AuthPass,
+		corsOrigins:   cfg.CORSOrigins,
+		healthAddr:    cfg.HealthzAddr,
+		started:       time.Now(),
+		sseHeartbeat:  cfg.SSEHeartbeat,
+		collector:     parser.Collector{Netns: cfg.Netns},
+	}
+	s.pollInterval.Store(int64(cfg.Interval))
+	// Millisecond sample times keep the SSE event IDs, and so the replay
+	// after a reconnect, of polls within the same second apart.
+	precision := cfg.HistoryPrecision
+	if precision == 0 {
+		precision = time.Millisecond
+	}
+	s.history.SetPrecision(precision)
+	if cfg.MinuteHistoryCap != 0 {
+		s.history.SetMinuteCapacity(cfg.MinuteHistoryCap)
+	}
+	for iface, n := range cfg.HistoryCapacities {
+		s.history.SetCapacity(iface, n)
+	}
+	s.historyCaps = cfg.HistoryCapacities
+	if cfg.EMAAlpha != 0 {
+		s.history.SetEMAAlpha(cfg.EMAAlpha)
+	}
+	s.history.SetWrapThreshold(cfg.WrapThreshold)
+	s.history.SetAppearHook(func(iface string) { s.sendTopology("appear", iface) })
+	s.history.SetDisappearHook(func(iface string) { s.sendTopology("disappear", iface) })
+	if s.sseHeartbeat <= 0 {
+		s.sseHeartbeat = DefaultSSEHeartbeat
+	}
+	s.webhook = newWebhook(cfg.WebhookURL, cfg.WebhookTimeout)
+	s.statsdAddr = cfg.StatsdAddr
+	s.influx, s.influxErr = newInfluxWriter(cfg)
+	s.pushGateway, s.pushGatewayErr = newPushGateway(cfg)
+	s.deliveries = append([]alert.AlertDelivery{WebhookDelivery{s.webhook}}, cfg.AlertDeliveries...)
+	if len(cfg.AlertRules) > 0 {
+		s.alerts, s.alertsErr = alert.NewAlertManager(cfg.AlertRules, s.deliverAlert)
+		if s.alerts != nil && cfg.AlertLogSize > 0 {
+			s.alerts.SetLogSize(cfg.AlertLogSize)
+		}
+	}
+	if len(cfg.Interfaces) > 0 {
+		s.ifaces = make(map[string]bool, len(cfg.Interfaces))
+		for _, name := range cfg.Interfaces {
+			s.ifaces[name] = true
+		}
+	}
+	if len(cfg.ExcludeInterfaces) > 0 {
+		s.exclude = make(map[string]bool, len(cfg.ExcludeInterfaces))
+		for _, name := range cfg.ExcludeInterfaces {
+			s.exclude[name] = true
+		}
 	}
 
 	app := fiber.New(fiber.Config{
 		ServerHeader: "cake-stats",
+		ErrorHandler: handleError,
+	})
+	app.Hooks().OnListen(func(fiber.ListenData) error {
+		s.markListening()
+		return nil
 	})
+	// The access log sits outside recover so a recovered panic is logged
+	// with its 500.
+	app.Use(requestid.New())
+	app.Use(accessLog())
 	app.Use(recovermiddleware.New())
+	// CORS first: preflight requests carry no credentials.
+	if len(s.corsOrigins) > 0 {
+		app.Use(corsMiddleware(s.corsOrigins))
+	}
+	if s.authUser != "" && s.authPass != "" {
+		app.Use(s.basicAuth())
+	}
 
 	app.Get("/", s.handleIndex)
+	app.Get("/healthz", s.handleHealthz)
+	app.Get("/api/version", s.handleAPIVersion)
+	app.Get("/api/openapi.json", s.handleOpenAPI)
 	app.Get("/api/stats", s.handleAPIStats)
+	app.Post("/api/poll", s.handleAPIPoll)
+	app.Get("/metrics", s.handleMetrics)
 	app.Get("/api/history", s.handleAPIHistory)
+	app.Get("/api/history/openmetrics", s.handleAPIHistoryOpenMetrics)
+	app.Get("/api/history.csv", s.handleAPIHistoryCSV)
+	app.Get("/api/history.influx", s.handleAPIHistoryInflux)
+	app.Get("/api/history/tiers", s.handleAPIHistoryTiers)
+	app.Get("/api/history/percentiles", s.handleAPIHistoryPercentiles)
+	// After the fixed /api/history/... routes so they are not taken for
+	// interface names.
+	app.Get("/api/history/:iface", s.handleAPIHistoryIface)
+	app.Get("/api/history/:iface/tiers", s.handleAPIHistoryIfaceTiers)
+	app.Get("/api/stats/percentile", s.handleAPIPercentile)
+	app.Get("/api/compare", s.handleAPICompare)
+	app.Get("/api/peaks", s.handleAPIPeaks)
+	app.Post("/api/peaks/reset", s.handleAPIPeaksReset)
+	app.Get("/api/alerts", s.handleAPIAlerts)
+	app.Delete("/api/interfaces/:iface", s.handleAPIDeleteInterface)
 	app.Get("/events", s.handleSSE)
+	if !cfg.NoWebSocket {
+		app.Get("/ws", s.handleWebSocket)
+		app.Get("/api/live", s.handleWebSocket)
+	}
 
 	s.app = app
+	if s.healthAddr != "" {
+		s.healthApp = fiber.New(fiber.Config{ServerHeader: "cake-stats", ErrorHandler: handleError})
+		s.healthApp.Get("/healthz", s.handleHealthz)
+	}
 	return s
 }
 
 func (s *Server) Run(ctx context.Context, addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("listen address: %w", err)
+	}
+	tlsCfg, err := s.tlsConfig([]string{host})
+	if err != nil {
+		return err
+	}
+	if (s.authUser == "") != (s.authPass == "") {
+		return errors.New("auth: user and password must be given together")
+	}
+	for _, o := range s.corsOrigins {
+		if err := checkOrigin(o); err != nil {
+			return err
+		}
+	}
+	if s.alertsErr != nil {
+		return fmt.Errorf("alerts: %w", s.alertsErr)
+	}
+	if s.influxErr != nil {
+		return s.influxErr
+	}
+	if s.pushGatewayErr != nil {
+		return s.pushGatewayErr
+	}
+	if s.dbPath != "" {
+		if err := s.history.OpenDB(s.dbPath); err != nil {
+			return fmt.Errorf("open history db: %w", err)
+		}
+		defer s.history.CloseDB()
+		log.Logger.Info().Str("path", s.dbPath).Msg("history persisted to disk")
+	}
+	if s.statsdAddr != "" {
+		c, err := dialStatsd(s.statsdAddr)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		s.statsd = c
+		log.Logger.Info().Str("addr", s.statsdAddr).Msg("pushing gauges to statsd")
+	}
+	go s.webhook.run(ctx)
+	if s.influx != nil {
+		go s.influx.run(ctx)
+	}
+	if s.alerts != nil {
+		go s.alerts.Run(ctx)
+	}
 	s.forcePoll()
 	go s.runPoller(ctx)
+	if s.pushGateway != nil {
+		go s.pushGateway.run(ctx, s)
+	}
+	go s.runWatchdog(ctx)
+	// stopped is closed once shutdown has returned: Listen comes back as
+	// soon as the listener closes, and Run must not run its deferred
+	// cleanup while SSE writers are still live.
+	stopped := make(chan struct{})
 	go func() {
 		<-ctx.Done()
-		_ = s.app.Shutdown()
+		sdNotify(daemon.SdNotifyStopping)
+		s.shutdown()
+		close(stopped)
 	}()
-	log.Logger.Info().Str("addr", addr).Dur("interval", s.pollInterval).Msg("listening")
-	return s.app.Listen(addr)
+	if s.healthApp != nil {
+		go func() {
+			log.Logger.Info().Str("addr", s.healthAddr).Msg("serving /healthz")
+			if err := s.healthApp.Listen(s.healthAddr, fiber.ListenConfig{DisableStartupMessage: true}); err != nil {
+				log.Logger.Error().Err(err).Str("addr", s.healthAddr).Msg("healthz listener failed")
+			}
+		}()
+	}
+	if tlsCfg != nil && s.tlsRedirect != "" {
+		go s.runRedirect(ctx, port)
+	}
+	log.Logger.Info().Str("addr", addr).Bool("tls", tlsCfg != nil).Dur("interval", s.interval()).Msg("listening")
+	err = s.app.Listen(addr, fiber.ListenConfig{TLSConfig: tlsCfg})
+	if ctx.Err() == nil {
+		// The listener failed on its own; stop whatever it started.
+		s.shutdown()
+	} else {
+		<-stopped
+	}
+	return err
 }
 
-func (s *Server) forcePoll() {
+// shutdown ends the SSE streams, stops the listeners and waits for every SSE
+// stream writer to finish, deferred cleanup included, so none outlives Run.
+func (s *Server) shutdown() {
+	s.streamMu.Lock()
+	s.stopOnce.Do(func() { close(s.done) })
+	s.streamMu.Unlock()
+	_ = s.app.Shutdown()
+	if s.healthApp != nil {
+		_ = s.healthApp.Shutdown()
+	}
+	// The listener is closed, so no handler can start another writer.
+	s.sseWG.Wait()
+}
+
+// forcePoll collects one round of stats, records it and broadcasts it.  It
+// returns the collection error, which has already been logged.
+func (s *Server) forcePoll() (err error) {
+	// One poll at a time: the ticker and POST /api/poll must not run tc
+	// concurrently.
+	s.pollMu.Lock()
+	defer s.pollMu.Unlock()
 	defer func() {
 		if r := recover(); r != nil {
 			log.Logger.Error().Interface("panic", r).Msg("poller recovered")
+			err = fmt.Errorf("poll panicked: %v", r)
 		}
 	}()
-	stats, err := parser.CollectStats(context.Background())
+	stats, err := s.collectRound(context.Background())
+	s.markPollFinished()
 	if err != nil {
-		log.Logger.Warn().Err(err).Msg("tc poll failed")
-		return
+		s.logPollError(err)
+		return err
+	}
+	s.pollRecovered()
+	s.markPolled()
+	if s.exclude != nil {
+		all := stats
+		stats = slices.DeleteFunc(slices.Clone(stats), func(cs types.CakeStats) bool { return s.exclude[cs.Interface] })
+		s.recycle(all)
 	}
-	s.history.Record(stats, s.pollInterval)
+	s.history.Record(stats, s.interval())
+	id := s.history.LastSampleTime().UnixMilli()
+	for i := range stats {
+		// A no-op below debug level: the event is nil and nothing is encoded.
+		log.Logger.Debug().Str("iface", stats[i].Interface).Interface("stats", &stats[i]).Msg("polled qdisc")
+	}
+	now := time.Now()
 	s.statsMu.Lock()
+	trackConfigChanges(s.stats, stats, now)
+	prev := s.stats
 	s.stats = stats
+	s.statsUpdated = now
+	s.statsGen++
+	gen := s.statsGen
+	s.statsID = id
+	if len(stats) > 0 {
+		s.lastHistoryModified = now
+		s.historyGen = gen
+	}
+	s.statsMu.Unlock()
+	s.broadcast(prev, stats, id, gen)
+	if s.statsd != nil {
+		s.statsd.logResult(s.statsd.send(stats))
+	}
+	if s.influx != nil {
+		s.influx.enqueue(stats, now)
+	}
+	// Readers use s.stats only under statsMu, so nothing references prev
+	// any more.
+	s.recycle(prev)
+	if s.alerts != nil {
+		// The alert goroutine outlives this poll, so it gets its own copy.
+		s.alerts.Observe(slices.Clone(stats))
+	}
+	return nil
+}
+
+// pollErrorKind buckets a poll error for log suppression.
+func pollErrorKind(err error) string {
+	switch {
+	case errors.Is(err, parser.ErrPermissionDenied):
+		return "permission"
+	case errors.Is(err, parser.ErrTCNotFound), errors.Is(err, exec.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "other"
+	}
+}
+
+// logPollError logs a failed poll at warn level the first time its kind is
+// seen since the last successful poll, and at debug level after that, so a
+// persistent failure at a 100 ms interval does not flood the log.
+//
+// A missing tc binary will not fix itself, so it is logged at error level
+// exactly once for the life of the server.
+func (s *Server) logPollError(err error) {
+	if errors.Is(err, parser.ErrTCNotFound) {
+		s.tcMissingOnce.Do(func() {
+			log.Logger.Error().Err(err).Msg("tc not found; install iproute2 (tc) or run with netlink access")
+		})
+		return
+	}
+	kind := pollErrorKind(err)
+	s.pollErrMu.Lock()
+	seen := s.pollErrKinds[kind]
+	if !seen {
+		if s.pollErrKinds == nil {
+			s.pollErrKinds = make(map[string]bool)
+		}
+		s.pollErrKinds[kind] = true
+	}
+	s.pollErrMu.Unlock()
+
+	// The parser already logged a permission failure with its hint.
+	ev := log.Logger.Debug()
+	if !seen {
+		ev = log.Logger.Warn()
+	}
+	ev.Err(err).Str("kind", kind).Msg("tc poll failed")
+}
+
+// pollRecovered clears the suppression state after a successful poll and
+// notes the recovery if anything had been logged.
+func (s *Server) pollRecovered() {
+	s.pollErrMu.Lock()
+	failed := len(s.pollErrKinds) > 0
+	s.pollErrKinds = nil
+	s.pollErrMu.Unlock()
+	if failed {
+		log.Logger.Info().Msg("tc poll recovered")
+	}
+}
+
+// collectRound fetches one poll's worth of stats.  Without an interface list
+// it is a single system-wide collect.  With one, a full scan first finds
+// which listed interfaces carry CAKE, then only those are collected: with one
+// netlink dump, or one tc run per device.  Any device error, or a round in which every device comes
+// back empty, drops back to a full scan on the next poll so renamed or
+// re-created interfaces are picked up again.
+func (s *Server) collectRound(ctx context.Context) ([]types.CakeStats, error) {
+	if s.ifaces == nil {
+		return s.collectAll(ctx)
+	}
+	s.statsMu.RLock()
+	devices := s.devices
+	s.statsMu.RUnlock()
+
+	if len(devices) == 0 {
+		all, err := s.collectAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stats := filterInterfaces(all, s.ifaces)
+		s.recycle(all)
+		for _, cs := range stats {
+			if !slices.Contains(devices, cs.Interface) {
+				devices = append(devices, cs.Interface)
+			}
+		}
+		if len(devices) > 0 {
+			log.Logger.Info().Strs("interfaces", devices).Msg("polling CAKE interfaces per device")
+		}
+		s.setDevices(devices)
+		return stats, nil
+	}
+
+	stats, err := s.collectOnly(ctx, devices)
+	if err != nil {
+		s.setDevices(nil)
+		return nil, err
+	}
+	if len(stats) == 0 {
+		s.setDevices(nil)
+	}
+	return stats, nil
+}
+
+func (s *Server) collectAll(ctx context.Context) ([]types.CakeStats, error) {
+	if s.collect != nil {
+		return s.collect(ctx)
+	}
+	return s.collector.Collect(ctx)
+}
+
+func (s *Server) collectOnly(ctx context.Context, devices []string) ([]types.CakeStats, error) {
+	if s.collectDevices != nil {
+		return s.collectDevices(ctx, devices)
+	}
+	return s.collector.CollectDevices(ctx, devices)
+}
+
+// recycle hands a slice from the collector back to the parser's pool.  The
+// caller must hold the only reference to it.
+func (s *Server) recycle(stats []types.CakeStats) {
+	if s.collect == nil && s.collectDevices == nil {
+		parser.ReleaseStats(stats)
+	}
+}
+
+func (s *Server) setDevices(devices []string) {
+	s.statsMu.Lock()
+	s.devices = devices
 	s.statsMu.Unlock()
-	s.broadcast(stats)
+}
+
+// filterInterfaces returns the entries whose interface is in allow, leaving
+// stats untouched.
+func filterInterfaces(stats []types.CakeStats, allow map[string]bool) []types.CakeStats {
+	var out []types.CakeStats
+	for _, cs := range stats {
+		if allow[cs.Interface] {
+			out = append(out, cs)
+		}
+	}
+	return out
+}
+
+// trackConfigChanges fills LastChanged on each entry of curr by comparing its
+// CAKE configuration against the same interface in prev.
+func trackConfigChanges(prev, curr []types.CakeStats, now time.Time) {
+	byIface := make(map[string]*types.CakeStats, len(prev))
+	for i := range prev {
+		byIface[prev[i].Interface] = &prev[i]
+	}
+	for i := range curr {
+		cs := &curr[i]
+		old, ok := byIface[cs.Interface]
+		switch {
+		case !ok:
+			cs.LastChanged = cs.UpdatedAt
+		case configDiffers(old, cs):
+			cs.LastChanged = now
+		default:
+			cs.LastChanged = old.LastChanged
+		}
+	}
+}
+
+// configDiffers reports whether any user-configurable CAKE parameter differs
+// between a and b.  Counters and computed fields are ignored.
+func configDiffers(a, b *types.CakeStats) bool {
+	return a.Bandwidth != b.Bandwidth ||
+		a.DiffservMode != b.DiffservMode ||
+		a.RTT != b.RTT ||
+		a.Overhead != b.Overhead ||
+		a.DualMode != b.DualMode ||
+		a.FwmarkMask != b.FwmarkMask ||
+		a.NATEnabled != b.NATEnabled ||
+		a.ATMMode != b.ATMMode ||
+		a.MPU != b.MPU ||
+		a.WashEnabled != b.WashEnabled ||
+		a.SplitGSO != b.SplitGSO ||
+		a.NoAckFilter != b.NoAckFilter ||
+		a.MemLimit != b.MemLimit ||
+		a.Direction != b.Direction
 }
 
 func (s *Server) runPoller(ctx context.Context) {
-	ticker := time.NewTicker(s.pollInterval)
+	cur := s.interval()
+	ticker := time.NewTicker(cur)
 	defer ticker.Stop()
 	for {
 		select {
@@ -96,37 +767,180 @@ func (s *Server) runPoller(ctx context.Context) {
 			return
 		case <-ticker.C:
 			s.forcePoll()
+			// Pick up an interval changed by Reload.
+			if d := s.interval(); d != cur {
+				cur = d
+				ticker.Reset(cur)
+			}
 		}
 	}
 }
 
-func (s *Server) broadcast(stats []types.CakeStats) {
+func (s *Server) interval() time.Duration {
+	return time.Duration(s.pollInterval.Load())
+}
+
+// broadcast sends snapshot generation gen to every client, with its delta
+// from prev, the generation before it.
+func (s *Server) broadcast(prev, stats []types.CakeStats, id int64, gen uint64) {
 	resp := types.StatsResponse{Interfaces: stats, UpdatedAt: time.Now().UTC().Format(time.RFC3339)}
-	payload, _ := easyjson.Marshal(&resp)
-	event := buildSSEEvent(payload)
+	event, payload := buildStatsEvent(&resp, id, int(s.eventSize.Load()))
+	s.eventSize.Store(int64(len(event)))
+	f := frame{payload: payload, event: event, id: id, seq: gen}
+	if delta, ok := diffStats(prev, stats, resp.UpdatedAt); ok {
+		f.delta = buildSSEEvent(sseEventDelta, id, delta)
+	}
+	s.webhook.enqueue(payload)
+	s.send(f)
+}
+
+// deliverAlert logs a (or its recovery), sends it to the streaming clients
+// and hands it to every AlertDelivery in turn, waiting for each to finish.
+// A failed delivery is logged and does not stop the others.
+func (s *Server) deliverAlert(ctx context.Context, a types.CakeAlert) {
+	ev, msg := log.Logger.Warn(), "alert"
+	if a.ResolvedAt != nil {
+		ev, msg = log.Logger.Info(), "alert resolved"
+	}
+	ev.Str("iface", a.Interface).
+		Str("metric", a.Metric).
+		Float64("value", a.Value).
+		Float64("threshold", a.Threshold).
+		Msg(msg)
+	payload, _ := easyjson.Marshal(&a)
+	s.send(frame{event: buildSSEEvent(sseEventAlert, 0, payload)})
+	for _, d := range s.deliveries {
+		if err := d.Deliver(ctx, a); err != nil && ctx.Err() == nil {
+			log.Logger.Warn().Err(err).Str("iface", a.Interface).Str("metric", a.Metric).Msg("alert delivery failed")
+		}
+	}
+}
 
+// sendTopology logs an interface appearing or disappearing and tells the SSE
+// clients.
+func (s *Server) sendTopology(typ, iface string) {
+	msg := "interface appeared"
+	if typ == "disappear" {
+		msg = "interface disappeared"
+	}
+	log.Logger.Info().Str("iface", iface).Msg(msg)
+	payload, _ := easyjson.Marshal(&types.TopologyEvent{Type: typ, Iface: iface})
+	s.send(frame{event: buildSSEEvent(sseEventTopology, 0, payload)})
+}
+
+// send queues f to every streaming client, dropping it for clients whose
+// buffer is full.
+func (s *Server) send(f frame) {
 	s.ssesMu.Lock()
 	defer s.ssesMu.Unlock()
 	for ch := range s.clients {
 		select {
-		case ch <- event:
+		case ch <- f:
 		default:
 		}
 	}
 }
 
-var sseBufPool = sync.Pool{New: func() any { b := make([]byte, 0, 1024); return &b }}
+// subscribe registers a new streaming client (SSE or WebSocket) to receive
+// every broadcast frame.  The caller must unsubscribe when it disconnects.
+func (s *Server) subscribe() chan frame {
+	ch := make(chan frame, sseBufSize)
+	s.ssesMu.Lock()
+	s.clients[ch] = struct{}{}
+	s.ssesMu.Unlock()
+	return ch
+}
 
-func buildSSEEvent(payload []byte) []byte {
-	buf := sseBufPool.Get().(*[]byte)
-	*buf = (*buf)[:0]
-	*buf = append(*buf, "retry: 2000\ndata: "...)
-	*buf = append(*buf, payload...)
-	*buf = append(*buf, "\n\n"...)
-	out := make([]byte, len(*buf))
-	copy(out, *buf)
-	sseBufPool.Put(buf)
-	return out
+func (s *Server) unsubscribe(ch chan frame) {
+	s.ssesMu.Lock()
+	delete(s.clients, ch)
+	s.ssesMu.Unlock()
+}
+
+// buildSSEEvent frames payload as one SSE event of type eventType.  A
+// non-zero id is sent as the event ID, which the browser echoes back in
+// Last-Event-ID when it reconnects.
+func buildSSEEvent(eventType string, id int64, payload []byte) []byte {
+	buf := appendSSEHeader(make([]byte, 0, len(payload)+64), eventType, id)
+	buf = append(buf, payload...)
+	return append(buf, "\n\n"...)
+}
+
+// buildStatsEvent encodes resp straight after the SSE header of a stats
+// event, so the poll's JSON is written once into the buffer that is sent.
+// payload is the JSON within event.  sizeHint, the length of the previous
+// event, sizes the buffer: when it fits, the event is one allocation instead
+// of easyjson's growing pooled chunks and a copy into the frame.
+func buildStatsEvent(resp *types.StatsResponse, id int64, sizeHint int) (event, payload []byte) {
+	buf := appendSSEHeader(make([]byte, 0, max(sizeHint+sizeHint/4, 4096)), sseEventStats, id)
+	w := jwriter.Writer{Buffer: buffer.Buffer{Buf: buf}}
+	start := w.Size()
+	resp.MarshalEasyJSON(&w)
+	end := w.Size()
+	w.RawString("\n\n")
+	event, _ = w.BuildBytes()
+	return event, event[start:end:end]
+}
+
+// appendSSEHeader appends the fields of an SSE event up to its "data: ".
+func appendSSEHeader(buf []byte, eventType string, id int64) []byte {
+	buf = append(buf, "retry: 2000\n"...)
+	if id != 0 {
+		buf = append(buf, "id: "...)
+		buf = strconv.AppendInt(buf, id, 10)
+		buf = append(buf, '\n')
+	}
+	buf = append(buf, "event: "...)
+	buf = append(buf, eventType...)
+	return append(buf, "\ndata: "...)
+}
+
+// bodyETag is a strong ETag: the FNV-1a hash of body and the snapshot
+// generation, quoted.
+func bodyETag(body []byte, gen uint64) string {
+	h := fnv.New64a()
+	h.Write(body)
+	h.Write(binary.BigEndian.AppendUint64(nil, gen))
+	return `"` + strconv.FormatUint(h.Sum64(), 16) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag,
+// using the weak comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// handleError renders every error returned from a handler (including Fiber's
+// own 404/405 for unmatched routes) as a types.ErrorResponse JSON body.
+// Handlers should return fiber.NewError(code, msg) rather than writing plain
+// text so that clients always get the same machine-readable shape. Any other
+// error may carry paths or command output, so the client only gets the status
+// text and the detail is logged under the request ID instead.
+func handleError(c fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+	rid := requestid.FromContext(c)
+	msg := err.Error()
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		code = fe.Code
+	} else {
+		msg = http.StatusText(code)
+		log.Logger.Error().Err(err).Str("request_id", rid).Str("path", c.Path()).Msg("request failed")
+	}
+	resp := types.ErrorResponse{
+		Error:     msg,
+		Code:      code,
+		RequestID: rid,
+	}
+	b, _ := easyjson.Marshal(&resp)
+	c.Set("Content-Type", "application/json; charset=utf-8")
+	return c.Status(code).Send(b)
 }
 
 func (s *Server) handleIndex(c fiber.Ctx) error {
@@ -135,62 +949,556 @@ func (s *Server) handleIndex(c fiber.Ctx) error {
 	return c.SendString(indexHTML)
 }
 
+// handleAPIStats returns the latest snapshot with an ETag derived from the
+// body; a matching If-None-Match gets 304 until the next poll.
 func (s *Server) handleAPIStats(c fiber.Ctx) error {
+	// The snapshot is encoded under the lock: the next poll recycles it.
 	s.statsMu.RLock()
 	snapshot := s.stats
+	updated := s.statsUpdated
+	gen := s.statsGen
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	// ?include_tiers=false drops the per-tin detail for clients that only need
+	// the interface totals; MaxAvDelayMs/MaxPkDelayMs still carry latency.
+	// Copy first: the snapshot slice is shared with other readers.
+	if c.Query("include_tiers") == "false" {
+		trimmed := make([]types.CakeStats, len(snapshot))
+		copy(trimmed, snapshot)
+		for i := range trimmed {
+			trimmed[i].Tiers = nil
+		}
+		snapshot = trimmed
+	}
+	resp := types.StatsResponse{Interfaces: snapshot, UpdatedAt: updated.UTC().Format(time.RFC3339)}
+	b, _ := easyjson.Marshal(&resp)
 	s.statsMu.RUnlock()
-	resp := types.StatsResponse{Interfaces: snapshot, UpdatedAt: time.Now().UTC().Format(time.RFC3339)}
+	etag := bodyETag(b, gen)
+	c.Set(fiber.HeaderETag, etag)
+	if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	c.Set("Content-Type", "application/json; charset=utf-8")
+	return c.Send(b)
+}
+
+// ErrNoInterfaces is returned by PollOnce when the poll found no CAKE qdisc.
+var ErrNoInterfaces = errors.New("no CAKE interfaces found")
+
+// PollOnce runs a single poll without starting any listener and returns its
+// result in the /api/stats JSON format.  It backs the -once command-line
+// mode.
+func (s *Server) PollOnce() ([]byte, error) {
+	if err := s.forcePoll(); err != nil {
+		return nil, err
+	}
+	s.statsMu.RLock()
+	defer s.statsMu.RUnlock()
+	resp := types.StatsResponse{Interfaces: s.stats, UpdatedAt: s.statsUpdated.UTC().Format(time.RFC3339)}
+	if len(resp.Interfaces) == 0 {
+		return nil, ErrNoInterfaces
+	}
+	return easyjson.Marshal(&resp)
+}
+
+// handleAPIPoll runs a poll immediately and answers like /api/stats with its
+// result.  Concurrent calls wait for each other (see forcePoll).
+func (s *Server) handleAPIPoll(c fiber.Ctx) error {
+	if err := s.forcePoll(); err != nil {
+		return fiber.NewError(fiber.StatusBadGateway, "poll failed: "+err.Error())
+	}
+	return s.handleAPIStats(c)
+}
+
+// handleMetrics exposes the latest poll in the Prometheus text format.
+func (s *Server) handleMetrics(c fiber.Ctx) error {
+	var buf bytes.Buffer
+	s.statsMu.RLock()
+	err := writePrometheus(&buf, s.stats)
+	s.statsMu.RUnlock()
+	if err != nil {
+		return err
+	}
+	c.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	return sendCompressed(c, buf.Bytes())
+}
+
+// handleHealthz reports readiness: 503 until the first successful poll,
+// then 200 with the process uptime.
+func (s *Server) handleHealthz(c fiber.Ctx) error {
+	s.statsMu.RLock()
+	ready := s.statsGen > 0
+	s.statsMu.RUnlock()
+	resp := types.HealthResponse{Status: "starting"}
+	code := fiber.StatusServiceUnavailable
+	if ready {
+		uptime := int64(time.Since(s.started).Seconds())
+		resp = types.HealthResponse{Status: "ok", UptimeS: &uptime}
+		code = fiber.StatusOK
+	}
+	c.Set("Content-Type", "application/json; charset=utf-8")
+	c.Set("Cache-Control", "no-store")
+	b, _ := easyjson.Marshal(&resp)
+	return c.Status(code).Send(b)
+}
+
+func (s *Server) handleOpenAPI(c fiber.Ctx) error {
+	c.Set("Content-Type", "application/json; charset=utf-8")
+	return sendCompressed(c, openAPISpec)
+}
+
+// handleAPIVersion returns the build metadata from pkg/buildinfo.
+func (s *Server) handleAPIVersion(c fiber.Ctx) error {
+	resp := types.VersionResponse{
+		Version:   buildinfo.Version,
+		GoVersion: buildinfo.GoVersion(),
+		BuiltAt:   buildinfo.BuiltAt,
+		Commit:    buildinfo.Commit,
+	}
 	c.Set("Content-Type", "application/json; charset=utf-8")
 	b, _ := easyjson.Marshal(&resp)
 	return c.Send(b)
 }
 
+// handleAPIHistory returns the history of every interface, or of one with
+// ?iface=.  Conditional requests are answered from the history generation:
+// the ETag covers it, the query and the content-coding, so If-None-Match
+// sees every new sample.
+// If-Modified-Since is only consulted without If-None-Match; HTTP dates have
+// one-second resolution, so a client revalidating with it alone can get 304
+// for samples recorded later within the second of its Last-Modified.
 func (s *Server) handleAPIHistory(c fiber.Ctx) error {
-	snap := s.history.Snapshot()
+	s.statsMu.RLock()
+	// Truncate so a client echoing our own Last-Modified value gets a 304.
+	modified := s.lastHistoryModified.UTC().Truncate(time.Second)
+	gen := s.historyGen
+	s.statsMu.RUnlock()
+	if !modified.IsZero() {
+		// Each content-coding is its own representation with its own strong
+		// ETag, and a 304 must carry the same Vary as the 200.
+		c.Vary(fiber.HeaderAcceptEncoding)
+		etag := bodyETag([]byte(string(c.Request().URI().QueryString())+"\x00"+responseEncoding(c)), gen)
+		c.Set(fiber.HeaderETag, etag)
+		c.Set(fiber.HeaderLastModified, modified.Format(http.TimeFormat))
+		if inm := c.Get(fiber.HeaderIfNoneMatch); inm != "" {
+			if etagMatches(inm, etag) {
+				return c.SendStatus(fiber.StatusNotModified)
+			}
+		} else if ims := c.Get(fiber.HeaderIfModifiedSince); ims != "" {
+			if t, err := http.ParseTime(ims); err == nil && !modified.After(t) {
+				return c.SendStatus(fiber.StatusNotModified)
+			}
+		}
+	}
+	if iface := c.Query("iface"); iface != "" {
+		return s.sendIfaceHistory(c, iface)
+	}
+	var snap types.HistoryResponse
+	switch c.Query("resolution") {
+	case "":
+		snap = s.history.Snapshot().Samples
+	case "minute":
+		snap = s.history.Snapshot().Minute
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "resolution must be minute or omitted")
+	}
+	c.Set("Content-Type", "application/json; charset=utf-8")
+	b, _ := json.Marshal(snap)
+	return sendCompressed(c, b)
+}
+
+// handleAPIHistoryIface returns one interface's history as a bare sample
+// array.  Names containing '/' (namespaced interfaces) can use
+// /api/history?iface= instead.
+func (s *Server) handleAPIHistoryIface(c fiber.Ctx) error {
+	return s.sendIfaceHistory(c, c.Params("iface"))
+}
+
+func (s *Server) sendIfaceHistory(c fiber.Ctx, iface string) error {
+	samples, ok := s.history.Samples(iface)
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "unknown interface "+iface)
+	}
+	if samples == nil {
+		samples = []types.HistorySample{}
+	}
+	c.Set("Content-Type", "application/json; charset=utf-8")
+	b, _ := json.Marshal(samples)
+	return sendCompressed(c, b)
+}
+
+// handleAPIHistoryIfaceTiers returns one interface's per-tier history.
+func (s *Server) handleAPIHistoryIfaceTiers(c fiber.Ctx) error {
+	iface := c.Params("iface")
+	samples, ok := s.history.TierSamples(iface)
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "unknown interface "+iface)
+	}
+	if samples == nil {
+		samples = []types.TierHistorySample{}
+	}
+	c.Set("Content-Type", "application/json; charset=utf-8")
+	b, _ := json.Marshal(samples)
+	return sendCompressed(c, b)
+}
+
+// handleAPIHistoryTiers returns the per-tier history of every interface.
+func (s *Server) handleAPIHistoryTiers(c fiber.Ctx) error {
+	snap := s.history.TierSnapshot()
 	c.Set("Content-Type", "application/json; charset=utf-8")
 	b, _ := json.Marshal(snap)
+	return sendCompressed(c, b)
+}
+
+func (s *Server) handleAPIHistoryOpenMetrics(c fiber.Ctx) error {
+	c.Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	var buf bytes.Buffer
+	if err := writeOpenMetrics(&buf, s.history.Checkpoint()); err != nil {
+		return err
+	}
+	return sendCompressed(c, buf.Bytes())
+}
+
+// handleAPIHistoryCSV exports history as CSV: one interface with ?iface=, or
+// every interface with an extra "interface" column.
+func (s *Server) handleAPIHistoryCSV(c fiber.Ctx) error {
+	cp := s.history.Checkpoint()
+	iface := c.Query("iface")
+	ifaces := cp.Interfaces()
+	if iface != "" {
+		if cp.Samples(iface) == nil {
+			return fiber.NewError(fiber.StatusNotFound, "no history for interface "+iface)
+		}
+		ifaces = []string{iface}
+	}
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, cp, ifaces, iface == ""); err != nil {
+		return err
+	}
+	c.Set("Content-Type", "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+exportFilename(iface, "csv")+`"`)
+	return sendCompressed(c, buf.Bytes())
+}
+
+// handleAPIHistoryInflux exports history, including per-tier samples, as
+// InfluxDB line protocol for one interface (?iface=) or all of them.
+func (s *Server) handleAPIHistoryInflux(c fiber.Ctx) error {
+	cp := s.history.Checkpoint()
+	iface := c.Query("iface")
+	ifaces := cp.Interfaces()
+	if iface != "" {
+		if cp.Samples(iface) == nil {
+			return fiber.NewError(fiber.StatusNotFound, "no history for interface "+iface)
+		}
+		ifaces = []string{iface}
+	}
+	var buf bytes.Buffer
+	if err := writeInflux(&buf, cp, s.history.TierSnapshot(), ifaces); err != nil {
+		return err
+	}
+	c.Set("Content-Type", "text/plain; charset=utf-8")
+	return sendCompressed(c, buf.Bytes())
+}
+
+func (s *Server) handleAPIPercentile(c fiber.Ctx) error {
+	iface, field := c.Query("iface"), c.Query("field", "av")
+	if iface == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "missing iface parameter")
+	}
+	pct, err := strconv.ParseFloat(c.Query("pct", "95"), 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid pct parameter")
+	}
+	v, err := s.history.PercentileDelay(iface, field, pct)
+	if err != nil {
+		return historyError(err)
+	}
+	resp := types.PercentileResponse{Interface: iface, Field: field, Pct: pct, Value: v}
+	c.Set("Content-Type", "application/json; charset=utf-8")
+	b, _ := easyjson.Marshal(&resp)
+	return c.Send(b)
+}
+
+// percentileFields are the history fields summarised by
+// /api/history/percentiles.
+var percentileFields = []string{"av", "pk", "sp", "tx"}
+
+// handleAPIHistoryPercentiles returns the p-th percentile of each of
+// percentileFields as {"av_p95": …, "pk_p95": …, …}.
+func (s *Server) handleAPIHistoryPercentiles(c fiber.Ctx) error {
+	iface := c.Query("iface")
+	if iface == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "missing iface parameter")
+	}
+	p, err := strconv.ParseFloat(c.Query("p", "95"), 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid p parameter")
+	}
+	suffix := "_p" + strconv.FormatFloat(p, 'f', -1, 64)
+	resp := make(map[string]float64, len(percentileFields))
+	for _, field := range percentileFields {
+		v, err := s.history.Percentile(iface, field, p)
+		if err != nil {
+			return historyError(err)
+		}
+		resp[field+suffix] = v
+	}
+	c.Set("Content-Type", "application/json; charset=utf-8")
+	b, _ := json.Marshal(resp)
 	return c.Send(b)
 }
 
+// handleAPICompare diffs the history samples nearest to two Unix timestamps,
+// giving a quick before/after view around a configuration change.
+func (s *Server) handleAPICompare(c fiber.Ctx) error {
+	iface := c.Query("iface")
+	if iface == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "missing iface parameter")
+	}
+	t1, err1 := strconv.ParseInt(c.Query("t1"), 10, 64)
+	t2, err2 := strconv.ParseInt(c.Query("t2"), 10, 64)
+	if err1 != nil || err2 != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "t1 and t2 must be unix timestamps")
+	}
+	if t1 >= t2 {
+		return fiber.NewError(fiber.StatusBadRequest, "t1 must be before t2")
+	}
+	a, ok := s.history.NearestSample(iface, time.Unix(t1, 0))
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "no history for interface "+iface)
+	}
+	b, _ := s.history.NearestSample(iface, time.Unix(t2, 0))
+	resp := types.CompareResponse{
+		Interface: iface,
+		T1:        a,
+		T2:        b,
+		Delta: types.SampleDelta{
+			T:  b.T - a.T,
+			Tx: b.Tx - a.Tx,
+			Av: b.Av - a.Av,
+			Pk: b.Pk - a.Pk,
+			Dr: b.Dr - a.Dr,
+			Wm: int64(b.Wm) - int64(a.Wm),
+			Wi: b.Wi - a.Wi,
+			Sp: b.Sp - a.Sp,
+			Rq: b.Rq - a.Rq,
+		},
+	}
+	c.Set("Content-Type", "application/json; charset=utf-8")
+	out, _ := easyjson.Marshal(&resp)
+	return c.Send(out)
+}
+
+// handleAPIPeaks returns the peak values of every interface, keyed by
+// interface name; handleAPIPeaksReset clears one interface's.
+func (s *Server) handleAPIPeaks(c fiber.Ctx) error {
+	c.Set("Content-Type", "application/json; charset=utf-8")
+	b, _ := json.Marshal(s.history.PeakSnapshot())
+	return c.Send(b)
+}
+
+// handleAPIPeaksReset zeroes the peaks of ?iface=.
+func (s *Server) handleAPIPeaksReset(c fiber.Ctx) error {
+	iface := c.Query("iface")
+	if iface == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "missing iface parameter")
+	}
+	if !s.history.ResetPeaks(iface) {
+		return fiber.NewError(fiber.StatusNotFound, "unknown interface "+iface)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// handleAPIAlerts returns the alert log, newest first; an empty array when no
+// alert rules are configured.
+func (s *Server) handleAPIAlerts(c fiber.Ctx) error {
+	resp := types.AlertsResponse{}
+	if s.alerts != nil {
+		resp = s.alerts.Log()
+	}
+	c.Set("Content-Type", "application/json; charset=utf-8")
+	b, _ := easyjson.Marshal(resp)
+	return c.Send(b)
+}
+
+// handleAPIDeleteInterface forgets everything known about one interface:
+// its history ring buffer and its entry in the current snapshot.  If tc still
+// reports the qdisc it reappears, with a fresh baseline, on the next poll.
+func (s *Server) handleAPIDeleteInterface(c fiber.Ctx) error {
+	iface := c.Params("iface")
+	inHistory := s.history.Reset(iface)
+
+	inStats := false
+	s.statsMu.Lock()
+	// Build a new slice: the old one may still be referenced by SSE writers.
+	kept := make([]types.CakeStats, 0, len(s.stats))
+	for _, cs := range s.stats {
+		if cs.Interface == iface {
+			inStats = true
+			continue
+		}
+		kept = append(kept, cs)
+	}
+	// The old slice is left to the GC rather than recycled: the poll that
+	// published it may still be broadcasting it.
+	s.stats = kept
+	// A new generation, so SSE clients get the next poll in full rather
+	// than as a delta from a snapshot that still had the interface.
+	s.statsGen++
+	if inHistory {
+		// The history lost an interface: revalidating clients need a 200.
+		s.historyGen = s.statsGen
+		s.lastHistoryModified = time.Now()
+	}
+	s.statsMu.Unlock()
+
+	if !inHistory && !inStats {
+		return fiber.NewError(fiber.StatusNotFound, "unknown interface "+iface)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// historyError maps HistoryStore query errors onto HTTP status codes.
+func historyError(err error) error {
+	switch {
+	case errors.Is(err, history.ErrUnknownInterface):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, history.ErrInsufficientSamples):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, history.ErrUnknownField), errors.Is(err, history.ErrInvalidPercentile):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		return err
+	}
+}
+
+// replayEvents frames samples as sseEventHistory events, one per distinct
+// timestamp in ascending order, each carrying that timestamp in unix
+// milliseconds as its ID.  It also returns the newest ID replayed, 0 if none.
+func replayEvents(samples types.HistoryResponse) ([][]byte, int64) {
+	byT := make(map[int64]types.HistoryResponse)
+	for iface, ss := range samples {
+		for _, hs := range ss {
+			t := history.SampleTime(hs).UnixMilli()
+			if byT[t] == nil {
+				byT[t] = make(types.HistoryResponse)
+			}
+			byT[t][iface] = append(byT[t][iface], hs)
+		}
+	}
+	ts := make([]int64, 0, len(byT))
+	for t := range byT {
+		ts = append(ts, t)
+	}
+	slices.Sort(ts)
+	events := make([][]byte, 0, len(ts))
+	for _, t := range ts {
+		payload, err := json.Marshal(byT[t])
+		if err != nil {
+			continue
+		}
+		events = append(events, buildSSEEvent(sseEventHistory, t, payload))
+	}
+	if len(ts) == 0 {
+		return nil, 0
+	}
+	return events, ts[len(ts)-1]
+}
+
 func (s *Server) handleSSE(c fiber.Ctx) error {
 	c.Set("Content-Type", "text/event-stream")
 	c.Set("Cache-Control", "no-cache")
 	c.Set("Connection", "keep-alive")
 	c.Set("X-Accel-Buffering", "no")
 
-	ch := make(chan []byte, sseBufSize)
+	ch := s.subscribe()
+	remote := c.IP()
+	start := time.Now()
+	log.Logger.Info().Str("remote_ip", remote).Msg("sse client connected")
 
-	s.ssesMu.Lock()
-	s.clients[ch] = struct{}{}
-	s.ssesMu.Unlock()
-
-	// Capture initial snapshot before entering the stream writer.
+	// Encode the initial snapshot now, under the lock: the next poll
+	// recycles the slice.
+	var snapshot []byte
 	s.statsMu.RLock()
-	snapshot := s.stats
+	lastSeq := s.statsGen
+	if len(s.stats) > 0 {
+		resp := types.StatsResponse{Interfaces: s.stats, UpdatedAt: time.Now().UTC().Format(time.RFC3339)}
+		snapshot, _ = buildStatsEvent(&resp, s.statsID, int(s.eventSize.Load()))
+	}
 	s.statsMu.RUnlock()
 
+	// A reconnecting browser names the last event it saw; replay the history
+	// samples recorded since then instead of the snapshot.  The lastEventId
+	// query parameter serves clients that open a new EventSource, which
+	// cannot set the header.
+	var replay [][]byte
+	lastID := int64(0)
+	last := c.Get("Last-Event-ID")
+	if last == "" {
+		last = c.Query("lastEventId")
+	}
+	if id, err := strconv.ParseInt(last, 10, 64); err == nil && id > 0 {
+		replay, lastID = replayEvents(s.history.Since(id))
+	}
+	if len(replay) > 0 || snapshot == nil {
+		// The client has no snapshot to apply deltas to.
+		lastSeq = 0
+	}
+
+	// fasthttp starts the writer right away, so it always gets to Done.
+	s.sseWG.Add(1)
 	c.RequestCtx().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer s.sseWG.Done()
+		defer s.unsubscribe(ch)
 		defer func() {
-			s.ssesMu.Lock()
-			delete(s.clients, ch)
-			s.ssesMu.Unlock()
+			log.Logger.Info().
+				Str("remote_ip", remote).
+				Float64("duration_s", time.Since(start).Seconds()).
+				Msg("sse client disconnected")
 		}()
 
-		// Send the current snapshot immediately so the page isn't blank.
-		if len(snapshot) > 0 {
-			resp := types.StatsResponse{
-				Interfaces: snapshot,
-				UpdatedAt:  time.Now().UTC().Format(time.RFC3339),
+		for _, event := range replay {
+			if _, err := w.Write(event); err != nil {
+				return
 			}
-			if payload, err := easyjson.Marshal(&resp); err == nil {
-				if _, err = w.Write(buildSSEEvent(payload)); err != nil {
-					return
-				}
-				_ = w.Flush()
+		}
+		if len(replay) > 0 {
+			_ = w.Flush()
+		} else if snapshot != nil {
+			// Send the current snapshot immediately so the page isn't blank.
+			if _, err := w.Write(snapshot); err != nil {
+				return
 			}
+			_ = w.Flush()
 		}
 
-		for event := range ch {
+		heartbeat := time.NewTicker(s.sseHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			var event []byte
+			select {
+			case f := <-ch:
+				if f.id != 0 && f.id <= lastID {
+					continue // already replayed
+				}
+				event = f.event
+				if f.seq != 0 {
+					if f.seq <= lastSeq {
+						continue // already in the snapshot
+					}
+					// A delta only applies to the generation right before
+					// it; after a dropped frame, send the whole snapshot.
+					if f.delta != nil && lastSeq != 0 && f.seq == lastSeq+1 {
+						event = f.delta
+					}
+					lastSeq = f.seq
+				}
+			case <-heartbeat.C:
+				event = sseHeartbeatEvent
+			case <-s.done:
+				return
+			}
 			if _, err := w.Write(event); err != nil {
 				return
 			}
@@ -0,0 +1,1277 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v3"
+	"github.com/mailru/easyjson"
+	"github.com/rs/zerolog"
+
+	"github.com/galpt/cake-stats/pkg/alert"
+	"github.com/galpt/cake-stats/pkg/buildinfo"
+	"github.com/galpt/cake-stats/pkg/log"
+	"github.com/galpt/cake-stats/pkg/parser"
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestNotFound_JSONError(t *testing.T) {
+	s := New("", Config{Interval: time.Second, HistoryCap: 10})
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/no-such-route", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status: want 404, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("content-type: want application/json, got %q", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var e types.ErrorResponse
+	if err := json.Unmarshal(body, &e); err != nil {
+		t.Fatalf("body is not JSON: %v (%s)", err, body)
+	}
+	if e.Code != http.StatusNotFound {
+		t.Errorf("code: want 404, got %d", e.Code)
+	}
+	if e.Error == "" {
+		t.Error("error message must not be empty")
+	}
+	if e.RequestID == "" || e.RequestID != resp.Header.Get("X-Request-ID") {
+		t.Errorf("request_id %q does not match header %q", e.RequestID, resp.Header.Get("X-Request-ID"))
+	}
+}
+
+func TestInternalError_HidesDetail(t *testing.T) {
+	var buf bytes.Buffer
+	old := log.Logger
+	log.Logger = zerolog.New(&buf).Level(zerolog.DebugLevel)
+	t.Cleanup(func() { log.Logger = old })
+
+	s := New("", Config{Interval: time.Second, HistoryCap: 10})
+	s.app.Get("/boom", func(fiber.Ctx) error {
+		return errors.New("open /etc/secret: permission denied")
+	})
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/boom", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("status: want 500, got %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var e types.ErrorResponse
+	if err := json.Unmarshal(body, &e); err != nil {
+		t.Fatalf("body is not JSON: %v (%s)", err, body)
+	}
+	if e.Error != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("error: want the status text, got %q", e.Error)
+	}
+	logged := buf.String()
+	if !strings.Contains(logged, "/etc/secret") || !strings.Contains(logged, e.RequestID) {
+		t.Errorf("log should carry the detail and request_id %q:\n%s", e.RequestID, logged)
+	}
+}
+
+// newTestServer returns a Server whose collector yields stats instead of
+// running tc.
+func newTestServer(stats []types.CakeStats) *Server {
+	s := New("", Config{Interval: time.Second, HistoryCap: 10})
+	s.collect = func(context.Context) ([]types.CakeStats, error) { return stats, nil }
+	return s
+}
+
+func TestHealthz(t *testing.T) {
+	s := New("", Config{Interval: time.Second, HistoryCap: 10, HealthzAddr: "127.0.0.1:0"})
+	fail := true
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		if fail {
+			return nil, errors.New("tc failed")
+		}
+		return []types.CakeStats{{Interface: "eth0"}}, nil
+	}
+
+	get := func(app *fiber.App) (int, map[string]any) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, body
+	}
+
+	if code, body := get(s.app); code != http.StatusServiceUnavailable || body["status"] != "starting" || body["uptime_s"] != nil {
+		t.Errorf("before first poll: got %d %v", code, body)
+	}
+	s.forcePoll()
+	if code, _ := get(s.app); code != http.StatusServiceUnavailable {
+		t.Errorf("after a failed poll: want 503, got %d", code)
+	}
+
+	fail = false
+	s.forcePoll()
+	for _, app := range []*fiber.App{s.app, s.healthApp} {
+		code, body := get(app)
+		if code != http.StatusOK || body["status"] != "ok" {
+			t.Errorf("after first successful poll: got %d %v", code, body)
+		}
+		if _, ok := body["uptime_s"].(float64); !ok {
+			t.Errorf("uptime_s missing: %v", body)
+		}
+	}
+}
+
+func TestAPIVersion(t *testing.T) {
+	s := newTestServer(nil)
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/version", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want 200, got %d", resp.StatusCode)
+	}
+	var got types.VersionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	// Unstamped test binaries report "dev".
+	if got.Version != buildinfo.Version || got.Version == "" {
+		t.Errorf("version: want %q, got %q", buildinfo.Version, got.Version)
+	}
+	if !strings.HasPrefix(got.GoVersion, "go") {
+		t.Errorf("go_version: got %q", got.GoVersion)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	s := newTestServer(nil)
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"openapi", "info", "paths", "components"} {
+		if _, ok := doc[key]; !ok {
+			t.Errorf("missing top-level key %q", key)
+		}
+	}
+	if v, _ := doc["openapi"].(string); !strings.HasPrefix(v, "3.0") {
+		t.Errorf("openapi: want 3.0.x, got %q", v)
+	}
+	paths, _ := doc["paths"].(map[string]interface{})
+	for _, p := range []string{"/api/stats", "/api/history", "/api/history/{iface}", "/healthz", "/api/version", "/events"} {
+		if _, ok := paths[p]; !ok {
+			t.Errorf("missing path %s", p)
+		}
+	}
+
+	// Every JSON field of the documented structs must be in the schema.
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, v := range []interface{}{types.CakeStats{}, types.CakeTier{}, types.HistorySample{}, types.StatsResponse{}} {
+		rt := reflect.TypeOf(v)
+		schema, _ := schemas[rt.Name()].(map[string]interface{})
+		props, _ := schema["properties"].(map[string]interface{})
+		for i := 0; i < rt.NumField(); i++ {
+			name, _, _ := strings.Cut(rt.Field(i).Tag.Get("json"), ",")
+			if _, ok := props[name]; !ok {
+				t.Errorf("%s: field %q missing from components/schemas", rt.Name(), name)
+			}
+		}
+	}
+}
+
+func TestAPIPoll(t *testing.T) {
+	s := New("", Config{Interval: time.Second, HistoryCap: 10})
+	var running, overlap atomic.Int32
+	fail := false
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		if running.Add(1) > 1 {
+			overlap.Store(1)
+		}
+		defer running.Add(-1)
+		time.Sleep(5 * time.Millisecond)
+		if fail {
+			return nil, errors.New("tc failed")
+		}
+		return []types.CakeStats{{Interface: "eth0"}}, nil
+	}
+
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodPost, "/api/poll", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want 200, got %d", resp.StatusCode)
+	}
+	var got types.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Interfaces) == 0 {
+		t.Fatal("want a non-empty interfaces array")
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() { _, _ = s.app.Test(httptest.NewRequest(http.MethodPost, "/api/poll", nil)) })
+	}
+	wg.Wait()
+	if overlap.Load() != 0 {
+		t.Error("concurrent POST /api/poll ran collections in parallel")
+	}
+
+	fail = true
+	resp, _ = s.app.Test(httptest.NewRequest(http.MethodPost, "/api/poll", nil))
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("failed poll: want 502, got %d", resp.StatusCode)
+	}
+}
+
+func TestStats_ETag(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{{Name: "Bulk"}}}})
+	s.forcePoll()
+
+	get := func(inm string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+		if inm != "" {
+			req.Header.Set("If-None-Match", inm)
+		}
+		resp, err := s.app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	resp := get("")
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("first request: status %d, ETag %q", resp.StatusCode, etag)
+	}
+	if resp := get(etag); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("matching If-None-Match: want 304, got %d", resp.StatusCode)
+	}
+	if resp := get(`"other", W/` + etag); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("weak match in a list: want 304, got %d", resp.StatusCode)
+	}
+	if resp, _ := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/stats?include_tiers=false", nil)); resp.Header.Get("ETag") == etag {
+		t.Error("include_tiers=false must have its own ETag")
+	}
+
+	// A new snapshot makes the old ETag stale, even within the same second.
+	s.forcePoll()
+	resp = get(etag)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("stale ETag: want 200, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("ETag") == etag {
+		t.Error("ETag did not change after a poll")
+	}
+}
+
+func TestHistory_IfModifiedSince(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	s.forcePoll()
+	s.forcePoll()
+
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lm := resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || lm == "" {
+		t.Fatalf("first request: status %d, Last-Modified %q", resp.StatusCode, lm)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/history", nil)
+	req.Header.Set("If-Modified-Since", lm)
+	resp, err = s.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("conditional request: want 304, got %d", resp.StatusCode)
+	}
+	if body, _ := io.ReadAll(resp.Body); len(body) != 0 {
+		t.Errorf("304 response must have no body, got %q", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/history", nil)
+	req.Header.Set("If-Modified-Since", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+	if resp, _ = s.app.Test(req); resp.StatusCode != http.StatusOK {
+		t.Errorf("stale If-Modified-Since: want 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestHistory_IfNoneMatch(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	s.forcePoll()
+
+	get := func(etag, lm string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/history", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lm != "" {
+			req.Header.Set("If-Modified-Since", lm)
+		}
+		resp, err := s.app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	resp := get("", "")
+	etag, lm := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if vary := resp.Header.Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("200 Vary: got %q", vary)
+	}
+	if resp = get(etag, lm); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("unchanged history: want 304, got %d", resp.StatusCode)
+	}
+	if vary := resp.Header.Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("304 Vary: got %q", vary)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/history", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", etag)
+	if resp, _ = s.app.Test(req); resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+		t.Errorf("gzip: want 200 with its own ETag, got %d %s", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	// The next poll most likely lands in the same second, leaving
+	// Last-Modified as it was; the ETag changes and takes precedence.
+	s.forcePoll()
+	if resp = get(etag, lm); resp.StatusCode != http.StatusOK {
+		t.Errorf("new sample: want 200, got %d", resp.StatusCode)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api/history?resolution=minute", nil)
+	req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+	if resp, _ = s.app.Test(req); resp.StatusCode != http.StatusOK {
+		t.Errorf("ETag of another query: want 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestLastChanged_AdvancesOnConfigChange(t *testing.T) {
+	first := time.Now().Add(-time.Minute).UTC()
+	stats := []types.CakeStats{{Interface: "eth0", DiffservMode: "diffserv4", UpdatedAt: first}}
+	s := New("", Config{Interval: time.Second, HistoryCap: 10})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		out := make([]types.CakeStats, len(stats))
+		copy(out, stats)
+		return out, nil
+	}
+
+	s.forcePoll()
+	if got := s.stats[0].LastChanged; !got.Equal(first) {
+		t.Fatalf("first poll: LastChanged want %v (UpdatedAt), got %v", first, got)
+	}
+	s.forcePoll()
+	if got := s.stats[0].LastChanged; !got.Equal(first) {
+		t.Fatalf("unchanged config: LastChanged moved to %v", got)
+	}
+	stats[0].DiffservMode = "diffserv8"
+	s.forcePoll()
+	if got := s.stats[0].LastChanged; !got.After(first) {
+		t.Errorf("diffserv change: LastChanged %v did not advance past %v", got, first)
+	}
+}
+
+// getInterfaces returns the interface names in /api/stats.
+func getInterfaces(t *testing.T, s *Server) []string {
+	t.Helper()
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sr types.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, cs := range sr.Interfaces {
+		names = append(names, cs.Interface)
+	}
+	return names
+}
+
+func TestDeleteInterface(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}, {Interface: "ifb4eth0"}})
+	s.forcePoll()
+	if names := getInterfaces(t, s); len(names) != 2 {
+		t.Fatalf("before delete: want 2 interfaces, got %v", names)
+	}
+
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodDelete, "/api/interfaces/eth0", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: want 204, got %d", resp.StatusCode)
+	}
+	if names := getInterfaces(t, s); len(names) != 1 || names[0] != "ifb4eth0" {
+		t.Errorf("after delete: want [ifb4eth0], got %v", names)
+	}
+
+	resp, _ = s.app.Test(httptest.NewRequest(http.MethodDelete, "/api/interfaces/eth0", nil))
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("second delete: want 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestDeleteInterface_InvalidatesHistoryETag(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	s.forcePoll()
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := resp.Header.Get("ETag")
+
+	if resp, _ = s.app.Test(httptest.NewRequest(http.MethodDelete, "/api/interfaces/eth0", nil)); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: want 204, got %d", resp.StatusCode)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/history", nil)
+	req.Header.Set("If-None-Match", etag)
+	if resp, _ = s.app.Test(req); resp.StatusCode != http.StatusOK {
+		t.Errorf("history after delete: want 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	s := newTestServer(nil)
+	for _, sent := range []uint64{0, 1000, 3000} {
+		s.history.Record([]types.CakeStats{{Interface: "eth1", SentBytes: sent}}, time.Second)
+	}
+
+	now := time.Now().Unix()
+	url := "/api/compare?iface=eth1&t1=" + strconv.FormatInt(now-3600, 10) + "&t2=" + strconv.FormatInt(now+3600, 10)
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want 200, got %d", resp.StatusCode)
+	}
+	var cr types.CompareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cr); err != nil {
+		t.Fatal(err)
+	}
+	if cr.Delta.Tx != cr.T2.Tx-cr.T1.Tx {
+		t.Errorf("delta.tx %v != t2.tx %v - t1.tx %v", cr.Delta.Tx, cr.T2.Tx, cr.T1.Tx)
+	}
+
+	resp, _ = s.app.Test(httptest.NewRequest(http.MethodGet, "/api/compare?iface=eth1&t1=20&t2=10", nil))
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("t1 >= t2: want 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestStats_IncludeTiers(t *testing.T) {
+	tiers := make([]types.CakeTier, 4)
+	s := newTestServer([]types.CakeStats{{Interface: "eth0", Tiers: tiers, MaxAvDelayMs: 1.5}})
+	s.forcePoll()
+
+	get := func(url string) (types.StatsResponse, string) {
+		t.Helper()
+		resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		var sr types.StatsResponse
+		if err := json.Unmarshal(body, &sr); err != nil {
+			t.Fatal(err)
+		}
+		return sr, string(body)
+	}
+
+	if sr, _ := get("/api/stats"); len(sr.Interfaces[0].Tiers) != 4 {
+		t.Errorf("default: want 4 tiers, got %d", len(sr.Interfaces[0].Tiers))
+	}
+	sr, body := get("/api/stats?include_tiers=false")
+	if !strings.Contains(body, `"tiers":null`) {
+		t.Errorf("include_tiers=false: body lacks \"tiers\":null: %s", body)
+	}
+	if sr.Interfaces[0].MaxAvDelayMs != 1.5 {
+		t.Errorf("include_tiers=false: max_av_delay_ms want 1.5, got %v", sr.Interfaces[0].MaxAvDelayMs)
+	}
+	if sr, _ := get("/api/stats?include_tiers=true"); len(sr.Interfaces[0].Tiers) != 4 {
+		t.Error("include_tiers=false must not modify the shared snapshot")
+	}
+}
+
+func TestExcludeInterfaces(t *testing.T) {
+	s := New("", Config{Interval: time.Second, HistoryCap: 10, ExcludeInterfaces: []string{"ifb4eth0"}})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		return []types.CakeStats{{Interface: "eth0"}, {Interface: "ifb4eth0"}}, nil
+	}
+	s.forcePoll()
+	if got := getInterfaces(t, s); !slices.Equal(got, []string{"eth0"}) {
+		t.Errorf("want [eth0], got %v", got)
+	}
+	if _, ok := s.history.Samples("ifb4eth0"); ok {
+		t.Error("excluded interface has history state")
+	}
+}
+
+func TestCollectRound_PerDevice(t *testing.T) {
+	s := New("", Config{Interval: time.Second, HistoryCap: 10, Interfaces: []string{"eth0", "ifb4eth0", "wlan0"}})
+	full := 0
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		full++
+		return []types.CakeStats{{Interface: "eth0"}, {Interface: "eth1"}, {Interface: "ifb4eth0"}}, nil
+	}
+	var mu sync.Mutex
+	var polled []string
+	failing := ""
+	s.collectDevices = func(_ context.Context, ifaces []string) ([]types.CakeStats, error) {
+		mu.Lock()
+		polled = append(polled, ifaces...)
+		mu.Unlock()
+		var out []types.CakeStats
+		for _, iface := range ifaces {
+			if iface == failing {
+				return nil, errors.New(iface + ": Cannot find device")
+			}
+			out = append(out, types.CakeStats{Interface: iface})
+		}
+		return out, nil
+	}
+
+	names := func(stats []types.CakeStats) []string {
+		var out []string
+		for _, cs := range stats {
+			out = append(out, cs.Interface)
+		}
+		return out
+	}
+
+	stats, err := s.collectRound(context.Background())
+	if err != nil || full != 1 || len(polled) != 0 {
+		t.Fatalf("first round: err=%v full=%d polled=%v", err, full, polled)
+	}
+	if got := names(stats); !slices.Equal(got, []string{"eth0", "ifb4eth0"}) {
+		t.Fatalf("first round: want [eth0 ifb4eth0], got %v", got)
+	}
+
+	stats, err = s.collectRound(context.Background())
+	if err != nil || full != 1 {
+		t.Fatalf("second round: err=%v full=%d", err, full)
+	}
+	slices.Sort(polled)
+	if !slices.Equal(polled, []string{"eth0", "ifb4eth0"}) {
+		t.Errorf("second round polled %v, want eth0 and ifb4eth0", polled)
+	}
+	if got := names(stats); !slices.Equal(got, []string{"eth0", "ifb4eth0"}) {
+		t.Errorf("second round: want results in device order, got %v", got)
+	}
+
+	failing = "eth0"
+	if _, err := s.collectRound(context.Background()); err == nil {
+		t.Fatal("device error must fail the round")
+	}
+	failing = ""
+	if _, err := s.collectRound(context.Background()); err != nil || full != 2 {
+		t.Errorf("after a device error the next round must rescan: err=%v full=%d", err, full)
+	}
+}
+
+func TestIfaceFilter_NoHistoryForOthers(t *testing.T) {
+	s := New("", Config{Interval: time.Second, HistoryCap: 10, Interfaces: []string{"eth0"}})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		return []types.CakeStats{{Interface: "eth0"}, {Interface: "eth1"}}, nil
+	}
+	s.collectDevices = func(_ context.Context, ifaces []string) ([]types.CakeStats, error) {
+		var out []types.CakeStats
+		for _, iface := range ifaces {
+			out = append(out, types.CakeStats{Interface: iface})
+		}
+		return out, nil
+	}
+	for range 3 {
+		s.forcePoll()
+	}
+	if got := getInterfaces(t, s); !slices.Equal(got, []string{"eth0"}) {
+		t.Errorf("want [eth0], got %v", got)
+	}
+	if _, ok := s.history.Samples("eth1"); ok {
+		t.Error("filtered-out interface has history state")
+	}
+	if samples, _ := s.history.Samples("eth0"); len(samples) != 2 {
+		t.Errorf("eth0: want 2 samples, got %d", len(samples))
+	}
+}
+
+func TestPollErrors_LoggedOncePerKind(t *testing.T) {
+	var buf bytes.Buffer
+	old := log.Logger
+	log.Logger = zerolog.New(&buf).Level(zerolog.InfoLevel)
+	t.Cleanup(func() { log.Logger = old })
+
+	var pollErr error
+	s := New("", Config{Interval: time.Second, HistoryCap: 10})
+	s.collect = func(context.Context) ([]types.CakeStats, error) { return nil, pollErr }
+
+	warnings := func() int { return strings.Count(buf.String(), `"level":"warn"`) }
+
+	pollErr = fmt.Errorf("tc -s qdisc: %w: exit status 2", parser.ErrPermissionDenied)
+	for i := 0; i < 5; i++ {
+		s.forcePoll()
+	}
+	if n := warnings(); n != 1 {
+		t.Fatalf("repeated permission errors: want 1 warning, got %d\n%s", n, buf.String())
+	}
+	if strings.Contains(buf.String(), `"hint"`) {
+		t.Error("the permission hint is the parser's to log, not the poller's")
+	}
+
+	pollErr = errors.New("exit status 1")
+	s.forcePoll()
+	s.forcePoll()
+	if n := warnings(); n != 2 {
+		t.Fatalf("new error kind: want 2 warnings, got %d", n)
+	}
+
+	pollErr = nil
+	s.forcePoll()
+	if !strings.Contains(buf.String(), "tc poll recovered") {
+		t.Error("recovery should be logged")
+	}
+	pollErr = fmt.Errorf("again: %w", parser.ErrPermissionDenied)
+	s.forcePoll()
+	if n := warnings(); n != 3 {
+		t.Errorf("after recovery the same kind should warn again: got %d warnings", n)
+	}
+}
+
+func TestPollErrors_TCNotFoundLoggedOnce(t *testing.T) {
+	var buf bytes.Buffer
+	old := log.Logger
+	log.Logger = zerolog.New(&buf).Level(zerolog.DebugLevel)
+	t.Cleanup(func() { log.Logger = old })
+
+	s := New("", Config{Interval: time.Second, HistoryCap: 10})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		return nil, fmt.Errorf("%w (searched PATH)", parser.ErrTCNotFound)
+	}
+	for i := 0; i < 5; i++ {
+		s.forcePoll()
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 1 {
+		t.Fatalf("want exactly 1 log line, got %d\n%s", n, buf.String())
+	}
+	if !strings.Contains(buf.String(), `"level":"error"`) {
+		t.Errorf("missing tc should be logged at error level: %s", buf.String())
+	}
+}
+
+func TestHistoryTiers(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{{Name: "Bulk"}, {Name: "Voice"}}}})
+	s.forcePoll()
+	s.forcePoll()
+
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history/tiers", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status: want 200, got %d", resp.StatusCode)
+	}
+	var got types.TierHistoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if s := got["eth0"]; len(s) != 2 || s[0].Name != "Bulk" || s[1].Name != "Voice" {
+		t.Errorf("want one Bulk and one Voice sample, got %+v", got)
+	}
+}
+
+func TestHistoryIface(t *testing.T) {
+	s := newTestServer([]types.CakeStats{
+		{Interface: "eth0", Tiers: []types.CakeTier{{Name: "Bulk"}}},
+		{Interface: "wan/eth1"},
+	})
+	for i := 0; i < 3; i++ {
+		s.forcePoll()
+	}
+
+	for _, path := range []string{"/api/history/eth0", "/api/history?iface=eth0", "/api/history?iface=wan%2Feth1"} {
+		resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: want 200, got %d", path, resp.StatusCode)
+		}
+		var got []types.HistorySample
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatalf("%s: not a sample array: %v", path, err)
+		}
+		if len(got) != 2 {
+			t.Errorf("%s: want 2 samples, got %d", path, len(got))
+		}
+	}
+
+	resp, _ := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history/eth0/tiers", nil))
+	var tiers []types.TierHistorySample
+	if err := json.NewDecoder(resp.Body).Decode(&tiers); err != nil {
+		t.Fatal(err)
+	}
+	if len(tiers) != 2 || tiers[0].Name != "Bulk" {
+		t.Errorf("tiers: got %+v", tiers)
+	}
+
+	// The fixed routes still win over :iface.
+	resp, _ = s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history/tiers", nil))
+	var all types.TierHistoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil || len(all["eth0"]) != 2 {
+		t.Errorf("/api/history/tiers: got %v, %v", all, err)
+	}
+
+	for _, path := range []string{"/api/history/eth9", "/api/history/eth9/tiers", "/api/history?iface=eth9"} {
+		resp, _ := s.app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: want 404, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestHistoryPercentiles(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0", Tiers: []types.CakeTier{{AvDelay: "2ms", PkDelay: "4ms", SpDelay: "1ms"}}}})
+	for i := 0; i < 3; i++ {
+		s.forcePoll()
+	}
+
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history/percentiles?iface=eth0&p=99", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status: want 200, got %d", resp.StatusCode)
+	}
+	var got map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"av_p99": 2, "pk_p99": 4, "sp_p99": 1, "tx_p99": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	for url, code := range map[string]int{
+		"/api/history/percentiles?iface=eth9":       http.StatusNotFound,
+		"/api/history/percentiles?iface=eth0&p=abc": http.StatusBadRequest,
+		"/api/history/percentiles?iface=eth0&p=120": http.StatusBadRequest,
+		"/api/history/percentiles":                  http.StatusBadRequest,
+	} {
+		resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != code {
+			t.Errorf("%s: want %d, got %d", url, code, resp.StatusCode)
+		}
+	}
+}
+
+func TestHistory_ResolutionMinute(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	s.forcePoll()
+
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history?resolution=minute", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status: want 200, got %d", resp.StatusCode)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "{}" {
+		t.Errorf("no completed minute yet: want {}, got %s", body)
+	}
+
+	resp, _ = s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history?resolution=hour", nil))
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown resolution: want 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestPeaks(t *testing.T) {
+	sent := uint64(0)
+	s := New("", Config{Interval: time.Second, HistoryCap: 10})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		sent += 1000
+		return []types.CakeStats{{Interface: "eth0", SentBytes: sent}}, nil
+	}
+	s.forcePoll()
+	s.forcePoll()
+
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/peaks", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var peaks map[string]types.PeakStats
+	if err := json.NewDecoder(resp.Body).Decode(&peaks); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := peaks["eth0"]; !ok || p.Tx <= 0 || p.Since.IsZero() {
+		t.Fatalf("want a positive eth0 tx peak, got %+v", peaks)
+	}
+
+	for url, code := range map[string]int{
+		"/api/peaks/reset?iface=eth0": http.StatusNoContent,
+		"/api/peaks/reset?iface=eth9": http.StatusNotFound,
+		"/api/peaks/reset":            http.StatusBadRequest,
+	} {
+		resp, err := s.app.Test(httptest.NewRequest(http.MethodPost, url, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != code {
+			t.Errorf("POST %s: want %d, got %d", url, code, resp.StatusCode)
+		}
+	}
+	if p := s.history.PeakSnapshot()["eth0"]; p.Tx != 0 {
+		t.Errorf("after reset: want tx peak 0, got %v", p.Tx)
+	}
+}
+
+// pipeListener hands out the server ends of net.Pipe connections.
+type pipeListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr{} }
+
+// dial returns the client end of a new connection to the listener.
+func (l *pipeListener) dial() net.Conn {
+	client, server := net.Pipe()
+	l.conns <- server
+	return client
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+func TestBuildSSEEvent(t *testing.T) {
+	got := string(buildSSEEvent(sseEventStats, 1700000000, []byte(`{"a":1}`)))
+	if want := "retry: 2000\nid: 1700000000\nevent: stats\ndata: {\"a\":1}\n\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got := string(buildSSEEvent("alert", 0, []byte(`{}`))); !strings.Contains(got, "retry: 2000\nevent: alert\n") {
+		t.Errorf("alert event: got %q", got)
+	}
+}
+
+// statsResponse returns n interfaces of four tiers each.
+func statsResponse(n int) *types.StatsResponse {
+	stats := make([]types.CakeStats, n)
+	for i := range stats {
+		stats[i] = types.CakeStats{Interface: fmt.Sprintf("eth%d", i), Bandwidth: "100Mbit", SentBytes: 123456789, DropsPerS: 1.5}
+		for _, name := range []string{"Bulk", "Best Effort", "Video", "Voice"} {
+			stats[i].Tiers = append(stats[i].Tiers, types.CakeTier{Name: name, AvDelay: "1.2ms", PkDelay: "4ms", Pkts: 1000, Bytes: 1500000})
+		}
+	}
+	return &types.StatsResponse{Interfaces: stats, UpdatedAt: "2024-01-01T00:00:00Z"}
+}
+
+func TestBuildStatsEvent(t *testing.T) {
+	// 40 interfaces outgrow the initial buffer, 1 fits it.
+	for _, n := range []int{1, 40} {
+		resp := statsResponse(n)
+		want, _ := easyjson.Marshal(resp)
+		event, payload := buildStatsEvent(resp, 1700000000, 0)
+		if !bytes.Equal(payload, want) {
+			t.Errorf("%d interfaces: payload differs from easyjson.Marshal", n)
+		}
+		if !bytes.Equal(event, buildSSEEvent(sseEventStats, 1700000000, want)) {
+			t.Errorf("%d interfaces: event differs from buildSSEEvent", n)
+		}
+	}
+}
+
+// BenchmarkMarshalStats compares framing a 4-interface, 4-tier poll as an
+// SSE event via encoding/json, easyjson.Marshal and buildStatsEvent.
+func BenchmarkMarshalStats(b *testing.B) {
+	resp := statsResponse(4)
+	b.Run("encoding-json", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			payload, _ := json.Marshal(resp)
+			_ = buildSSEEvent(sseEventStats, 1, payload)
+		}
+	})
+	b.Run("easyjson", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			payload, _ := easyjson.Marshal(resp)
+			_ = buildSSEEvent(sseEventStats, 1, payload)
+		}
+	})
+	b.Run("direct", func(b *testing.B) {
+		event, _ := buildStatsEvent(resp, 1, 0)
+		b.ReportAllocs()
+		for b.Loop() {
+			event, _ = buildStatsEvent(resp, 1, len(event))
+		}
+	})
+}
+
+func TestSSE_HeartbeatDetectsDeadClient(t *testing.T) {
+	const heartbeat = 50 * time.Millisecond
+	s := New("", Config{Interval: time.Second, HistoryCap: 10, SSEHeartbeat: heartbeat})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		return []types.CakeStats{{Interface: "eth0"}}, nil
+	}
+	s.forcePoll()
+	ln := newPipeListener()
+	go func() { _ = s.app.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true}) }()
+	t.Cleanup(func() { _ = s.app.Shutdown() })
+
+	conn := ln.dial()
+	if _, err := io.WriteString(conn, "GET /events HTTP/1.1\r\nHost: pipe\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	// Read until the first heartbeat so the stream is known to be live.
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(line, ": heartbeat") {
+			break
+		}
+	}
+	if !waitClients(s, 1) {
+		t.Fatal("SSE client not registered")
+	}
+
+	// The peer vanishes without the server seeing a read; only a write can
+	// notice, and with no polls running that write is the heartbeat.
+	conn.Close()
+	start := time.Now()
+	deadline := start.Add(heartbeat + 500*time.Millisecond)
+	for {
+		s.ssesMu.Lock()
+		n := len(s.clients)
+		s.ssesMu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("SSE handler still running %v after the client died", time.Since(start))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// sseEvent is one parsed SSE event.
+type sseEvent struct {
+	id, event, data string
+}
+
+// readSSEEvent reads the next event from r, skipping comments.
+func readSSEEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if ev.event != "" {
+				return ev
+			}
+		case strings.HasPrefix(line, "id: "):
+			ev.id = line[len("id: "):]
+		case strings.HasPrefix(line, "event: "):
+			ev.event = line[len("event: "):]
+		case strings.HasPrefix(line, "data: "):
+			ev.data = line[len("data: "):]
+		}
+	}
+}
+
+func TestHistoryPrecision_Second(t *testing.T) {
+	s := New("", Config{Interval: time.Second, HistoryCap: 10, HistoryPrecision: time.Second})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		return []types.CakeStats{{Interface: "eth0"}}, nil
+	}
+	s.history.SetClock(func() time.Time { return time.UnixMilli(1700000000250) })
+	s.forcePoll()
+	s.statsMu.RLock()
+	id := s.statsID
+	s.statsMu.RUnlock()
+	if id != 1700000000000 {
+		t.Errorf("event ID: want the whole-second sample time 1700000000000, got %d", id)
+	}
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/api/history", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if bytes.Contains(body, []byte("t_ms")) {
+		t.Errorf("second precision must not add t_ms: %s", body)
+	}
+}
+
+func TestSSE_LastEventIDReplay(t *testing.T) {
+	// A short heartbeat lets the server notice the first client leaving.
+	s := New("", Config{Interval: time.Second, HistoryCap: 10, SSEHeartbeat: 20 * time.Millisecond})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		return []types.CakeStats{{Interface: "eth0"}}, nil
+	}
+	// Polls 100 ms apart share a second but must still get distinct IDs.
+	var tick atomic.Int64
+	base := time.Unix(1700000000, 0)
+	s.history.SetClock(func() time.Time { return base.Add(time.Duration(tick.Add(1)) * 100 * time.Millisecond) })
+	id := func(n int) string { return strconv.FormatInt(base.UnixMilli()+int64(n)*100, 10) }
+	s.forcePoll()
+	addr := listen(t, s)
+
+	connect := func(lastID string) (*http.Response, *bufio.Reader) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, "http://"+addr+"/events", nil)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	resp, r := connect("")
+	var seen []sseEvent
+	seen = append(seen, readSSEEvent(t, r)) // snapshot
+	if !waitClients(s, 1) {
+		t.Fatal("SSE client not registered")
+	}
+	for range 2 {
+		s.forcePoll()
+		seen = append(seen, readSSEEvent(t, r))
+	}
+	// The polls after the snapshot arrive as deltas from it.
+	for i, ev := range seen {
+		want := sseEventDelta
+		if i == 0 {
+			want = sseEventStats
+		}
+		if ev.event != want || ev.id != id(i+1) {
+			t.Fatalf("event %d: got %q id %q", i, ev.event, ev.id)
+		}
+	}
+	resp.Body.Close()
+	if !waitClients(s, 0) {
+		t.Fatal("SSE client not removed")
+	}
+
+	// Three polls happen while the client is away.
+	for range 3 {
+		s.forcePoll()
+	}
+	resp, r = connect(seen[2].id)
+	defer resp.Body.Close()
+	for i := range 3 {
+		ev := readSSEEvent(t, r)
+		want := id(i + 4)
+		if ev.event != sseEventHistory || ev.id != want {
+			t.Fatalf("replay %d: want history id %s, got %q id %q", i, want, ev.event, ev.id)
+		}
+		var h types.HistoryResponse
+		if err := json.Unmarshal([]byte(ev.data), &h); err != nil {
+			t.Fatal(err)
+		}
+		if got := h["eth0"]; len(got) != 1 || strconv.FormatInt(got[0].TMs, 10) != want {
+			t.Errorf("replay %d: got %+v", i, h)
+		}
+	}
+	if !waitClients(s, 1) {
+		t.Fatal("SSE client not registered")
+	}
+	s.forcePoll()
+	// A replay leaves the client without a snapshot, so this one is full.
+	if ev := readSSEEvent(t, r); ev.event != sseEventStats || ev.id != id(7) {
+		t.Errorf("live event after replay: got %q id %q", ev.event, ev.id)
+	}
+}
+
+func TestAPIAlerts(t *testing.T) {
+	s := New("", Config{Interval: time.Second, HistoryCap: 10,
+		AlertRules: []alert.AlertRule{{Metric: "drops_per_s", Op: ">", Threshold: 5}}})
+	clock := time.Unix(1700000000, 0)
+	s.alerts.SetClock(func() time.Time { return clock })
+
+	// eth0..eth4 start dropping one second apart; eth0, eth2 and eth4 then
+	// recover while eth1 and eth3 keep dropping.
+	stats := make([]types.CakeStats, 5)
+	for i := range stats {
+		stats[i].Interface = fmt.Sprintf("eth%d", i)
+		clock = clock.Add(time.Second)
+		stats[i].DropsPerS = 10
+		s.alerts.Evaluate(stats)
+	}
+	clock = clock.Add(2 * time.Second)
+	stats[0].DropsPerS, stats[2].DropsPerS, stats[4].DropsPerS = 0, 0, 0
+	s.alerts.Evaluate(stats)
+	clock = clock.Add(time.Second)
+
+	resp, err := s.app.Test(httptest.NewRequest("GET", "/api/alerts", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 5 {
+		t.Fatalf("want 5 alerts, got %d: %v", len(got), got)
+	}
+	for i, a := range got {
+		iface := fmt.Sprintf("eth%d", 4-i)
+		resolved := i%2 == 0
+		// eth<n> fired at n+1 s; resolved ones ended at 7 s, the rest run to 8 s.
+		wantMs := float64(8000 - (5-i)*1000)
+		if resolved {
+			wantMs -= 1000
+		}
+		if a["interface"] != iface || (a["resolved_at"] != nil) != resolved || a["duration_ms"] != wantMs {
+			t.Errorf("alert %d: want %s resolved=%v duration %v, got %v", i, iface, resolved, wantMs, a)
+		}
+	}
+}
+
+func TestTopologyEvents(t *testing.T) {
+	s := New("", Config{Interval: time.Second, HistoryCap: 10})
+	var polled []types.CakeStats
+	s.collect = func(context.Context) ([]types.CakeStats, error) { return slices.Clone(polled), nil }
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	// Drained after every poll: the client buffer holds only sseBufSize
+	// frames.
+	var got []string
+	poll := func() {
+		s.forcePoll()
+		for len(ch) > 0 {
+			f := <-ch
+			if bytes.Contains(f.event, []byte("event: "+sseEventTopology+"\n")) {
+				got = append(got, string(f.event[bytes.Index(f.event, []byte("data: "))+len("data: "):len(f.event)-2]))
+			}
+		}
+	}
+	polled = []types.CakeStats{{Interface: "eth0"}}
+	poll()
+	polled = nil
+	for range 3 {
+		poll()
+	}
+	want := []string{`{"type":"appear","iface":"eth0"}`, `{"type":"disappear","iface":"eth0"}`}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestShutdown_WaitsForSSE(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s := New("", Config{Interval: time.Second, HistoryCap: 10})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		return []types.CakeStats{{Interface: "eth0"}}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.Run(ctx, addr) }()
+
+	var resp *http.Response
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if resp, err = http.Get("http://" + addr + "/events"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+	}
+	defer resp.Body.Close()
+	readSSEEvent(t, bufio.NewReader(resp.Body))
+	if !waitClients(s, 1) {
+		t.Fatal("SSE client not registered")
+	}
+
+	cancel()
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return with an SSE client connected")
+	}
+	// The writer's deferred unsubscribe has run by the time Run returns.
+	s.ssesMu.Lock()
+	n := len(s.clients)
+	s.ssesMu.Unlock()
+	if n != 0 {
+		t.Errorf("want no clients after Run returns, got %d", n)
+	}
+}
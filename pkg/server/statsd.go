@@ -0,0 +1,181 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/galpt/cake-stats/pkg/log"
+	"github.com/galpt/cake-stats/pkg/types"
+	"github.com/galpt/cake-stats/pkg/util"
+)
+
+// statsdPrefix starts every StatsD metric name.
+const statsdPrefix = "cake_stats."
+
+// statsdMaxDatagram is the largest datagram sent: it fits a 1500-byte
+// Ethernet MTU with IPv6 and UDP headers to spare.
+const statsdMaxDatagram = 1432
+
+// statsdMetric is one CakeStats gauge, named after its JSON field.
+type statsdMetric struct {
+	name  string
+	value func(*types.CakeStats) float64
+}
+
+// statsdTierMetric is statsdMetric for one CakeTier field; delays are sent
+// in milliseconds and byte strings as byte counts.
+type statsdTierMetric struct {
+	name  string
+	value func(*types.CakeTier) float64
+}
+
+var statsdMetrics = []statsdMetric{
+	{"bandwidth_bps", func(cs *types.CakeStats) float64 { return float64(cs.BandwidthBps) }},
+	{"memlimit_bytes", func(cs *types.CakeStats) float64 { return float64(cs.MemLimitBytes) }},
+	{"sent_bytes", func(cs *types.CakeStats) float64 { return float64(cs.SentBytes) }},
+	{"sent_pkts", func(cs *types.CakeStats) float64 { return float64(cs.SentPkts) }},
+	{"dropped", func(cs *types.CakeStats) float64 { return float64(cs.Dropped) }},
+	{"overlimits", func(cs *types.CakeStats) float64 { return float64(cs.Overlimits) }},
+	{"requeues", func(cs *types.CakeStats) float64 { return float64(cs.Requeues) }},
+	{"backlog_bytes", func(cs *types.CakeStats) float64 { return float64(util.ParseBytesStr(cs.BacklogBytes)) }},
+	{"backlog_pkts", func(cs *types.CakeStats) float64 { return float64(cs.BacklogPkts) }},
+	{"memory_used", func(cs *types.CakeStats) float64 { return float64(util.ParseBytesStr(cs.MemoryUsed)) }},
+	{"memory_total", func(cs *types.CakeStats) float64 { return float64(util.ParseBytesStr(cs.MemoryTotal)) }},
+	{"max_flows", func(cs *types.CakeStats) float64 { return float64(cs.MaxFlows) }},
+	{"tx_bytes_per_s", func(cs *types.CakeStats) float64 { return cs.TxBytesPerS }},
+	{"drops_per_s", func(cs *types.CakeStats) float64 { return cs.DropsPerS }},
+	{"marks_per_s", func(cs *types.CakeStats) float64 { return cs.MarksPerS }},
+	{"ack_drop_per_s", func(cs *types.CakeStats) float64 { return cs.AckDropPerS }},
+	{"way_cols_per_s", func(cs *types.CakeStats) float64 { return cs.WayColsPerS }},
+	{"max_av_delay_ms", func(cs *types.CakeStats) float64 { return cs.MaxAvDelayMs }},
+	{"max_pk_delay_ms", func(cs *types.CakeStats) float64 { return cs.MaxPkDelayMs }},
+	{"utilization_pct", func(cs *types.CakeStats) float64 { return cs.UtilizationPct }},
+	{"memory_pct", func(cs *types.CakeStats) float64 { return cs.MemoryPct }},
+}
+
+var statsdTierMetrics = []statsdTierMetric{
+	{"tier.target_ms", func(t *types.CakeTier) float64 { return util.ParseDelayMs(t.Target) }},
+	{"tier.interval_ms", func(t *types.CakeTier) float64 { return util.ParseDelayMs(t.Interval) }},
+	{"tier.pk_delay_ms", func(t *types.CakeTier) float64 { return util.ParseDelayMs(t.PkDelay) }},
+	{"tier.av_delay_ms", func(t *types.CakeTier) float64 { return util.ParseDelayMs(t.AvDelay) }},
+	{"tier.sp_delay_ms", func(t *types.CakeTier) float64 { return util.ParseDelayMs(t.SpDelay) }},
+	{"tier.backlog", func(t *types.CakeTier) float64 { return float64(util.ParseBytesStr(t.Backlog)) }},
+	{"tier.pkts", func(t *types.CakeTier) float64 { return float64(t.Pkts) }},
+	{"tier.bytes", func(t *types.CakeTier) float64 { return float64(t.Bytes) }},
+	{"tier.way_inds", func(t *types.CakeTier) float64 { return float64(t.WayInds) }},
+	{"tier.way_miss", func(t *types.CakeTier) float64 { return float64(t.WayMiss) }},
+	{"tier.way_cols", func(t *types.CakeTier) float64 { return float64(t.WayCols) }},
+	{"tier.drops", func(t *types.CakeTier) float64 { return float64(t.Drops) }},
+	{"tier.marks", func(t *types.CakeTier) float64 { return float64(t.Marks) }},
+	{"tier.ack_drop", func(t *types.CakeTier) float64 { return float64(t.AckDrop) }},
+	{"tier.sp_flows", func(t *types.CakeTier) float64 { return float64(t.SpFlows) }},
+	{"tier.bk_flows", func(t *types.CakeTier) float64 { return float64(t.BkFlows) }},
+	{"tier.un_flows", func(t *types.CakeTier) float64 { return float64(t.UnFlows) }},
+	{"tier.max_len", func(t *types.CakeTier) float64 { return float64(t.MaxLen) }},
+	{"tier.quantum", func(t *types.CakeTier) float64 { return float64(t.Quantum) }},
+	{"tier.total_flows", func(t *types.CakeTier) float64 { return float64(t.TotalFlows) }},
+}
+
+// statsdTagValue replaces the characters that end a tag or a line in the
+// DogStatsD format, and spaces, with underscores: "Best Effort" is sent as
+// "Best_Effort".
+var statsdTagValue = strings.NewReplacer(",", "_", "|", "_", "#", "_", " ", "_", "\n", "_")
+
+// statsdClient pushes every poll to a StatsD or DogStatsD agent as gauges,
+// tagged DogStatsD-style with iface, direction and, for tier metrics, tier:
+//
+//	cake_stats.tx_bytes_per_s:1250000|g|#iface:eth0,direction:egress
+//	cake_stats.tier.av_delay_ms:0.042|g|#iface:eth0,direction:egress,tier:Best_Effort
+//
+// Lines are packed into datagrams of up to statsdMaxDatagram bytes.  Plain
+// StatsD servers that do not know tags ignore them or reject the line,
+// depending on the implementation.
+type statsdClient struct {
+	conn net.Conn
+	// buf holds the datagram being built and line the current line; both
+	// are reused across polls, which are serialized by pollMu.
+	buf  []byte
+	line []byte
+	// failing is set after a failed send, so an agent that stays down is
+	// logged once rather than on every poll.
+	failing bool
+}
+
+// dialStatsd opens the UDP socket to addr.  Nothing is sent, so an agent
+// that is not running yet is not an error.
+func dialStatsd(addr string) (*statsdClient, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: %w", err)
+	}
+	return &statsdClient{conn: conn, buf: make([]byte, 0, statsdMaxDatagram)}, nil
+}
+
+func (c *statsdClient) Close() error {
+	return c.conn.Close()
+}
+
+// send writes one gauge per statsdMetrics entry and interface, then one per
+// statsdTierMetrics entry and tier.  It returns the first write error; the
+// remaining datagrams are still sent.
+func (c *statsdClient) send(stats []types.CakeStats) error {
+	var first error
+	add := func(name string, v float64, tags string) {
+		l := append(c.line[:0], statsdPrefix...)
+		l = append(l, name...)
+		l = append(l, ':')
+		l = strconv.AppendFloat(l, v, 'f', -1, 64)
+		l = append(l, "|g|#"...)
+		l = append(l, tags...)
+		c.line = l
+		if len(c.buf) > 0 && len(c.buf)+1+len(l) > statsdMaxDatagram {
+			if err := c.flush(); err != nil && first == nil {
+				first = err
+			}
+		}
+		if len(c.buf) > 0 {
+			c.buf = append(c.buf, '\n')
+		}
+		c.buf = append(c.buf, l...)
+	}
+	for i := range stats {
+		cs := &stats[i]
+		tags := "iface:" + statsdTagValue.Replace(cs.Interface) + ",direction:" + statsdTagValue.Replace(cs.Direction)
+		for _, m := range statsdMetrics {
+			add(m.name, m.value(cs), tags)
+		}
+		for j := range cs.Tiers {
+			t := &cs.Tiers[j]
+			tierTags := tags + ",tier:" + statsdTagValue.Replace(t.Name)
+			for _, m := range statsdTierMetrics {
+				add(m.name, m.value(t), tierTags)
+			}
+		}
+	}
+	if len(c.buf) > 0 {
+		if err := c.flush(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// logResult warns about the first failed send and notes the recovery.
+func (c *statsdClient) logResult(err error) {
+	switch {
+	case err != nil && !c.failing:
+		c.failing = true
+		log.Logger.Warn().Err(err).Str("addr", c.conn.RemoteAddr().String()).Msg("statsd send failed; retrying every poll")
+	case err == nil && c.failing:
+		c.failing = false
+		log.Logger.Info().Str("addr", c.conn.RemoteAddr().String()).Msg("statsd send recovered")
+	}
+}
+
+func (c *statsdClient) flush() error {
+	_, err := c.conn.Write(c.buf)
+	c.buf = c.buf[:0]
+	return err
+}
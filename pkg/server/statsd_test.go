@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestStatsd(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	c, err := dialStatsd(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	stats := []types.CakeStats{{
+		Interface:    "eth0",
+		Direction:    "egress",
+		TxBytesPerS:  1234.5,
+		BacklogBytes: "1536b",
+		Tiers: []types.CakeTier{
+			{Name: "Bulk"},
+			{Name: "Best Effort", Pkts: 999, AvDelay: "42us"},
+		},
+	}}
+	if err := c.send(stats); err != nil {
+		t.Fatal(err)
+	}
+
+	want := len(statsdMetrics) + 2*len(statsdTierMetrics)
+	var lines []string
+	buf := make([]byte, 64<<10)
+	for len(lines) < want {
+		pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("after %d of %d lines: %v", len(lines), want, err)
+		}
+		if n > statsdMaxDatagram {
+			t.Errorf("datagram of %d bytes, want at most %d", n, statsdMaxDatagram)
+		}
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+	if len(lines) != want {
+		t.Errorf("got %d lines, want %d", len(lines), want)
+	}
+	for _, l := range []string{
+		"cake_stats.tx_bytes_per_s:1234.5|g|#iface:eth0,direction:egress",
+		"cake_stats.backlog_bytes:1536|g|#iface:eth0,direction:egress",
+		"cake_stats.dropped:0|g|#iface:eth0,direction:egress",
+		"cake_stats.tier.pkts:0|g|#iface:eth0,direction:egress,tier:Bulk",
+		"cake_stats.tier.pkts:999|g|#iface:eth0,direction:egress,tier:Best_Effort",
+		"cake_stats.tier.av_delay_ms:0.042|g|#iface:eth0,direction:egress,tier:Best_Effort",
+	} {
+		if !slices.Contains(lines, l) {
+			t.Errorf("missing %q", l)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+
+	"github.com/galpt/cake-stats/pkg/log"
+)
+
+// readiness tracks the two conditions for telling systemd READY=1 under
+// Type=notify: a successful poll and a bound HTTP listener, which may happen
+// in either order.
+type readiness struct {
+	mu        sync.Mutex
+	polled    bool
+	listening bool
+	notified  bool
+	// finished is when the last poll returned, successful or not, in Unix
+	// nanoseconds; runWatchdog pings only while it is recent.
+	finished atomic.Int64
+}
+
+// markPolled and markListening record one condition each and send READY=1
+// once both hold.
+func (s *Server) markPolled()    { s.markReady(true, false) }
+func (s *Server) markListening() { s.markReady(false, true) }
+
+func (s *Server) markReady(polled, listening bool) {
+	r := &s.ready
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.notified {
+		return
+	}
+	r.polled = r.polled || polled
+	r.listening = r.listening || listening
+	if r.polled && r.listening {
+		r.notified = true
+		sdNotify(daemon.SdNotifyReady)
+	}
+}
+
+// markPollFinished records that a poll returned, for runWatchdog.
+func (s *Server) markPollFinished() {
+	s.ready.finished.Store(time.Now().UnixNano())
+}
+
+// runWatchdog checks every half WATCHDOG_USEC until ctx is done and sends
+// WATCHDOG=1 only if a poll finished within the last WATCHDOG_USEC, so a
+// poller wedged in tc stops the pings and systemd restarts the service.
+// WatchdogSec must therefore exceed the poll interval.  It returns at once
+// when systemd has no watchdog configured for us.
+func (s *Server) runWatchdog(ctx context.Context) {
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(time.Unix(0, s.ready.finished.Load())) < interval {
+				sdNotify(daemon.SdNotifyWatchdog)
+			}
+		}
+	}
+}
+
+// sdNotify sends state to systemd.  Outside systemd (no NOTIFY_SOCKET) it
+// does nothing.
+func sdNotify(state string) {
+	if _, err := daemon.SdNotify(false, state); err != nil {
+		log.Logger.Debug().Err(err).Str("state", state).Msg("sd_notify failed")
+	}
+}
@@ -0,0 +1,88 @@
+//go:build unix
+
+package server
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestSDNotify_ReadyAfterPollAndListen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	read := func(wait time.Duration) string {
+		buf := make([]byte, 256)
+		_ = conn.SetReadDeadline(time.Now().Add(wait))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return ""
+		}
+		return string(buf[:n])
+	}
+
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	s.forcePoll()
+	if got := read(50 * time.Millisecond); got != "" {
+		t.Fatalf("notified %q before the listener was bound", got)
+	}
+	listen(t, s)
+	if got := read(2 * time.Second); got != "READY=1" {
+		t.Fatalf("want READY=1, got %q", got)
+	}
+	s.forcePoll()
+	if got := read(50 * time.Millisecond); got != "" {
+		t.Errorf("READY sent twice: %q", got)
+	}
+}
+
+func TestSDNotify_WatchdogNeedsRecentPoll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+	t.Setenv("WATCHDOG_USEC", "100000")
+
+	read := func(wait time.Duration) string {
+		buf := make([]byte, 256)
+		_ = conn.SetReadDeadline(time.Now().Add(wait))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return ""
+		}
+		return string(buf[:n])
+	}
+
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.runWatchdog(ctx)
+	if got := read(200 * time.Millisecond); got != "" {
+		t.Fatalf("pinged %q before any poll", got)
+	}
+	s.forcePoll()
+	if got := read(time.Second); got != "WATCHDOG=1" {
+		t.Fatalf("want WATCHDOG=1 after a poll, got %q", got)
+	}
+	// Without further polls the pings stop once the last one is older
+	// than WATCHDOG_USEC.
+	time.Sleep(150 * time.Millisecond)
+	for read(5*time.Millisecond) != "" {
+	}
+	if got := read(200 * time.Millisecond); got != "" {
+		t.Errorf("still pinging %q with the poller stalled", got)
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/log"
+)
+
+// tlsConfig builds the listener TLS configuration from the Config TLS
+// fields, or returns nil when TLS is off.  A certificate without a key (or
+// the reverse), or a certificate combined with TLSSelfSigned, is an error.
+func (s *Server) tlsConfig(hosts []string) (*tls.Config, error) {
+	switch {
+	case (s.tlsCert == "") != (s.tlsKey == ""):
+		return nil, errors.New("tls: certificate and key must be given together")
+	case s.tlsCert != "" && s.tlsSelfSigned:
+		return nil, errors.New("tls: a certificate file and a self-signed certificate are mutually exclusive")
+	case s.tlsCert != "":
+		cert, err := tls.LoadX509KeyPair(s.tlsCert, s.tlsKey)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+	case s.tlsSelfSigned:
+		cert, err := selfSignedCert(hosts)
+		if err != nil {
+			return nil, fmt.Errorf("tls: self-signed certificate: %w", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+	}
+	return nil, nil
+}
+
+// selfSignedCert generates an ECDSA P-256 certificate valid for one year for
+// localhost, the loopback addresses and hosts.  It lives only in memory, so
+// browsers see a new certificate on every start.
+func selfSignedCert(hosts []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"cake-stats"}, CommonName: "cake-stats"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	for _, h := range hosts {
+		if h == "" || h == "0.0.0.0" || h == "::" {
+			continue
+		}
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// httpsRedirect answers every plain-HTTP request with a permanent redirect
+// to the same host and path on tlsPort.
+func httpsRedirect(tlsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		target := "https://" + host
+		if tlsPort != "443" {
+			target = "https://" + net.JoinHostPort(host, tlsPort)
+		}
+		http.Redirect(w, r, target+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// runRedirect serves httpsRedirect on s.tlsRedirect until ctx is done.  A
+// failure to bind is logged but does not stop the HTTPS server.
+func (s *Server) runRedirect(ctx context.Context, tlsPort string) {
+	srv := &http.Server{
+		Addr:              s.tlsRedirect,
+		Handler:           httpsRedirect(tlsPort),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	log.Logger.Info().Str("addr", s.tlsRedirect).Msg("redirecting HTTP to HTTPS")
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Logger.Error().Err(err).Str("addr", s.tlsRedirect).Msg("HTTP redirect listener failed")
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v3"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestTLSConfig_Validation(t *testing.T) {
+	for _, cfg := range []Config{
+		{TLSCert: "cert.pem"},
+		{TLSKey: "key.pem"},
+		{TLSCert: "cert.pem", TLSKey: "key.pem", TLSSelfSigned: true},
+		{TLSCert: "/nonexistent/cert.pem", TLSKey: "/nonexistent/key.pem"},
+	} {
+		s := New("", cfg)
+		if _, err := s.tlsConfig(nil); err == nil {
+			t.Errorf("%+v: want error", cfg)
+		}
+	}
+	if tc, err := New("", Config{}).tlsConfig(nil); tc != nil || err != nil {
+		t.Errorf("no TLS: want nil, nil; got %v, %v", tc, err)
+	}
+}
+
+func TestSelfSignedCert(t *testing.T) {
+	cert, err := selfSignedCert([]string{"0.0.0.0", "router.lan", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := cert.Leaf
+	if leaf == nil || !bytes.Equal(leaf.Raw, cert.Certificate[0]) {
+		t.Fatal("Leaf must be the parsed DER certificate")
+	}
+	for _, h := range []string{"localhost", "router.lan", "127.0.0.1", "192.168.1.1"} {
+		if err := leaf.VerifyHostname(h); err != nil {
+			t.Errorf("%s: %v", h, err)
+		}
+	}
+}
+
+func TestTLS_ServesHTTPS(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	s.tlsSelfSigned = true
+	tc, err := s.tlsConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.app.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true}) }()
+	t.Cleanup(func() { _ = s.app.Shutdown() })
+
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/api/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS == nil {
+		t.Errorf("want 200 over TLS, got %d (tls %v)", resp.StatusCode, resp.TLS != nil)
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	for _, tc := range []struct{ host, port, want string }{
+		{"router.lan", "11112", "https://router.lan:11112/api/stats?x=1"},
+		{"router.lan:80", "443", "https://router.lan/api/stats?x=1"},
+		{"[::1]:80", "8443", "https://" + net.JoinHostPort("::1", "8443") + "/api/stats?x=1"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats?x=1", nil)
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		httpsRedirect(tc.port).ServeHTTP(rec, req)
+		if rec.Code != http.StatusMovedPermanently {
+			t.Errorf("%s: want 301, got %d", tc.host, rec.Code)
+		}
+		if got := rec.Header().Get("Location"); got != tc.want {
+			t.Errorf("%s: want %q, got %q", tc.host, tc.want, got)
+		}
+	}
+}
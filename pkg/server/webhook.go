@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mailru/easyjson"
+
+	"github.com/galpt/cake-stats/pkg/buildinfo"
+	"github.com/galpt/cake-stats/pkg/log"
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// DefaultWebhookTimeout bounds each webhook POST when Config.WebhookTimeout
+// is zero.
+const DefaultWebhookTimeout = 5 * time.Second
+
+// webhook POSTs each poll's StatsResponse JSON to a URL.  Deliveries run on
+// their own goroutine behind a one-slot queue: if the endpoint is slower
+// than the poll interval, stale payloads are replaced by the newest one
+// rather than delaying the poller.  The URL can be changed while running; an
+// empty URL turns delivery off.
+type webhook struct {
+	url        atomic.Pointer[string]
+	client     *http.Client
+	retryDelay time.Duration
+	queue      chan []byte
+}
+
+func newWebhook(url string, timeout time.Duration) *webhook {
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	w := &webhook{
+		client:     &http.Client{Timeout: timeout},
+		retryDelay: 500 * time.Millisecond,
+		queue:      make(chan []byte, 1),
+	}
+	w.setURL(url)
+	return w
+}
+
+// setURL changes the delivery URL from the next payload on.
+func (w *webhook) setURL(url string) {
+	w.url.Store(&url)
+}
+
+func (w *webhook) getURL() string {
+	return *w.url.Load()
+}
+
+// enqueue hands payload to the delivery goroutine without blocking,
+// replacing a payload still waiting to be sent.  It is a no-op while the URL
+// is empty.
+func (w *webhook) enqueue(payload []byte) {
+	if w.getURL() == "" {
+		return
+	}
+	for {
+		select {
+		case w.queue <- payload:
+			return
+		default:
+		}
+		select {
+		case <-w.queue:
+		default:
+		}
+	}
+}
+
+// run delivers queued payloads until ctx is done.
+func (w *webhook) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-w.queue:
+			if err := w.deliver(ctx, p); err != nil && ctx.Err() == nil {
+				log.Logger.Warn().Err(err).Str("url", w.getURL()).Msg("webhook delivery failed")
+			}
+		}
+	}
+}
+
+// deliver POSTs payload, retrying once after a transient failure (5xx or a
+// transport error such as a timeout), and returns the final error.
+func (w *webhook) deliver(ctx context.Context, payload []byte) error {
+	url := w.getURL()
+	if url == "" {
+		return nil
+	}
+	retry, err := w.post(ctx, url, payload)
+	if err != nil && retry {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(w.retryDelay):
+		}
+		_, err = w.post(ctx, url, payload)
+	}
+	return err
+}
+
+// WebhookDelivery is the alert.AlertDelivery that POSTs each alert as JSON
+// to Config.WebhookURL.  The server always includes it; it does nothing
+// while the URL is empty.
+type WebhookDelivery struct {
+	w *webhook
+}
+
+// Deliver POSTs a, retrying once after a transient failure.
+func (d WebhookDelivery) Deliver(ctx context.Context, a types.CakeAlert) error {
+	payload, _ := easyjson.Marshal(&a)
+	return d.w.deliver(ctx, payload)
+}
+
+// post makes one attempt and reports whether a failure is worth retrying.
+func (w *webhook) post(ctx context.Context, url string, payload []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "cake-stats/"+buildinfo.Version)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook: %s", resp.Status)
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/galpt/cake-stats/pkg/alert"
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+func TestWebhook_Payload(t *testing.T) {
+	bodies := make(chan []byte, 4)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("got %s with content-type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		b, _ := io.ReadAll(r.Body)
+		bodies <- b
+	}))
+	defer ts.Close()
+
+	s := New("", Config{Interval: time.Second, HistoryCap: 10, WebhookURL: ts.URL})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		return []types.CakeStats{{Interface: "eth0"}}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.webhook.run(ctx)
+	s.forcePoll()
+
+	select {
+	case b := <-bodies:
+		var resp types.StatsResponse
+		if err := json.Unmarshal(b, &resp); err != nil {
+			t.Fatalf("payload is not a StatsResponse: %v (%q)", err, b)
+		}
+		if len(resp.Interfaces) != 1 || resp.Interfaces[0].Interface != "eth0" || resp.UpdatedAt == "" {
+			t.Errorf("payload: got %+v", resp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestWebhook_Retry(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		statuses []int
+		attempts int32
+	}{
+		{"success", []int{200}, 1},
+		{"5xx then success", []int{503, 200}, 2},
+		{"5xx twice gives up", []int{500, 500, 200}, 2},
+		{"4xx is not retried", []int{400, 200}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var n atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statuses[n.Add(1)-1])
+			}))
+			defer ts.Close()
+			w := newWebhook(ts.URL, time.Second)
+			w.retryDelay = 0
+			w.deliver(context.Background(), []byte(`{}`))
+			if got := n.Load(); got != tc.attempts {
+				t.Errorf("want %d attempts, got %d", tc.attempts, got)
+			}
+		})
+	}
+}
+
+func TestWebhook_RetryOnTimeout(t *testing.T) {
+	var n atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer ts.Close()
+	w := newWebhook(ts.URL, 50*time.Millisecond)
+	w.retryDelay = 0
+	w.deliver(context.Background(), []byte(`{}`))
+	if got := n.Load(); got != 2 {
+		t.Errorf("want a retry after the timeout (2 attempts), got %d", got)
+	}
+}
+
+func TestWebhook_EnqueueKeepsNewest(t *testing.T) {
+	w := newWebhook("http://127.0.0.1:0", time.Second)
+	w.enqueue([]byte("old"))
+	w.enqueue([]byte("new"))
+	if got := string(<-w.queue); got != "new" {
+		t.Errorf("want the newest payload, got %q", got)
+	}
+}
+
+func TestAlert_PostedToWebhook(t *testing.T) {
+	alerts := make(chan types.CakeAlert, 4)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		var a types.CakeAlert
+		if json.Unmarshal(b, &a) == nil && a.Metric != "" {
+			alerts <- a
+		}
+	}))
+	defer ts.Close()
+
+	var dropped atomic.Uint64
+	s := New("", Config{
+		Interval: time.Second, HistoryCap: 10, WebhookURL: ts.URL,
+		AlertRules: []alert.AlertRule{{Metric: "drops_per_s", Op: ">", Threshold: 5}},
+	})
+	s.collect = func(context.Context) ([]types.CakeStats, error) {
+		return []types.CakeStats{{Interface: "eth0", Dropped: dropped.Load()}}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.webhook.run(ctx)
+	go s.alerts.Run(ctx)
+
+	// The first poll is the baseline and the second drops nothing.
+	s.forcePoll()
+	s.forcePoll()
+	select {
+	case a := <-alerts:
+		t.Fatalf("alert before any drops: %+v", a)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	dropped.Store(1000)
+	s.forcePoll()
+	select {
+	case a := <-alerts:
+		if a.Interface != "eth0" || a.Metric != "drops_per_s" || a.Value <= 5 || a.Threshold != 5 {
+			t.Errorf("alert: got %+v", a)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert not posted")
+	}
+}
+
+// deliveryFunc adapts a function to alert.AlertDelivery.
+type deliveryFunc func(context.Context, types.CakeAlert) error
+
+func (f deliveryFunc) Deliver(ctx context.Context, a types.CakeAlert) error { return f(ctx, a) }
+
+func TestDeliverAlert_AllBackends(t *testing.T) {
+	var posts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	var got []types.CakeAlert
+	s := New("", Config{
+		Interval: time.Second, HistoryCap: 10, WebhookURL: ts.URL,
+		AlertDeliveries: []alert.AlertDelivery{deliveryFunc(func(_ context.Context, a types.CakeAlert) error {
+			got = append(got, a)
+			return nil
+		})},
+	})
+	s.deliverAlert(context.Background(), types.CakeAlert{Interface: "eth0", Metric: "drops_per_s", Value: 9})
+	if posts.Load() != 1 {
+		t.Errorf("want 1 webhook POST, got %d", posts.Load())
+	}
+	if len(got) != 1 || got[0].Interface != "eth0" {
+		t.Errorf("want the alert after the failed webhook, got %+v", got)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"time"
+
+	"github.com/fasthttp/websocket"
+	fiber "github.com/gofiber/fiber/v3"
+	easyjson "github.com/mailru/easyjson"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+const wsWriteTimeout = 5 * time.Second
+
+var wsUpgrader = websocket.FastHTTPUpgrader{
+	ReadBufferSize:  512,
+	WriteBufferSize: 4096,
+}
+
+// handleWebSocket streams the same StatsResponse JSON as /events, one text
+// message per poll, for clients that prefer WebSocket over EventSource.  It
+// shares the SSE client registry, so broadcast feeds both transports.
+func (s *Server) handleWebSocket(c fiber.Ctx) error {
+	if !websocket.FastHTTPIsWebSocketUpgrade(c.RequestCtx()) {
+		return fiber.NewError(fiber.StatusUpgradeRequired, "websocket upgrade required")
+	}
+	// Encode the snapshot under the lock: the next poll recycles the slice.
+	var snapshot []byte
+	s.statsMu.RLock()
+	if len(s.stats) > 0 {
+		resp := types.StatsResponse{Interfaces: s.stats, UpdatedAt: time.Now().UTC().Format(time.RFC3339)}
+		snapshot, _ = easyjson.Marshal(&resp)
+	}
+	s.statsMu.RUnlock()
+
+	return wsUpgrader.Upgrade(c.RequestCtx(), func(conn *websocket.Conn) {
+		// The connection is hijacked, so app.Shutdown does not close it;
+		// shutdown waits for this goroutine instead.
+		if !s.addStream() {
+			closeGoingAway(conn)
+			return
+		}
+		defer s.sseWG.Done()
+		ch := s.subscribe()
+		defer s.unsubscribe(ch)
+
+		// The protocol is server-push only; the read loop exists to notice the
+		// client going away (close frame or broken connection).
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		send := func(payload []byte) error {
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			return conn.WriteMessage(websocket.TextMessage, payload)
+		}
+		if snapshot != nil && send(snapshot) != nil {
+			return
+		}
+		for {
+			select {
+			case <-done:
+				return
+			case <-s.done:
+				closeGoingAway(conn)
+				return
+			case f := <-ch:
+				if f.payload == nil {
+					continue // SSE-only frame (alert)
+				}
+				if send(f.payload) != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// addStream counts a hijacked stream in sseWG, unless shutdown has begun.
+func (s *Server) addStream() bool {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+	s.sseWG.Add(1)
+	return true
+}
+
+// closeGoingAway tells the client the server is shutting down.
+func closeGoingAway(conn *websocket.Conn) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+}
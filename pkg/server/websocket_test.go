@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	fiber "github.com/gofiber/fiber/v3"
+
+	"github.com/galpt/cake-stats/pkg/types"
+)
+
+// listen serves s.app on a loopback port and returns its address.
+func listen(t *testing.T, s *Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.app.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true}) }()
+	t.Cleanup(func() { _ = s.app.Shutdown() })
+	return ln.Addr().String()
+}
+
+// waitClients polls until s has n registered streaming clients.
+func waitClients(s *Server, n int) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.ssesMu.Lock()
+		got := len(s.clients)
+		s.ssesMu.Unlock()
+		if got == n {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebSocket_ReceivesSnapshotAndBroadcast(t *testing.T) {
+	for _, path := range []string{"/ws", "/api/live"} {
+		t.Run(path, func(t *testing.T) { testWebSocketStream(t, path) })
+	}
+}
+
+func testWebSocketStream(t *testing.T, path string) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	s.forcePoll()
+	addr := listen(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, "ws://"+addr+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	read := func() types.StatsResponse {
+		t.Helper()
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp types.StatsResponse
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			t.Fatalf("frame is not bare StatsResponse JSON: %v (%q)", err, msg)
+		}
+		return resp
+	}
+	if resp := read(); len(resp.Interfaces) != 1 || resp.Interfaces[0].Interface != "eth0" {
+		t.Fatalf("initial snapshot: got %+v", resp)
+	}
+
+	// Wait for the handler to register before broadcasting.
+	waitClients(s, 1)
+	s.forcePoll()
+	if resp := read(); len(resp.Interfaces) != 1 {
+		t.Fatalf("broadcast frame: got %+v", resp)
+	}
+
+	conn.Close()
+	if !waitClients(s, 0) {
+		t.Error("client still registered after disconnect")
+	}
+}
+
+func TestWebSocket_ClosedOnShutdown(t *testing.T) {
+	s := newTestServer([]types.CakeStats{{Interface: "eth0"}})
+	s.forcePoll()
+	addr := listen(t, s)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+	if !waitClients(s, 1) {
+		t.Fatal("WebSocket client not registered")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.shutdown()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("shutdown still waiting for the WebSocket client")
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("want a going-away close frame, got %v", err)
+	}
+}
+
+func TestWebSocket_Disabled(t *testing.T) {
+	s := New("", Config{Interval: time.Second, HistoryCap: 10, NoWebSocket: true})
+	for _, path := range []string{"/ws", "/api/live"} {
+		resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: want 404 with -no-ws, got %d", path, resp.StatusCode)
+		}
+	}
+}
@@ -29,15 +29,24 @@ type CakeTier struct {
 	UnFlows  uint64 `json:"un_flows"`
 	MaxLen   uint64 `json:"max_len"`
 	Quantum  uint64 `json:"quantum"`
+	// TotalFlows is SpFlows + BkFlows + UnFlows: the tier's active flows.
+	TotalFlows uint64 `json:"total_flows"`
 }
 
 // CakeStats holds all parsed information for a single CAKE qdisc instance.
 type CakeStats struct {
-	Interface    string `json:"interface"`
-	Handle       string `json:"handle"`
-	Direction    string `json:"direction"`
-	Bandwidth    string `json:"bandwidth"`
+	Interface string `json:"interface"`
+	Handle    string `json:"handle"`
+	Direction string `json:"direction"`
+	Bandwidth string `json:"bandwidth"`
+	// BandwidthBps is Bandwidth in bits per second; 0 when unlimited,
+	// autorate-ingress or unparseable.
+	BandwidthBps uint64 `json:"bandwidth_bps"`
 	DiffservMode string `json:"diffserv_mode"`
+	// DiffservCode is DiffservMode as a number (besteffort 1, diffserv3 3,
+	// diffserv4 4, diffserv8 8, precedence 0) for consumers that prefer a
+	// compact, low-cardinality value.
+	DiffservCode int    `json:"diffserv_code"`
 	RTT          string `json:"rtt"`
 	Overhead     string `json:"overhead"`
 	DualMode     string `json:"dual_mode"`
@@ -50,11 +59,22 @@ type CakeStats struct {
 	// MPU stores the minimum packet unit value when configured (e.g. "84").
 	// Empty string means the mpu parameter was absent or zero.
 	MPU string `json:"mpu"`
+	// MPUBytes is MPU as an integer for overhead arithmetic; 0 when MPU is
+	// empty or not numeric.
+	MPUBytes int `json:"mpu_bytes"`
 	// WashEnabled is true when CAKE is configured with the "wash" keyword,
 	// which re-marks DSCP on forwarded packets.  False means "nowash".
-	WashEnabled bool   `json:"wash_enabled"`
+	WashEnabled bool `json:"wash_enabled"`
+	// SplitGSO is true with "split-gso" (GSO super-packets are split before
+	// queueing) and false with "no-split-gso".
+	SplitGSO bool `json:"split_gso"`
+	// NoAckFilter is true with "no-ack-filter"; false when "ack-filter" or
+	// "ack-filter-aggressive" is configured.
+	NoAckFilter bool   `json:"no_ack_filter"`
 	MemLimit    string `json:"memlimit"`
-	RawHeader   string `json:"raw_header"`
+	// MemLimitBytes is MemLimit in bytes; 0 when no memlimit is configured.
+	MemLimitBytes uint64 `json:"memlimit_bytes"`
+	RawHeader     string `json:"raw_header"`
 
 	SentBytes  uint64 `json:"sent_bytes"`
 	SentPkts   uint64 `json:"sent_pkts"`
@@ -75,15 +95,35 @@ type CakeStats struct {
 	MaxAdjSize   string `json:"max_adj_size"`
 	AvgHdrOffset string `json:"avg_hdr_offset"`
 
-	Tiers     []CakeTier `json:"tiers"`
-	UpdatedAt time.Time  `json:"updated_at"`
+	Tiers []CakeTier `json:"tiers"`
+	// MaxFlows is the largest TotalFlows across Tiers.
+	MaxFlows  uint64    `json:"max_flows"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// LastChanged is when the CAKE configuration (bandwidth, diffserv mode,
+	// rtt, …) last differed from the previous poll.  Set by the server; equals
+	// UpdatedAt on the poll an interface is first seen.
+	LastChanged time.Time `json:"last_changed"`
 
 	// Computed per-poll by HistoryStore.Record — not parsed from tc output.
 	// Zero on the first poll (no previous sample to diff against).
-	TxBytesPerS  float64 `json:"tx_bytes_per_s"`
-	DropsPerS    float64 `json:"drops_per_s"`
+	TxBytesPerS float64 `json:"tx_bytes_per_s"`
+	DropsPerS   float64 `json:"drops_per_s"`
+	// MarksPerS and AckDropPerS are ECN marks and ACK-filter drops per
+	// second, summed across tiers.
+	MarksPerS   float64 `json:"marks_per_s"`
+	AckDropPerS float64 `json:"ack_drop_per_s"`
+	// WayColsPerS is flow hash collisions per second summed across tiers.
+	// A sustained non-zero rate means the flow table is too small for the
+	// number of concurrent flows.
+	WayColsPerS  float64 `json:"way_cols_per_s"`
 	MaxAvDelayMs float64 `json:"max_av_delay_ms"`
 	MaxPkDelayMs float64 `json:"max_pk_delay_ms"`
+	// UtilizationPct is TxBytesPerS as a percentage of BandwidthBps; 0 when
+	// the shaper has no fixed rate (unlimited or autorate-ingress).
+	UtilizationPct float64 `json:"utilization_pct"`
+	// MemoryPct is MemoryUsed as a percentage of MemoryTotal (the memlimit);
+	// 0 when the total is unknown.
+	MemoryPct float64 `json:"memory_pct"`
 }
 
 // HistorySample is one time-series data point for a single CAKE interface.
@@ -91,10 +131,64 @@ type CakeStats struct {
 // libraries (uPlot, Chart.js, etc.).
 type HistorySample struct {
 	T  int64   `json:"t"`  // unix timestamp (seconds)
-	Tx float64 `json:"tx"` // bytes transmitted per second (TX throughput)
+	Tx float64 `json:"tx"` // bytes transmitted per second, EMA-smoothed (see HistoryStore.SetEMAAlpha)
 	Av float64 `json:"av"` // max av_delay across all tiers (milliseconds)
 	Pk float64 `json:"pk"` // max pk_delay across all tiers (milliseconds)
 	Dr float64 `json:"dr"` // packet drops per second
+	// Wm is the absolute increase in way_miss (summed across tiers) since the
+	// previous sample.  Kept as an integer delta rather than a rate so small
+	// counts are not lost to float rounding.
+	Wm uint64 `json:"wm"`
+	// Wi is way_inds per second summed across tiers: hash lookups that needed
+	// an indirection.  Unlike way_miss and way_cols this is expected to be
+	// non-zero on a healthy link; it scales with the number of active flows.
+	Wi float64 `json:"wi"`
+	// Sp is the max sp_delay across all tiers (milliseconds): how long
+	// sparse flows wait, a measure of flow isolation.
+	Sp float64 `json:"sp"`
+	// Rq is requeues per second, a sign of pressure on the hardware TX
+	// queue below CAKE.
+	Rq float64 `json:"rq"`
+	// Mk and Ad are ECN marks and ACK-filter drops per second, summed
+	// across tiers.
+	Mk float64 `json:"mk"`
+	Ad float64 `json:"ad"`
+	// Fl is the largest number of active flows in any tier (CakeStats.MaxFlows).
+	Fl float64 `json:"fl"`
+	// Util is CakeStats.UtilizationPct at the time of the sample.
+	Util float64 `json:"util"`
+	// Mem is CakeStats.MemoryPct at the time of the sample.
+	Mem float64 `json:"mem"`
+	// TMs and TUs carry the same instant at millisecond / microsecond
+	// resolution.  Only the one matching HistoryStore.SetPrecision is filled
+	// in; the other is zero and omitted from JSON.
+	TMs int64 `json:"t_ms,omitempty"`
+	TUs int64 `json:"t_us,omitempty"`
+}
+
+// TierHistorySample is one time-series data point for a single CAKE tier
+// (tin), recorded alongside the interface-wide HistorySample.
+type TierHistorySample struct {
+	T           int64   `json:"t"`              // unix timestamp (seconds)
+	Name        string  `json:"name"`           // tier name, e.g. "Best Effort"
+	AvMs        float64 `json:"av_ms"`          // av_delay (milliseconds)
+	PkMs        float64 `json:"pk_ms"`          // pk_delay (milliseconds)
+	DrRate      float64 `json:"dr_rate"`        // drops per second
+	TxBytesPerS float64 `json:"tx_bytes_per_s"` // bytes sent per second
+}
+
+// TierHistoryResponse maps interface name to its tier samples, grouped by
+// tier in the order tc reports them and oldest first within each tier.
+type TierHistoryResponse map[string][]TierHistorySample
+
+// PeakStats holds the highest instantaneous values seen on an interface
+// since Since: startup, discovery or the last reset via /api/peaks/reset.
+type PeakStats struct {
+	Tx    float64   `json:"tx"` // bytes per second (raw, not EMA-smoothed)
+	Pk    float64   `json:"pk"` // max pk_delay across tiers (milliseconds)
+	Av    float64   `json:"av"` // max av_delay across tiers (milliseconds)
+	Dr    float64   `json:"dr"` // drops per second
+	Since time.Time `json:"since"`
 }
 
 // StatsResponse is the JSON message sent to clients containing the current
@@ -104,6 +198,108 @@ type StatsResponse struct {
 	UpdatedAt  string      `json:"updated_at"`
 }
 
+// PercentileResponse is returned by /api/stats/percentile.
+type PercentileResponse struct {
+	Interface string  `json:"interface"`
+	Field     string  `json:"field"`
+	Pct       float64 `json:"pct"`
+	Value     float64 `json:"value"`
+}
+
+// SampleDelta is HistorySample t2 minus t1 for /api/compare.  Positive values
+// mean the metric increased.
+type SampleDelta struct {
+	T  int64   `json:"t"`
+	Tx float64 `json:"tx"`
+	Av float64 `json:"av"`
+	Pk float64 `json:"pk"`
+	Dr float64 `json:"dr"`
+	Wm int64   `json:"wm"`
+	Wi float64 `json:"wi"`
+	Sp float64 `json:"sp"`
+	Rq float64 `json:"rq"`
+}
+
+// CompareResponse is returned by /api/compare: the samples nearest to the two
+// requested timestamps and their difference.
+type CompareResponse struct {
+	Interface string        `json:"interface"`
+	T1        HistorySample `json:"t1"`
+	T2        HistorySample `json:"t2"`
+	Delta     SampleDelta   `json:"delta"`
+}
+
+// HealthResponse is returned by /healthz: Status is "ok" once a poll has
+// succeeded, "starting" before that.  UptimeS is nil (omitted) while
+// starting, so a zero uptime still appears once ready.
+type HealthResponse struct {
+	Status  string `json:"status"`
+	UptimeS *int64 `json:"uptime_s,omitempty"`
+}
+
+// VersionResponse is returned by /api/version; see pkg/buildinfo.
+type VersionResponse struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	BuiltAt   string `json:"built_at"`
+	Commit    string `json:"commit"`
+}
+
+// CakeAlert reports an alert rule matching one interface's statistics, or
+// ceasing to match.  It is the body POSTed to the webhook and the data of SSE
+// "alert" events.  ResolvedAt is nil while the alert is firing; a recovery
+// carries the TriggeredAt of the alert it resolves and the current Value.
+type CakeAlert struct {
+	Interface   string     `json:"interface"`
+	Metric      string     `json:"metric"`
+	Value       float64    `json:"value"`
+	Threshold   float64    `json:"threshold"`
+	TriggeredAt time.Time  `json:"triggered_at"`
+	ResolvedAt  *time.Time `json:"resolved_at"`
+	// Bandwidth is the interface's configured CakeStats.Bandwidth, set on
+	// utilization_pct alerts.
+	Bandwidth string `json:"bandwidth,omitempty"`
+	// Tier is the tier with the largest delay, set on max_av_delay_ms and
+	// max_pk_delay_ms alerts.
+	Tier string `json:"tier,omitempty"`
+}
+
+// AlertLogEntry is one alert in the log returned by /api/alerts: the alert
+// as it first fired, with ResolvedAt set once it recovered.  DurationMs is
+// the time from TriggeredAt to ResolvedAt, or to now while still firing.
+type AlertLogEntry struct {
+	CakeAlert
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// AlertsResponse is returned by /api/alerts, newest alert first.
+//
+//easyjson:json
+type AlertsResponse []AlertLogEntry
+
+// TopologyEvent is the data of SSE "topology" events: Type is "appear" when
+// an interface is first recorded and "disappear" when its history is pruned.
+type TopologyEvent struct {
+	Type  string `json:"type"`
+	Iface string `json:"iface"`
+}
+
+// ErrorResponse is the JSON body returned for every non-2xx API response.
+// RequestID echoes the X-Request-ID header so a client report can be matched
+// against the server log.
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	Code      int    `json:"code"`
+	RequestID string `json:"request_id"`
+}
+
 // HistoryResponse is the serializable representation of the in-memory history
 // store.  It's a map from interface name to an ordered slice of samples.
 type HistoryResponse map[string][]HistorySample
+
+// HistorySnapshot holds both history rings: Samples at full poll resolution
+// and Minute at one sample per minute, each keyed by interface name.
+type HistorySnapshot struct {
+	Samples HistoryResponse `json:"samples"`
+	Minute  HistoryResponse `json:"minute"`
+}
@@ -7,6 +7,7 @@ import (
 	easyjson "github.com/mailru/easyjson"
 	jlexer "github.com/mailru/easyjson/jlexer"
 	jwriter "github.com/mailru/easyjson/jwriter"
+	time "time"
 )
 
 // suppress unused package warning
@@ -17,7 +18,7 @@ var (
 	_ easyjson.Marshaler
 )
 
-func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes(in *jlexer.Lexer, out *StatsResponse) {
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes(in *jlexer.Lexer, out *VersionResponse) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -31,38 +32,29 @@ func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes(in *jlexer.Lexer, out
 		key := in.UnsafeFieldName(false)
 		in.WantColon()
 		switch key {
-		case "interfaces":
+		case "version":
 			if in.IsNull() {
 				in.Skip()
-				out.Interfaces = nil
 			} else {
-				in.Delim('[')
-				if out.Interfaces == nil {
-					if !in.IsDelim(']') {
-						out.Interfaces = make([]CakeStats, 0, 0)
-					} else {
-						out.Interfaces = []CakeStats{}
-					}
-				} else {
-					out.Interfaces = (out.Interfaces)[:0]
-				}
-				for !in.IsDelim(']') {
-					var v1 CakeStats
-					if in.IsNull() {
-						in.Skip()
-					} else {
-						(v1).UnmarshalEasyJSON(in)
-					}
-					out.Interfaces = append(out.Interfaces, v1)
-					in.WantComma()
-				}
-				in.Delim(']')
+				out.Version = string(in.String())
 			}
-		case "updated_at":
+		case "go_version":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.UpdatedAt = string(in.String())
+				out.GoVersion = string(in.String())
+			}
+		case "built_at":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.BuiltAt = string(in.String())
+			}
+		case "commit":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Commit = string(in.String())
 			}
 		default:
 			in.SkipRecursive()
@@ -74,58 +66,57 @@ func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes(in *jlexer.Lexer, out
 		in.Consumed()
 	}
 }
-func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes(out *jwriter.Writer, in StatsResponse) {
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes(out *jwriter.Writer, in VersionResponse) {
 	out.RawByte('{')
 	first := true
 	_ = first
 	{
-		const prefix string = ",\"interfaces\":"
+		const prefix string = ",\"version\":"
 		out.RawString(prefix[1:])
-		if in.Interfaces == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
-			out.RawString("null")
-		} else {
-			out.RawByte('[')
-			for v2, v3 := range in.Interfaces {
-				if v2 > 0 {
-					out.RawByte(',')
-				}
-				(v3).MarshalEasyJSON(out)
-			}
-			out.RawByte(']')
-		}
+		out.String(string(in.Version))
 	}
 	{
-		const prefix string = ",\"updated_at\":"
+		const prefix string = ",\"go_version\":"
 		out.RawString(prefix)
-		out.String(string(in.UpdatedAt))
+		out.String(string(in.GoVersion))
+	}
+	{
+		const prefix string = ",\"built_at\":"
+		out.RawString(prefix)
+		out.String(string(in.BuiltAt))
+	}
+	{
+		const prefix string = ",\"commit\":"
+		out.RawString(prefix)
+		out.String(string(in.Commit))
 	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v StatsResponse) MarshalJSON() ([]byte, error) {
+func (v VersionResponse) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
 	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
-func (v StatsResponse) MarshalEasyJSON(w *jwriter.Writer) {
+func (v VersionResponse) MarshalEasyJSON(w *jwriter.Writer) {
 	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
-func (v *StatsResponse) UnmarshalJSON(data []byte) error {
+func (v *VersionResponse) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
 	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
-func (v *StatsResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
+func (v *VersionResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes(l, v)
 }
-func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes1(in *jlexer.Lexer, out *HistorySample) {
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes1(in *jlexer.Lexer, out *TopologyEvent) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -139,35 +130,17 @@ func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes1(in *jlexer.Lexer, ou
 		key := in.UnsafeFieldName(false)
 		in.WantColon()
 		switch key {
-		case "t":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.T = int64(in.Int64())
-			}
-		case "tx":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Tx = float64(in.Float64())
-			}
-		case "av":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Av = float64(in.Float64())
-			}
-		case "pk":
+		case "type":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.Pk = float64(in.Float64())
+				out.Type = string(in.String())
 			}
-		case "dr":
+		case "iface":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.Dr = float64(in.Float64())
+				out.Iface = string(in.String())
 			}
 		default:
 			in.SkipRecursive()
@@ -179,62 +152,47 @@ func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes1(in *jlexer.Lexer, ou
 		in.Consumed()
 	}
 }
-func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes1(out *jwriter.Writer, in HistorySample) {
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes1(out *jwriter.Writer, in TopologyEvent) {
 	out.RawByte('{')
 	first := true
 	_ = first
 	{
-		const prefix string = ",\"t\":"
+		const prefix string = ",\"type\":"
 		out.RawString(prefix[1:])
-		out.Int64(int64(in.T))
-	}
-	{
-		const prefix string = ",\"tx\":"
-		out.RawString(prefix)
-		out.Float64(float64(in.Tx))
-	}
-	{
-		const prefix string = ",\"av\":"
-		out.RawString(prefix)
-		out.Float64(float64(in.Av))
-	}
-	{
-		const prefix string = ",\"pk\":"
-		out.RawString(prefix)
-		out.Float64(float64(in.Pk))
+		out.String(string(in.Type))
 	}
 	{
-		const prefix string = ",\"dr\":"
+		const prefix string = ",\"iface\":"
 		out.RawString(prefix)
-		out.Float64(float64(in.Dr))
+		out.String(string(in.Iface))
 	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v HistorySample) MarshalJSON() ([]byte, error) {
+func (v TopologyEvent) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
 	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes1(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
-func (v HistorySample) MarshalEasyJSON(w *jwriter.Writer) {
+func (v TopologyEvent) MarshalEasyJSON(w *jwriter.Writer) {
 	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes1(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
-func (v *HistorySample) UnmarshalJSON(data []byte) error {
+func (v *TopologyEvent) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
 	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes1(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
-func (v *HistorySample) UnmarshalEasyJSON(l *jlexer.Lexer) {
+func (v *TopologyEvent) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes1(l, v)
 }
-func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes2(in *jlexer.Lexer, out *CakeTier) {
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes2(in *jlexer.Lexer, out *TierHistorySample) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -248,131 +206,41 @@ func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes2(in *jlexer.Lexer, ou
 		key := in.UnsafeFieldName(false)
 		in.WantColon()
 		switch key {
-		case "name":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Name = string(in.String())
-			}
-		case "thresh":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Thresh = string(in.String())
-			}
-		case "target":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Target = string(in.String())
-			}
-		case "interval":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Interval = string(in.String())
-			}
-		case "pk_delay":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.PkDelay = string(in.String())
-			}
-		case "av_delay":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.AvDelay = string(in.String())
-			}
-		case "sp_delay":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.SpDelay = string(in.String())
-			}
-		case "backlog":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Backlog = string(in.String())
-			}
-		case "pkts":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Pkts = uint64(in.Uint64())
-			}
-		case "bytes":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Bytes = uint64(in.Uint64())
-			}
-		case "way_inds":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.WayInds = uint64(in.Uint64())
-			}
-		case "way_miss":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.WayMiss = uint64(in.Uint64())
-			}
-		case "way_cols":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.WayCols = uint64(in.Uint64())
-			}
-		case "drops":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Drops = uint64(in.Uint64())
-			}
-		case "marks":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Marks = uint64(in.Uint64())
-			}
-		case "ack_drop":
+		case "t":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.AckDrop = uint64(in.Uint64())
+				out.T = int64(in.Int64())
 			}
-		case "sp_flows":
+		case "name":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.SpFlows = uint64(in.Uint64())
+				out.Name = string(in.String())
 			}
-		case "bk_flows":
+		case "av_ms":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.BkFlows = uint64(in.Uint64())
+				out.AvMs = float64(in.Float64())
 			}
-		case "un_flows":
+		case "pk_ms":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.UnFlows = uint64(in.Uint64())
+				out.PkMs = float64(in.Float64())
 			}
-		case "max_len":
+		case "dr_rate":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.MaxLen = uint64(in.Uint64())
+				out.DrRate = float64(in.Float64())
 			}
-		case "quantum":
+		case "tx_bytes_per_s":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.Quantum = uint64(in.Uint64())
+				out.TxBytesPerS = float64(in.Float64())
 			}
 		default:
 			in.SkipRecursive()
@@ -384,142 +252,2161 @@ func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes2(in *jlexer.Lexer, ou
 		in.Consumed()
 	}
 }
-func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes2(out *jwriter.Writer, in CakeTier) {
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes2(out *jwriter.Writer, in TierHistorySample) {
 	out.RawByte('{')
 	first := true
 	_ = first
 	{
-		const prefix string = ",\"name\":"
+		const prefix string = ",\"t\":"
 		out.RawString(prefix[1:])
-		out.String(string(in.Name))
-	}
-	{
-		const prefix string = ",\"thresh\":"
-		out.RawString(prefix)
-		out.String(string(in.Thresh))
-	}
-	{
-		const prefix string = ",\"target\":"
-		out.RawString(prefix)
-		out.String(string(in.Target))
-	}
-	{
-		const prefix string = ",\"interval\":"
-		out.RawString(prefix)
-		out.String(string(in.Interval))
-	}
-	{
-		const prefix string = ",\"pk_delay\":"
-		out.RawString(prefix)
-		out.String(string(in.PkDelay))
-	}
-	{
-		const prefix string = ",\"av_delay\":"
-		out.RawString(prefix)
-		out.String(string(in.AvDelay))
+		out.Int64(int64(in.T))
 	}
 	{
-		const prefix string = ",\"sp_delay\":"
+		const prefix string = ",\"name\":"
 		out.RawString(prefix)
-		out.String(string(in.SpDelay))
+		out.String(string(in.Name))
 	}
 	{
-		const prefix string = ",\"backlog\":"
+		const prefix string = ",\"av_ms\":"
 		out.RawString(prefix)
-		out.String(string(in.Backlog))
+		out.Float64(float64(in.AvMs))
 	}
 	{
-		const prefix string = ",\"pkts\":"
+		const prefix string = ",\"pk_ms\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.Pkts))
+		out.Float64(float64(in.PkMs))
 	}
 	{
-		const prefix string = ",\"bytes\":"
+		const prefix string = ",\"dr_rate\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.Bytes))
+		out.Float64(float64(in.DrRate))
 	}
 	{
-		const prefix string = ",\"way_inds\":"
+		const prefix string = ",\"tx_bytes_per_s\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.WayInds))
+		out.Float64(float64(in.TxBytesPerS))
 	}
-	{
-		const prefix string = ",\"way_miss\":"
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v TierHistorySample) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes2(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v TierHistorySample) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes2(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *TierHistorySample) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes2(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *TierHistorySample) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes2(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes3(in *jlexer.Lexer, out *StatsResponse) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "interfaces":
+			if in.IsNull() {
+				in.Skip()
+				out.Interfaces = nil
+			} else {
+				in.Delim('[')
+				if out.Interfaces == nil {
+					if !in.IsDelim(']') {
+						out.Interfaces = make([]CakeStats, 0, 0)
+					} else {
+						out.Interfaces = []CakeStats{}
+					}
+				} else {
+					out.Interfaces = (out.Interfaces)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v1 CakeStats
+					if in.IsNull() {
+						in.Skip()
+					} else {
+						(v1).UnmarshalEasyJSON(in)
+					}
+					out.Interfaces = append(out.Interfaces, v1)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "updated_at":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.UpdatedAt = string(in.String())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes3(out *jwriter.Writer, in StatsResponse) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"interfaces\":"
+		out.RawString(prefix[1:])
+		if in.Interfaces == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v2, v3 := range in.Interfaces {
+				if v2 > 0 {
+					out.RawByte(',')
+				}
+				(v3).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"updated_at\":"
+		out.RawString(prefix)
+		out.String(string(in.UpdatedAt))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v StatsResponse) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v StatsResponse) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *StatsResponse) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *StatsResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes3(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes4(in *jlexer.Lexer, out *SampleDelta) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "t":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.T = int64(in.Int64())
+			}
+		case "tx":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Tx = float64(in.Float64())
+			}
+		case "av":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Av = float64(in.Float64())
+			}
+		case "pk":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Pk = float64(in.Float64())
+			}
+		case "dr":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Dr = float64(in.Float64())
+			}
+		case "wm":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Wm = int64(in.Int64())
+			}
+		case "wi":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Wi = float64(in.Float64())
+			}
+		case "sp":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Sp = float64(in.Float64())
+			}
+		case "rq":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Rq = float64(in.Float64())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes4(out *jwriter.Writer, in SampleDelta) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"t\":"
+		out.RawString(prefix[1:])
+		out.Int64(int64(in.T))
+	}
+	{
+		const prefix string = ",\"tx\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Tx))
+	}
+	{
+		const prefix string = ",\"av\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Av))
+	}
+	{
+		const prefix string = ",\"pk\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Pk))
+	}
+	{
+		const prefix string = ",\"dr\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Dr))
+	}
+	{
+		const prefix string = ",\"wm\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.Wm))
+	}
+	{
+		const prefix string = ",\"wi\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Wi))
+	}
+	{
+		const prefix string = ",\"sp\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Sp))
+	}
+	{
+		const prefix string = ",\"rq\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Rq))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v SampleDelta) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes4(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v SampleDelta) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes4(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *SampleDelta) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes4(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *SampleDelta) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes4(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes5(in *jlexer.Lexer, out *PercentileResponse) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "interface":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Interface = string(in.String())
+			}
+		case "field":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Field = string(in.String())
+			}
+		case "pct":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Pct = float64(in.Float64())
+			}
+		case "value":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Value = float64(in.Float64())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes5(out *jwriter.Writer, in PercentileResponse) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"interface\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Interface))
+	}
+	{
+		const prefix string = ",\"field\":"
+		out.RawString(prefix)
+		out.String(string(in.Field))
+	}
+	{
+		const prefix string = ",\"pct\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Pct))
+	}
+	{
+		const prefix string = ",\"value\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Value))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v PercentileResponse) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes5(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v PercentileResponse) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes5(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *PercentileResponse) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes5(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *PercentileResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes5(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes6(in *jlexer.Lexer, out *PeakStats) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "tx":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Tx = float64(in.Float64())
+			}
+		case "pk":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Pk = float64(in.Float64())
+			}
+		case "av":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Av = float64(in.Float64())
+			}
+		case "dr":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Dr = float64(in.Float64())
+			}
+		case "since":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				if data := in.Raw(); in.Ok() {
+					in.AddError((out.Since).UnmarshalJSON(data))
+				}
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes6(out *jwriter.Writer, in PeakStats) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"tx\":"
+		out.RawString(prefix[1:])
+		out.Float64(float64(in.Tx))
+	}
+	{
+		const prefix string = ",\"pk\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Pk))
+	}
+	{
+		const prefix string = ",\"av\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Av))
+	}
+	{
+		const prefix string = ",\"dr\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Dr))
+	}
+	{
+		const prefix string = ",\"since\":"
+		out.RawString(prefix)
+		out.Raw((in.Since).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v PeakStats) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes6(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v PeakStats) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes6(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *PeakStats) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes6(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *PeakStats) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes6(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes7(in *jlexer.Lexer, out *HistorySnapshot) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "samples":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				in.Delim('{')
+				out.Samples = make(HistoryResponse)
+				for !in.IsDelim('}') {
+					key := string(in.String())
+					in.WantColon()
+					var v4 []HistorySample
+					if in.IsNull() {
+						in.Skip()
+						v4 = nil
+					} else {
+						in.Delim('[')
+						if v4 == nil {
+							if !in.IsDelim(']') {
+								v4 = make([]HistorySample, 0, 0)
+							} else {
+								v4 = []HistorySample{}
+							}
+						} else {
+							v4 = (v4)[:0]
+						}
+						for !in.IsDelim(']') {
+							var v5 HistorySample
+							if in.IsNull() {
+								in.Skip()
+							} else {
+								(v5).UnmarshalEasyJSON(in)
+							}
+							v4 = append(v4, v5)
+							in.WantComma()
+						}
+						in.Delim(']')
+					}
+					(out.Samples)[key] = v4
+					in.WantComma()
+				}
+				in.Delim('}')
+			}
+		case "minute":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				in.Delim('{')
+				out.Minute = make(HistoryResponse)
+				for !in.IsDelim('}') {
+					key := string(in.String())
+					in.WantColon()
+					var v6 []HistorySample
+					if in.IsNull() {
+						in.Skip()
+						v6 = nil
+					} else {
+						in.Delim('[')
+						if v6 == nil {
+							if !in.IsDelim(']') {
+								v6 = make([]HistorySample, 0, 0)
+							} else {
+								v6 = []HistorySample{}
+							}
+						} else {
+							v6 = (v6)[:0]
+						}
+						for !in.IsDelim(']') {
+							var v7 HistorySample
+							if in.IsNull() {
+								in.Skip()
+							} else {
+								(v7).UnmarshalEasyJSON(in)
+							}
+							v6 = append(v6, v7)
+							in.WantComma()
+						}
+						in.Delim(']')
+					}
+					(out.Minute)[key] = v6
+					in.WantComma()
+				}
+				in.Delim('}')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes7(out *jwriter.Writer, in HistorySnapshot) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"samples\":"
+		out.RawString(prefix[1:])
+		if in.Samples == nil && (out.Flags&jwriter.NilMapAsEmpty) == 0 {
+			out.RawString(`null`)
+		} else {
+			out.RawByte('{')
+			v8First := true
+			for v8Name, v8Value := range in.Samples {
+				if v8First {
+					v8First = false
+				} else {
+					out.RawByte(',')
+				}
+				out.String(string(v8Name))
+				out.RawByte(':')
+				if v8Value == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+					out.RawString("null")
+				} else {
+					out.RawByte('[')
+					for v9, v10 := range v8Value {
+						if v9 > 0 {
+							out.RawByte(',')
+						}
+						(v10).MarshalEasyJSON(out)
+					}
+					out.RawByte(']')
+				}
+			}
+			out.RawByte('}')
+		}
+	}
+	{
+		const prefix string = ",\"minute\":"
+		out.RawString(prefix)
+		if in.Minute == nil && (out.Flags&jwriter.NilMapAsEmpty) == 0 {
+			out.RawString(`null`)
+		} else {
+			out.RawByte('{')
+			v11First := true
+			for v11Name, v11Value := range in.Minute {
+				if v11First {
+					v11First = false
+				} else {
+					out.RawByte(',')
+				}
+				out.String(string(v11Name))
+				out.RawByte(':')
+				if v11Value == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+					out.RawString("null")
+				} else {
+					out.RawByte('[')
+					for v12, v13 := range v11Value {
+						if v12 > 0 {
+							out.RawByte(',')
+						}
+						(v13).MarshalEasyJSON(out)
+					}
+					out.RawByte(']')
+				}
+			}
+			out.RawByte('}')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v HistorySnapshot) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes7(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v HistorySnapshot) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes7(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *HistorySnapshot) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes7(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *HistorySnapshot) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes7(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes8(in *jlexer.Lexer, out *HistorySample) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "t":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.T = int64(in.Int64())
+			}
+		case "tx":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Tx = float64(in.Float64())
+			}
+		case "av":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Av = float64(in.Float64())
+			}
+		case "pk":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Pk = float64(in.Float64())
+			}
+		case "dr":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Dr = float64(in.Float64())
+			}
+		case "wm":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Wm = uint64(in.Uint64())
+			}
+		case "wi":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Wi = float64(in.Float64())
+			}
+		case "sp":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Sp = float64(in.Float64())
+			}
+		case "rq":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Rq = float64(in.Float64())
+			}
+		case "mk":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Mk = float64(in.Float64())
+			}
+		case "ad":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Ad = float64(in.Float64())
+			}
+		case "fl":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Fl = float64(in.Float64())
+			}
+		case "util":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Util = float64(in.Float64())
+			}
+		case "mem":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Mem = float64(in.Float64())
+			}
+		case "t_ms":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.TMs = int64(in.Int64())
+			}
+		case "t_us":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.TUs = int64(in.Int64())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes8(out *jwriter.Writer, in HistorySample) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"t\":"
+		out.RawString(prefix[1:])
+		out.Int64(int64(in.T))
+	}
+	{
+		const prefix string = ",\"tx\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Tx))
+	}
+	{
+		const prefix string = ",\"av\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Av))
+	}
+	{
+		const prefix string = ",\"pk\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Pk))
+	}
+	{
+		const prefix string = ",\"dr\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Dr))
+	}
+	{
+		const prefix string = ",\"wm\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Wm))
+	}
+	{
+		const prefix string = ",\"wi\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Wi))
+	}
+	{
+		const prefix string = ",\"sp\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Sp))
+	}
+	{
+		const prefix string = ",\"rq\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Rq))
+	}
+	{
+		const prefix string = ",\"mk\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Mk))
+	}
+	{
+		const prefix string = ",\"ad\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Ad))
+	}
+	{
+		const prefix string = ",\"fl\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Fl))
+	}
+	{
+		const prefix string = ",\"util\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Util))
+	}
+	{
+		const prefix string = ",\"mem\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Mem))
+	}
+	if in.TMs != 0 {
+		const prefix string = ",\"t_ms\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.TMs))
+	}
+	if in.TUs != 0 {
+		const prefix string = ",\"t_us\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.TUs))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v HistorySample) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes8(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v HistorySample) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes8(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *HistorySample) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes8(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *HistorySample) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes8(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes9(in *jlexer.Lexer, out *HealthResponse) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "status":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Status = string(in.String())
+			}
+		case "uptime_s":
+			if in.IsNull() {
+				in.Skip()
+				out.UptimeS = nil
+			} else {
+				if out.UptimeS == nil {
+					out.UptimeS = new(int64)
+				}
+				if in.IsNull() {
+					in.Skip()
+				} else {
+					*out.UptimeS = int64(in.Int64())
+				}
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes9(out *jwriter.Writer, in HealthResponse) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"status\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Status))
+	}
+	if in.UptimeS != nil {
+		const prefix string = ",\"uptime_s\":"
+		out.RawString(prefix)
+		out.Int64(int64(*in.UptimeS))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v HealthResponse) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes9(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v HealthResponse) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes9(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *HealthResponse) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes9(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *HealthResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes9(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes10(in *jlexer.Lexer, out *ErrorResponse) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "error":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Error = string(in.String())
+			}
+		case "code":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Code = int(in.Int())
+			}
+		case "request_id":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.RequestID = string(in.String())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes10(out *jwriter.Writer, in ErrorResponse) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"error\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Error))
+	}
+	{
+		const prefix string = ",\"code\":"
+		out.RawString(prefix)
+		out.Int(int(in.Code))
+	}
+	{
+		const prefix string = ",\"request_id\":"
+		out.RawString(prefix)
+		out.String(string(in.RequestID))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ErrorResponse) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes10(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ErrorResponse) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes10(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ErrorResponse) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes10(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ErrorResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes10(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes11(in *jlexer.Lexer, out *CompareResponse) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "interface":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Interface = string(in.String())
+			}
+		case "t1":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				(out.T1).UnmarshalEasyJSON(in)
+			}
+		case "t2":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				(out.T2).UnmarshalEasyJSON(in)
+			}
+		case "delta":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				(out.Delta).UnmarshalEasyJSON(in)
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes11(out *jwriter.Writer, in CompareResponse) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"interface\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Interface))
+	}
+	{
+		const prefix string = ",\"t1\":"
+		out.RawString(prefix)
+		(in.T1).MarshalEasyJSON(out)
+	}
+	{
+		const prefix string = ",\"t2\":"
+		out.RawString(prefix)
+		(in.T2).MarshalEasyJSON(out)
+	}
+	{
+		const prefix string = ",\"delta\":"
+		out.RawString(prefix)
+		(in.Delta).MarshalEasyJSON(out)
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v CompareResponse) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes11(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v CompareResponse) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes11(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *CompareResponse) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes11(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *CompareResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes11(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes12(in *jlexer.Lexer, out *CakeTier) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "name":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Name = string(in.String())
+			}
+		case "thresh":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Thresh = string(in.String())
+			}
+		case "target":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Target = string(in.String())
+			}
+		case "interval":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Interval = string(in.String())
+			}
+		case "pk_delay":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.PkDelay = string(in.String())
+			}
+		case "av_delay":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.AvDelay = string(in.String())
+			}
+		case "sp_delay":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.SpDelay = string(in.String())
+			}
+		case "backlog":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Backlog = string(in.String())
+			}
+		case "pkts":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Pkts = uint64(in.Uint64())
+			}
+		case "bytes":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Bytes = uint64(in.Uint64())
+			}
+		case "way_inds":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.WayInds = uint64(in.Uint64())
+			}
+		case "way_miss":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.WayMiss = uint64(in.Uint64())
+			}
+		case "way_cols":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.WayCols = uint64(in.Uint64())
+			}
+		case "drops":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Drops = uint64(in.Uint64())
+			}
+		case "marks":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Marks = uint64(in.Uint64())
+			}
+		case "ack_drop":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.AckDrop = uint64(in.Uint64())
+			}
+		case "sp_flows":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.SpFlows = uint64(in.Uint64())
+			}
+		case "bk_flows":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.BkFlows = uint64(in.Uint64())
+			}
+		case "un_flows":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.UnFlows = uint64(in.Uint64())
+			}
+		case "max_len":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MaxLen = uint64(in.Uint64())
+			}
+		case "quantum":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Quantum = uint64(in.Uint64())
+			}
+		case "total_flows":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.TotalFlows = uint64(in.Uint64())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes12(out *jwriter.Writer, in CakeTier) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"name\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Name))
+	}
+	{
+		const prefix string = ",\"thresh\":"
+		out.RawString(prefix)
+		out.String(string(in.Thresh))
+	}
+	{
+		const prefix string = ",\"target\":"
+		out.RawString(prefix)
+		out.String(string(in.Target))
+	}
+	{
+		const prefix string = ",\"interval\":"
+		out.RawString(prefix)
+		out.String(string(in.Interval))
+	}
+	{
+		const prefix string = ",\"pk_delay\":"
+		out.RawString(prefix)
+		out.String(string(in.PkDelay))
+	}
+	{
+		const prefix string = ",\"av_delay\":"
+		out.RawString(prefix)
+		out.String(string(in.AvDelay))
+	}
+	{
+		const prefix string = ",\"sp_delay\":"
+		out.RawString(prefix)
+		out.String(string(in.SpDelay))
+	}
+	{
+		const prefix string = ",\"backlog\":"
+		out.RawString(prefix)
+		out.String(string(in.Backlog))
+	}
+	{
+		const prefix string = ",\"pkts\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Pkts))
+	}
+	{
+		const prefix string = ",\"bytes\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Bytes))
+	}
+	{
+		const prefix string = ",\"way_inds\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.WayInds))
+	}
+	{
+		const prefix string = ",\"way_miss\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.WayMiss))
+	}
+	{
+		const prefix string = ",\"way_cols\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.WayCols))
+	}
+	{
+		const prefix string = ",\"drops\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Drops))
+	}
+	{
+		const prefix string = ",\"marks\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Marks))
+	}
+	{
+		const prefix string = ",\"ack_drop\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.AckDrop))
+	}
+	{
+		const prefix string = ",\"sp_flows\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.SpFlows))
+	}
+	{
+		const prefix string = ",\"bk_flows\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.BkFlows))
+	}
+	{
+		const prefix string = ",\"un_flows\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.UnFlows))
+	}
+	{
+		const prefix string = ",\"max_len\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.MaxLen))
+	}
+	{
+		const prefix string = ",\"quantum\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Quantum))
+	}
+	{
+		const prefix string = ",\"total_flows\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.TotalFlows))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v CakeTier) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes12(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v CakeTier) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes12(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *CakeTier) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes12(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *CakeTier) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes12(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes13(in *jlexer.Lexer, out *CakeStats) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "interface":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Interface = string(in.String())
+			}
+		case "handle":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Handle = string(in.String())
+			}
+		case "direction":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Direction = string(in.String())
+			}
+		case "bandwidth":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Bandwidth = string(in.String())
+			}
+		case "bandwidth_bps":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.BandwidthBps = uint64(in.Uint64())
+			}
+		case "diffserv_mode":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.DiffservMode = string(in.String())
+			}
+		case "diffserv_code":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.DiffservCode = int(in.Int())
+			}
+		case "rtt":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.RTT = string(in.String())
+			}
+		case "overhead":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Overhead = string(in.String())
+			}
+		case "dual_mode":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.DualMode = string(in.String())
+			}
+		case "fwmark_mask":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.FwmarkMask = string(in.String())
+			}
+		case "nat_enabled":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.NATEnabled = bool(in.Bool())
+			}
+		case "atm_mode":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.ATMMode = string(in.String())
+			}
+		case "mpu":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MPU = string(in.String())
+			}
+		case "mpu_bytes":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MPUBytes = int(in.Int())
+			}
+		case "wash_enabled":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.WashEnabled = bool(in.Bool())
+			}
+		case "split_gso":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.SplitGSO = bool(in.Bool())
+			}
+		case "no_ack_filter":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.NoAckFilter = bool(in.Bool())
+			}
+		case "memlimit":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MemLimit = string(in.String())
+			}
+		case "memlimit_bytes":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MemLimitBytes = uint64(in.Uint64())
+			}
+		case "raw_header":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.RawHeader = string(in.String())
+			}
+		case "sent_bytes":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.SentBytes = uint64(in.Uint64())
+			}
+		case "sent_pkts":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.SentPkts = uint64(in.Uint64())
+			}
+		case "dropped":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Dropped = uint64(in.Uint64())
+			}
+		case "overlimits":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Overlimits = uint64(in.Uint64())
+			}
+		case "requeues":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Requeues = uint64(in.Uint64())
+			}
+		case "backlog_bytes":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.BacklogBytes = string(in.String())
+			}
+		case "backlog_pkts":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.BacklogPkts = uint64(in.Uint64())
+			}
+		case "memory_used":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MemoryUsed = string(in.String())
+			}
+		case "memory_total":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MemoryTotal = string(in.String())
+			}
+		case "capacity_estimate":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.CapacityEst = string(in.String())
+			}
+		case "min_net_size":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MinNetSize = string(in.String())
+			}
+		case "max_net_size":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MaxNetSize = string(in.String())
+			}
+		case "min_adj_size":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MinAdjSize = string(in.String())
+			}
+		case "max_adj_size":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MaxAdjSize = string(in.String())
+			}
+		case "avg_hdr_offset":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.AvgHdrOffset = string(in.String())
+			}
+		case "tiers":
+			if in.IsNull() {
+				in.Skip()
+				out.Tiers = nil
+			} else {
+				in.Delim('[')
+				if out.Tiers == nil {
+					if !in.IsDelim(']') {
+						out.Tiers = make([]CakeTier, 0, 0)
+					} else {
+						out.Tiers = []CakeTier{}
+					}
+				} else {
+					out.Tiers = (out.Tiers)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v14 CakeTier
+					if in.IsNull() {
+						in.Skip()
+					} else {
+						(v14).UnmarshalEasyJSON(in)
+					}
+					out.Tiers = append(out.Tiers, v14)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "max_flows":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MaxFlows = uint64(in.Uint64())
+			}
+		case "updated_at":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				if data := in.Raw(); in.Ok() {
+					in.AddError((out.UpdatedAt).UnmarshalJSON(data))
+				}
+			}
+		case "last_changed":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				if data := in.Raw(); in.Ok() {
+					in.AddError((out.LastChanged).UnmarshalJSON(data))
+				}
+			}
+		case "tx_bytes_per_s":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.TxBytesPerS = float64(in.Float64())
+			}
+		case "drops_per_s":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.DropsPerS = float64(in.Float64())
+			}
+		case "marks_per_s":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MarksPerS = float64(in.Float64())
+			}
+		case "ack_drop_per_s":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.AckDropPerS = float64(in.Float64())
+			}
+		case "way_cols_per_s":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.WayColsPerS = float64(in.Float64())
+			}
+		case "max_av_delay_ms":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MaxAvDelayMs = float64(in.Float64())
+			}
+		case "max_pk_delay_ms":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MaxPkDelayMs = float64(in.Float64())
+			}
+		case "utilization_pct":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.UtilizationPct = float64(in.Float64())
+			}
+		case "memory_pct":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MemoryPct = float64(in.Float64())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes13(out *jwriter.Writer, in CakeStats) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"interface\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Interface))
+	}
+	{
+		const prefix string = ",\"handle\":"
+		out.RawString(prefix)
+		out.String(string(in.Handle))
+	}
+	{
+		const prefix string = ",\"direction\":"
+		out.RawString(prefix)
+		out.String(string(in.Direction))
+	}
+	{
+		const prefix string = ",\"bandwidth\":"
+		out.RawString(prefix)
+		out.String(string(in.Bandwidth))
+	}
+	{
+		const prefix string = ",\"bandwidth_bps\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.BandwidthBps))
+	}
+	{
+		const prefix string = ",\"diffserv_mode\":"
+		out.RawString(prefix)
+		out.String(string(in.DiffservMode))
+	}
+	{
+		const prefix string = ",\"diffserv_code\":"
+		out.RawString(prefix)
+		out.Int(int(in.DiffservCode))
+	}
+	{
+		const prefix string = ",\"rtt\":"
+		out.RawString(prefix)
+		out.String(string(in.RTT))
+	}
+	{
+		const prefix string = ",\"overhead\":"
+		out.RawString(prefix)
+		out.String(string(in.Overhead))
+	}
+	{
+		const prefix string = ",\"dual_mode\":"
+		out.RawString(prefix)
+		out.String(string(in.DualMode))
+	}
+	{
+		const prefix string = ",\"fwmark_mask\":"
+		out.RawString(prefix)
+		out.String(string(in.FwmarkMask))
+	}
+	{
+		const prefix string = ",\"nat_enabled\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.NATEnabled))
+	}
+	{
+		const prefix string = ",\"atm_mode\":"
+		out.RawString(prefix)
+		out.String(string(in.ATMMode))
+	}
+	{
+		const prefix string = ",\"mpu\":"
+		out.RawString(prefix)
+		out.String(string(in.MPU))
+	}
+	{
+		const prefix string = ",\"mpu_bytes\":"
+		out.RawString(prefix)
+		out.Int(int(in.MPUBytes))
+	}
+	{
+		const prefix string = ",\"wash_enabled\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.WashEnabled))
+	}
+	{
+		const prefix string = ",\"split_gso\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.SplitGSO))
+	}
+	{
+		const prefix string = ",\"no_ack_filter\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.NoAckFilter))
+	}
+	{
+		const prefix string = ",\"memlimit\":"
+		out.RawString(prefix)
+		out.String(string(in.MemLimit))
+	}
+	{
+		const prefix string = ",\"memlimit_bytes\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.MemLimitBytes))
+	}
+	{
+		const prefix string = ",\"raw_header\":"
+		out.RawString(prefix)
+		out.String(string(in.RawHeader))
+	}
+	{
+		const prefix string = ",\"sent_bytes\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.SentBytes))
+	}
+	{
+		const prefix string = ",\"sent_pkts\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.SentPkts))
+	}
+	{
+		const prefix string = ",\"dropped\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Dropped))
+	}
+	{
+		const prefix string = ",\"overlimits\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Overlimits))
+	}
+	{
+		const prefix string = ",\"requeues\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Requeues))
+	}
+	{
+		const prefix string = ",\"backlog_bytes\":"
+		out.RawString(prefix)
+		out.String(string(in.BacklogBytes))
+	}
+	{
+		const prefix string = ",\"backlog_pkts\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.BacklogPkts))
+	}
+	{
+		const prefix string = ",\"memory_used\":"
+		out.RawString(prefix)
+		out.String(string(in.MemoryUsed))
+	}
+	{
+		const prefix string = ",\"memory_total\":"
+		out.RawString(prefix)
+		out.String(string(in.MemoryTotal))
+	}
+	{
+		const prefix string = ",\"capacity_estimate\":"
+		out.RawString(prefix)
+		out.String(string(in.CapacityEst))
+	}
+	{
+		const prefix string = ",\"min_net_size\":"
+		out.RawString(prefix)
+		out.String(string(in.MinNetSize))
+	}
+	{
+		const prefix string = ",\"max_net_size\":"
+		out.RawString(prefix)
+		out.String(string(in.MaxNetSize))
+	}
+	{
+		const prefix string = ",\"min_adj_size\":"
+		out.RawString(prefix)
+		out.String(string(in.MinAdjSize))
+	}
+	{
+		const prefix string = ",\"max_adj_size\":"
+		out.RawString(prefix)
+		out.String(string(in.MaxAdjSize))
+	}
+	{
+		const prefix string = ",\"avg_hdr_offset\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.WayMiss))
+		out.String(string(in.AvgHdrOffset))
 	}
 	{
-		const prefix string = ",\"way_cols\":"
+		const prefix string = ",\"tiers\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.WayCols))
+		if in.Tiers == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v15, v16 := range in.Tiers {
+				if v15 > 0 {
+					out.RawByte(',')
+				}
+				(v16).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
+		}
 	}
 	{
-		const prefix string = ",\"drops\":"
+		const prefix string = ",\"max_flows\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.Drops))
+		out.Uint64(uint64(in.MaxFlows))
 	}
 	{
-		const prefix string = ",\"marks\":"
+		const prefix string = ",\"updated_at\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.Marks))
+		out.Raw((in.UpdatedAt).MarshalJSON())
 	}
 	{
-		const prefix string = ",\"ack_drop\":"
+		const prefix string = ",\"last_changed\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.AckDrop))
+		out.Raw((in.LastChanged).MarshalJSON())
 	}
 	{
-		const prefix string = ",\"sp_flows\":"
+		const prefix string = ",\"tx_bytes_per_s\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.SpFlows))
+		out.Float64(float64(in.TxBytesPerS))
 	}
 	{
-		const prefix string = ",\"bk_flows\":"
+		const prefix string = ",\"drops_per_s\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.BkFlows))
+		out.Float64(float64(in.DropsPerS))
 	}
 	{
-		const prefix string = ",\"un_flows\":"
+		const prefix string = ",\"marks_per_s\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.UnFlows))
+		out.Float64(float64(in.MarksPerS))
 	}
 	{
-		const prefix string = ",\"max_len\":"
+		const prefix string = ",\"ack_drop_per_s\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.MaxLen))
+		out.Float64(float64(in.AckDropPerS))
 	}
 	{
-		const prefix string = ",\"quantum\":"
+		const prefix string = ",\"way_cols_per_s\":"
 		out.RawString(prefix)
-		out.Uint64(uint64(in.Quantum))
+		out.Float64(float64(in.WayColsPerS))
+	}
+	{
+		const prefix string = ",\"max_av_delay_ms\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.MaxAvDelayMs))
+	}
+	{
+		const prefix string = ",\"max_pk_delay_ms\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.MaxPkDelayMs))
+	}
+	{
+		const prefix string = ",\"utilization_pct\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.UtilizationPct))
+	}
+	{
+		const prefix string = ",\"memory_pct\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.MemoryPct))
 	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v CakeTier) MarshalJSON() ([]byte, error) {
+func (v CakeStats) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes2(&w, v)
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes13(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
-func (v CakeTier) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes2(w, v)
+func (v CakeStats) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes13(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
-func (v *CakeTier) UnmarshalJSON(data []byte) error {
+func (v *CakeStats) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes2(&r, v)
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes13(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
-func (v *CakeTier) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes2(l, v)
+func (v *CakeStats) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes13(l, v)
 }
-func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes3(in *jlexer.Lexer, out *CakeStats) {
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes14(in *jlexer.Lexer, out *CakeAlert) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -539,238 +2426,293 @@ func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes3(in *jlexer.Lexer, ou
 			} else {
 				out.Interface = string(in.String())
 			}
-		case "handle":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Handle = string(in.String())
-			}
-		case "direction":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Direction = string(in.String())
-			}
-		case "bandwidth":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Bandwidth = string(in.String())
-			}
-		case "diffserv_mode":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.DiffservMode = string(in.String())
-			}
-		case "rtt":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.RTT = string(in.String())
-			}
-		case "overhead":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.Overhead = string(in.String())
-			}
-		case "dual_mode":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.DualMode = string(in.String())
-			}
-		case "fwmark_mask":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.FwmarkMask = string(in.String())
-			}
-		case "nat_enabled":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.NATEnabled = bool(in.Bool())
-			}
-		case "atm_mode":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.ATMMode = string(in.String())
-			}
-		case "mpu":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.MPU = string(in.String())
-			}
-		case "wash_enabled":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.WashEnabled = bool(in.Bool())
-			}
-		case "memlimit":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.MemLimit = string(in.String())
-			}
-		case "raw_header":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.RawHeader = string(in.String())
-			}
-		case "sent_bytes":
+		case "metric":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.SentBytes = uint64(in.Uint64())
+				out.Metric = string(in.String())
 			}
-		case "sent_pkts":
+		case "value":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.SentPkts = uint64(in.Uint64())
+				out.Value = float64(in.Float64())
 			}
-		case "dropped":
+		case "threshold":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.Dropped = uint64(in.Uint64())
+				out.Threshold = float64(in.Float64())
 			}
-		case "overlimits":
+		case "triggered_at":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.Overlimits = uint64(in.Uint64())
+				if data := in.Raw(); in.Ok() {
+					in.AddError((out.TriggeredAt).UnmarshalJSON(data))
+				}
 			}
-		case "requeues":
+		case "resolved_at":
 			if in.IsNull() {
 				in.Skip()
+				out.ResolvedAt = nil
 			} else {
-				out.Requeues = uint64(in.Uint64())
+				if out.ResolvedAt == nil {
+					out.ResolvedAt = new(time.Time)
+				}
+				if in.IsNull() {
+					in.Skip()
+				} else {
+					if data := in.Raw(); in.Ok() {
+						in.AddError((*out.ResolvedAt).UnmarshalJSON(data))
+					}
+				}
 			}
-		case "backlog_bytes":
+		case "bandwidth":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.BacklogBytes = string(in.String())
+				out.Bandwidth = string(in.String())
 			}
-		case "backlog_pkts":
+		case "tier":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.BacklogPkts = uint64(in.Uint64())
+				out.Tier = string(in.String())
 			}
-		case "memory_used":
-			if in.IsNull() {
-				in.Skip()
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes14(out *jwriter.Writer, in CakeAlert) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"interface\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Interface))
+	}
+	{
+		const prefix string = ",\"metric\":"
+		out.RawString(prefix)
+		out.String(string(in.Metric))
+	}
+	{
+		const prefix string = ",\"value\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Value))
+	}
+	{
+		const prefix string = ",\"threshold\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Threshold))
+	}
+	{
+		const prefix string = ",\"triggered_at\":"
+		out.RawString(prefix)
+		out.Raw((in.TriggeredAt).MarshalJSON())
+	}
+	{
+		const prefix string = ",\"resolved_at\":"
+		out.RawString(prefix)
+		if in.ResolvedAt == nil {
+			out.RawString("null")
+		} else {
+			out.Raw((*in.ResolvedAt).MarshalJSON())
+		}
+	}
+	if in.Bandwidth != "" {
+		const prefix string = ",\"bandwidth\":"
+		out.RawString(prefix)
+		out.String(string(in.Bandwidth))
+	}
+	if in.Tier != "" {
+		const prefix string = ",\"tier\":"
+		out.RawString(prefix)
+		out.String(string(in.Tier))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v CakeAlert) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes14(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v CakeAlert) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes14(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *CakeAlert) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes14(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *CakeAlert) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes14(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes15(in *jlexer.Lexer, out *AlertsResponse) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(AlertsResponse, 0, 0)
 			} else {
-				out.MemoryUsed = string(in.String())
+				*out = AlertsResponse{}
 			}
-		case "memory_total":
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v17 AlertLogEntry
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.MemoryTotal = string(in.String())
+				(v17).UnmarshalEasyJSON(in)
 			}
-		case "capacity_estimate":
+			*out = append(*out, v17)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes15(out *jwriter.Writer, in AlertsResponse) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v18, v19 := range in {
+			if v18 > 0 {
+				out.RawByte(',')
+			}
+			(v19).MarshalEasyJSON(out)
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v AlertsResponse) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes15(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v AlertsResponse) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes15(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *AlertsResponse) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes15(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *AlertsResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes15(l, v)
+}
+func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes16(in *jlexer.Lexer, out *AlertLogEntry) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "duration_ms":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.CapacityEst = string(in.String())
+				out.DurationMs = int64(in.Int64())
 			}
-		case "min_net_size":
+		case "interface":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.MinNetSize = string(in.String())
+				out.Interface = string(in.String())
 			}
-		case "max_net_size":
+		case "metric":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.MaxNetSize = string(in.String())
+				out.Metric = string(in.String())
 			}
-		case "min_adj_size":
+		case "value":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.MinAdjSize = string(in.String())
+				out.Value = float64(in.Float64())
 			}
-		case "max_adj_size":
+		case "threshold":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.MaxAdjSize = string(in.String())
+				out.Threshold = float64(in.Float64())
 			}
-		case "avg_hdr_offset":
+		case "triggered_at":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.AvgHdrOffset = string(in.String())
+				if data := in.Raw(); in.Ok() {
+					in.AddError((out.TriggeredAt).UnmarshalJSON(data))
+				}
 			}
-		case "tiers":
+		case "resolved_at":
 			if in.IsNull() {
 				in.Skip()
-				out.Tiers = nil
+				out.ResolvedAt = nil
 			} else {
-				in.Delim('[')
-				if out.Tiers == nil {
-					if !in.IsDelim(']') {
-						out.Tiers = make([]CakeTier, 0, 0)
-					} else {
-						out.Tiers = []CakeTier{}
-					}
-				} else {
-					out.Tiers = (out.Tiers)[:0]
+				if out.ResolvedAt == nil {
+					out.ResolvedAt = new(time.Time)
 				}
-				for !in.IsDelim(']') {
-					var v4 CakeTier
-					if in.IsNull() {
-						in.Skip()
-					} else {
-						(v4).UnmarshalEasyJSON(in)
+				if in.IsNull() {
+					in.Skip()
+				} else {
+					if data := in.Raw(); in.Ok() {
+						in.AddError((*out.ResolvedAt).UnmarshalJSON(data))
 					}
-					out.Tiers = append(out.Tiers, v4)
-					in.WantComma()
-				}
-				in.Delim(']')
-			}
-		case "updated_at":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				if data := in.Raw(); in.Ok() {
-					in.AddError((out.UpdatedAt).UnmarshalJSON(data))
 				}
 			}
-		case "tx_bytes_per_s":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.TxBytesPerS = float64(in.Float64())
-			}
-		case "drops_per_s":
-			if in.IsNull() {
-				in.Skip()
-			} else {
-				out.DropsPerS = float64(in.Float64())
-			}
-		case "max_av_delay_ms":
+		case "bandwidth":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.MaxAvDelayMs = float64(in.Float64())
+				out.Bandwidth = string(in.String())
 			}
-		case "max_pk_delay_ms":
+		case "tier":
 			if in.IsNull() {
 				in.Skip()
 			} else {
-				out.MaxPkDelayMs = float64(in.Float64())
+				out.Tier = string(in.String())
 			}
 		default:
 			in.SkipRecursive()
@@ -782,224 +2724,82 @@ func easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes3(in *jlexer.Lexer, ou
 		in.Consumed()
 	}
 }
-func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes3(out *jwriter.Writer, in CakeStats) {
+func easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes16(out *jwriter.Writer, in AlertLogEntry) {
 	out.RawByte('{')
 	first := true
 	_ = first
 	{
-		const prefix string = ",\"interface\":"
+		const prefix string = ",\"duration_ms\":"
 		out.RawString(prefix[1:])
-		out.String(string(in.Interface))
-	}
-	{
-		const prefix string = ",\"handle\":"
-		out.RawString(prefix)
-		out.String(string(in.Handle))
-	}
-	{
-		const prefix string = ",\"direction\":"
-		out.RawString(prefix)
-		out.String(string(in.Direction))
-	}
-	{
-		const prefix string = ",\"bandwidth\":"
-		out.RawString(prefix)
-		out.String(string(in.Bandwidth))
-	}
-	{
-		const prefix string = ",\"diffserv_mode\":"
-		out.RawString(prefix)
-		out.String(string(in.DiffservMode))
-	}
-	{
-		const prefix string = ",\"rtt\":"
-		out.RawString(prefix)
-		out.String(string(in.RTT))
-	}
-	{
-		const prefix string = ",\"overhead\":"
-		out.RawString(prefix)
-		out.String(string(in.Overhead))
-	}
-	{
-		const prefix string = ",\"dual_mode\":"
-		out.RawString(prefix)
-		out.String(string(in.DualMode))
-	}
-	{
-		const prefix string = ",\"fwmark_mask\":"
-		out.RawString(prefix)
-		out.String(string(in.FwmarkMask))
-	}
-	{
-		const prefix string = ",\"nat_enabled\":"
-		out.RawString(prefix)
-		out.Bool(bool(in.NATEnabled))
-	}
-	{
-		const prefix string = ",\"atm_mode\":"
-		out.RawString(prefix)
-		out.String(string(in.ATMMode))
-	}
-	{
-		const prefix string = ",\"mpu\":"
-		out.RawString(prefix)
-		out.String(string(in.MPU))
-	}
-	{
-		const prefix string = ",\"wash_enabled\":"
-		out.RawString(prefix)
-		out.Bool(bool(in.WashEnabled))
-	}
-	{
-		const prefix string = ",\"memlimit\":"
-		out.RawString(prefix)
-		out.String(string(in.MemLimit))
-	}
-	{
-		const prefix string = ",\"raw_header\":"
-		out.RawString(prefix)
-		out.String(string(in.RawHeader))
-	}
-	{
-		const prefix string = ",\"sent_bytes\":"
-		out.RawString(prefix)
-		out.Uint64(uint64(in.SentBytes))
-	}
-	{
-		const prefix string = ",\"sent_pkts\":"
-		out.RawString(prefix)
-		out.Uint64(uint64(in.SentPkts))
-	}
-	{
-		const prefix string = ",\"dropped\":"
-		out.RawString(prefix)
-		out.Uint64(uint64(in.Dropped))
-	}
-	{
-		const prefix string = ",\"overlimits\":"
-		out.RawString(prefix)
-		out.Uint64(uint64(in.Overlimits))
-	}
-	{
-		const prefix string = ",\"requeues\":"
-		out.RawString(prefix)
-		out.Uint64(uint64(in.Requeues))
-	}
-	{
-		const prefix string = ",\"backlog_bytes\":"
-		out.RawString(prefix)
-		out.String(string(in.BacklogBytes))
-	}
-	{
-		const prefix string = ",\"backlog_pkts\":"
-		out.RawString(prefix)
-		out.Uint64(uint64(in.BacklogPkts))
-	}
-	{
-		const prefix string = ",\"memory_used\":"
-		out.RawString(prefix)
-		out.String(string(in.MemoryUsed))
-	}
-	{
-		const prefix string = ",\"memory_total\":"
-		out.RawString(prefix)
-		out.String(string(in.MemoryTotal))
-	}
-	{
-		const prefix string = ",\"capacity_estimate\":"
-		out.RawString(prefix)
-		out.String(string(in.CapacityEst))
+		out.Int64(int64(in.DurationMs))
 	}
 	{
-		const prefix string = ",\"min_net_size\":"
+		const prefix string = ",\"interface\":"
 		out.RawString(prefix)
-		out.String(string(in.MinNetSize))
+		out.String(string(in.Interface))
 	}
 	{
-		const prefix string = ",\"max_net_size\":"
+		const prefix string = ",\"metric\":"
 		out.RawString(prefix)
-		out.String(string(in.MaxNetSize))
+		out.String(string(in.Metric))
 	}
 	{
-		const prefix string = ",\"min_adj_size\":"
+		const prefix string = ",\"value\":"
 		out.RawString(prefix)
-		out.String(string(in.MinAdjSize))
+		out.Float64(float64(in.Value))
 	}
 	{
-		const prefix string = ",\"max_adj_size\":"
+		const prefix string = ",\"threshold\":"
 		out.RawString(prefix)
-		out.String(string(in.MaxAdjSize))
+		out.Float64(float64(in.Threshold))
 	}
 	{
-		const prefix string = ",\"avg_hdr_offset\":"
+		const prefix string = ",\"triggered_at\":"
 		out.RawString(prefix)
-		out.String(string(in.AvgHdrOffset))
+		out.Raw((in.TriggeredAt).MarshalJSON())
 	}
 	{
-		const prefix string = ",\"tiers\":"
+		const prefix string = ",\"resolved_at\":"
 		out.RawString(prefix)
-		if in.Tiers == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		if in.ResolvedAt == nil {
 			out.RawString("null")
 		} else {
-			out.RawByte('[')
-			for v5, v6 := range in.Tiers {
-				if v5 > 0 {
-					out.RawByte(',')
-				}
-				(v6).MarshalEasyJSON(out)
-			}
-			out.RawByte(']')
+			out.Raw((*in.ResolvedAt).MarshalJSON())
 		}
 	}
-	{
-		const prefix string = ",\"updated_at\":"
-		out.RawString(prefix)
-		out.Raw((in.UpdatedAt).MarshalJSON())
-	}
-	{
-		const prefix string = ",\"tx_bytes_per_s\":"
-		out.RawString(prefix)
-		out.Float64(float64(in.TxBytesPerS))
-	}
-	{
-		const prefix string = ",\"drops_per_s\":"
-		out.RawString(prefix)
-		out.Float64(float64(in.DropsPerS))
-	}
-	{
-		const prefix string = ",\"max_av_delay_ms\":"
+	if in.Bandwidth != "" {
+		const prefix string = ",\"bandwidth\":"
 		out.RawString(prefix)
-		out.Float64(float64(in.MaxAvDelayMs))
+		out.String(string(in.Bandwidth))
 	}
-	{
-		const prefix string = ",\"max_pk_delay_ms\":"
+	if in.Tier != "" {
+		const prefix string = ",\"tier\":"
 		out.RawString(prefix)
-		out.Float64(float64(in.MaxPkDelayMs))
+		out.String(string(in.Tier))
 	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v CakeStats) MarshalJSON() ([]byte, error) {
+func (v AlertLogEntry) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes3(&w, v)
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes16(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
-func (v CakeStats) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes3(w, v)
+func (v AlertLogEntry) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson6601e8cdEncodeGithubComGalptCakeStatsPkgTypes16(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
-func (v *CakeStats) UnmarshalJSON(data []byte) error {
+func (v *AlertLogEntry) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes3(&r, v)
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes16(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
-func (v *CakeStats) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes3(l, v)
+func (v *AlertLogEntry) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson6601e8cdDecodeGithubComGalptCakeStatsPkgTypes16(l, v)
 }
@@ -46,7 +46,8 @@ func ParseBytesStr(s string) uint64 {
 // ParseDelayMs converts a tc delay string to float64 milliseconds.
 //
 // Recognised suffixes: "us" (microseconds), "ms" (milliseconds), "s" (seconds).
-// The function handles decimal values such as "1.5ms".
+// The function handles decimal values such as "1.5ms", and "1,5ms" as some
+// tc builds print them under a locale with a decimal comma (e.g. de_DE).
 // Returns 0 for empty input, the bare string "0", or unrecognised suffixes.
 //
 // This is the single canonical implementation; it replaces the duplicate
@@ -57,6 +58,9 @@ func ParseDelayMs(s string) float64 {
 	if s == "" || s == "0" {
 		return 0
 	}
+	if strings.IndexByte(s, ',') >= 0 {
+		s = strings.Replace(s, ",", ".", 1)
+	}
 	for _, sfx := range []string{"us", "ms", "s"} {
 		if strings.HasSuffix(s, sfx) {
 			v, err := strconv.ParseFloat(strings.TrimSuffix(s, sfx), 64)
@@ -41,6 +41,19 @@ func TestParseDelayMs(t *testing.T) {
 	}
 }
 
+func TestParseDelayUsec_DecimalComma(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want float64
+	}{
+		{"1,5ms", 1500}, {"545,0us", 545}, {"545us", 545}, {"1.5ms", 1500}, {"0,25s", 250000},
+	} {
+		if got := ParseDelayUsec(tc.in); got != tc.want {
+			t.Errorf("ParseDelayUsec(%q)=%v want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
 func TestBytesToString(t *testing.T) {
 	if s := BytesToString([]byte("hi")); s != "hi" {
 		t.Errorf("BytesToString=%q want hi", s)